@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"go.sia.tech/sia-antfarm/persist"
+	"go.sia.tech/sia-antfarm/proxy"
 	"go.sia.tech/sia-antfarm/upnprouter"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/node/api/client"
@@ -68,16 +70,45 @@ type assignedPortsType struct {
 // services.
 var assignedPorts assignedPortsType
 
+var (
+	// ErrAntRunning is returned when starting an ant whose siad is still
+	// running.
+	ErrAntRunning = errors.New("ant is already running")
+)
+
 // AntConfig represents a configuration object passed to New(), used to
 // configure a newly created Sia Ant.
 type AntConfig struct {
 	SiadConfig
 
 	Name            string `json:",omitempty"`
-	Jobs            []string
+	Jobs            []JobConfig
 	DesiredCurrency uint64
 
+	// UseFaucet defines whether the ant requests its desired currency and
+	// the host job's desired balance from the antfarm's faucet instead of
+	// mining.
+	UseFaucet bool `json:",omitempty"`
+
+	// SiadVersion is a Sia git tag or branch the ant's siad is built from,
+	// it is an alternative to SiadPath. The antfarm resolves it to the path
+	// of the built siad binary, building the binary if it is missing.
+	SiadVersion string `json:",omitempty"`
+
+	// Proxy enables a traffic shaping proxy in front of the ant's RPC, host
+	// and SiaMux addresses.
+	Proxy *proxy.Config `json:",omitempty"`
+
+	// RestartPolicy defines whether the ant's siad is restarted after it
+	// crashes. By default a crashed siad isn't restarted.
+	RestartPolicy *RestartPolicy `json:",omitempty"`
+
 	InitialWalletSeed string
+
+	// Seed makes the ant's wallet seed (unless InitialWalletSeed is set),
+	// renter file contents and jobs' random choices deterministic. An empty
+	// seed means random values.
+	Seed string `json:",omitempty"`
 }
 
 // An Ant is a Sia Client programmed with network user stories. It executes
@@ -96,8 +127,74 @@ type Ant struct {
 
 	Config AntConfig
 
-	siad *exec.Cmd
-	Jr   *JobRunner
+	// siad is the ant's siad process, it is nil if the ant uses an external
+	// siad. siadMu serializes closing the ant and starting or restarting
+	// siad. Jr is the ant's job runner, it is replaced under siadMu and jrMu
+	// when siad is started again, so it must be read under one of them, e.g.
+	// by JobRunner.
+	siad   *siadProcess
+	Jr     *JobRunner
+	siadMu sync.Mutex
+	jrMu   sync.Mutex
+
+	// crashes contains the siad crashes captured by the ant's supervisor,
+	// siadRestarts is the number of siad restart attempts done by the
+	// supervisor.
+	crashes      []Crash
+	siadRestarts uint64
+	crashMu      sync.Mutex
+
+	// supervisorStop is closed to stop the supervisor from restarting a
+	// crashed siad, it is replaced under siadMu. abortStart is closed by
+	// Close to abort the warm-up of a siad being started, so that closing
+	// the ant doesn't wait for it, it is accessed under jrMu.
+	supervisorStop chan struct{}
+	abortStart     chan struct{}
+
+	// proxies are the traffic shaping proxies in front of the ant's siad, if
+	// the ant has a proxy configured.
+	proxies []*proxy.Proxy
+
+	// jobs contains the jobs started on the ant, nextJobID is the ID of the
+	// last started job.
+	jobs      []*trackedJob
+	nextJobID uint64
+	jobsMu    sync.Mutex
+
+	// staticEvents publishes the events observed by the ant's event watcher,
+	// watchState is the ant's state observed by the watcher. The watcher runs
+	// in the job runner's thread group, the state and the subscriptions are
+	// preserved when siad is restarted. watchUpdated is closed when the
+	// watcher updates the state, staticPollNow asks the watcher to poll siad
+	// without waiting for the next regular poll.
+	staticEvents  *EventBroker
+	staticPollNow chan struct{}
+	watchState    watchState
+	watchUpdated  chan struct{}
+	watchMu       sync.Mutex
+
+	// draining defines whether the ant's renter jobs stopped starting new
+	// uploads and downloads, inFlightTransfers is the number of the ant's
+	// in-flight uploads and downloads.
+	draining          bool
+	inFlightTransfers int
+	transfersMu       sync.Mutex
+
+	// balanceMaintainerCancel stops the running balance maintainer,
+	// balanceMaintainerDone is closed when it returns. Both are nil if the
+	// balance maintainer isn't running.
+	balanceMaintainerCancel context.CancelFunc
+	balanceMaintainerDone   chan struct{}
+	balanceMaintainerMu     sync.Mutex
+
+	// faucet funds the ant's wallet if the ant uses a faucet, it is nil if
+	// the ant was created without a faucet and the faucet wasn't set yet.
+	faucet   Faucet
+	faucetMu sync.Mutex
+
+	// staticRand is the source of the ant's random file contents and random
+	// choices, it is deterministic if the ant has a seed.
+	staticRand *antRand
 
 	// A variable to track which blocks + heights the sync detector has seen
 	// for this ant. The map will just keep growing, but it shouldn't take up a
@@ -275,6 +372,20 @@ func NameRenter(i int) string {
 
 // New creates a new Ant using the configuration passed through `config`.
 func New(antsSyncWG *sync.WaitGroup, logger *persist.Logger, config AntConfig) (*Ant, error) {
+	return NewWithContext(context.Background(), antsSyncWG, logger, config)
+}
+
+// NewWithContext creates a new Ant using the configuration passed through
+// `config`. Starting siad is aborted when the context is cancelled.
+func NewWithContext(ctx context.Context, antsSyncWG *sync.WaitGroup, logger *persist.Logger, config AntConfig) (*Ant, error) {
+	return NewWithFaucet(ctx, antsSyncWG, logger, config, nil)
+}
+
+// NewWithFaucet creates a new Ant using the configuration passed through
+// `config` with the faucet set before the ant's jobs start, so that jobs of an
+// ant with UseFaucet can request funds right away. Starting siad is aborted
+// when the context is cancelled.
+func NewWithFaucet(ctx context.Context, antsSyncWG *sync.WaitGroup, logger *persist.Logger, config AntConfig, faucet Faucet) (*Ant, error) {
 	// Create ant working dir if it doesn't exist
 	// (e.g. ant farm deleted the whole farm dir)
 	if _, err := os.Stat(config.DataDir); os.IsNotExist(err) {
@@ -299,48 +410,73 @@ func New(antsSyncWG *sync.WaitGroup, logger *persist.Logger, config AntConfig) (
 		}
 	}
 
+	ant := &Ant{
+		staticAntsSyncWG: antsSyncWG,
+		staticLogger:     logger,
+		StaticClient:     c,
+		APIAddr:          config.APIAddr,
+		RPCAddr:          config.RPCAddr,
+		Config:           config,
+		SeenBlocks:       make(map[types.BlockHeight]types.BlockID),
+		staticEvents:     NewEventBroker(),
+		staticPollNow:    make(chan struct{}, 1),
+		staticRand:       newAntRand(config.Seed),
+		supervisorStop:   make(chan struct{}),
+		faucet:           faucet,
+	}
+
+	// Start the proxies in front of siad
+	err = ant.startProxies()
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to start proxies")
+	}
+	defer func() {
+		if err != nil {
+			ant.closeProxies()
+		}
+	}()
+
 	// Construct the ant's Siad instance
-	siad, err := newSiad(logger, config.SiadConfig)
+	siad, err := startSiad(ctx, logger, config.SiadConfig)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to create new siad process")
 	}
+	ant.siad = siad
 
 	// Ensure siad is always stopped if an error is returned.
 	defer func() {
 		if err != nil {
-			stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad.Process)
+			stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad)
 		}
 	}()
 
-	ant := &Ant{
-		staticAntsSyncWG: antsSyncWG,
-		staticLogger:     logger,
-		StaticClient:     c,
-		APIAddr:          config.APIAddr,
-		RPCAddr:          config.RPCAddr,
-		Config:           config,
-		SeenBlocks:       make(map[types.BlockHeight]types.BlockID),
-		siad:             siad,
-	}
-
 	j, err := newJobRunner(logger, ant, config.SiadConfig.DataDir, config.InitialWalletSeed)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to crate jobrunner")
 	}
 	ant.Jr = j
+	ant.recordSiadVersion()
+	go ant.threadedWatchEvents(j)
 
-	for _, job := range config.Jobs {
+	for _, jc := range config.Jobs {
 		// Here err should be reused (err =) instead of redeclared (err :=), so
 		// that defer can catch this error.
-		err = ant.StartJob(antsSyncWG, job)
+		var job Job
+		job, err = NewJobFromConfig(jc)
+		if err != nil {
+			return nil, errors.AddContext(err, "can't create ant's job")
+		}
+		if startedByAntfarm(job) {
+			continue
+		}
+		err = ant.RunJob(job)
 		if err != nil {
 			return nil, errors.AddContext(err, "can't start ant's job")
 		}
 	}
 
-	if config.DesiredCurrency != 0 {
-		go j.balanceMaintainer(types.SiacoinPrecision.Mul64(config.DesiredCurrency))
-	}
+	ant.managedStartBalanceMaintainer(j)
+	ant.startSupervisor()
 
 	return ant, nil
 }
@@ -378,19 +514,49 @@ func (a *Ant) BlockHeight() types.BlockHeight {
 }
 
 // Close releases all resources created by the ant, including the Siad
-// subprocess.
+// subprocess. The warm-up of a siad being started or restarted is aborted.
 func (a *Ant) Close() error {
+	a.jrMu.Lock()
+	if a.abortStart != nil {
+		close(a.abortStart)
+		a.abortStart = nil
+	}
+	a.jrMu.Unlock()
+
+	a.siadMu.Lock()
+	defer a.siadMu.Unlock()
+	a.stopSupervisor()
+	return a.stop()
+}
+
+// stop stops the ant's jobs, its siad and its proxies. It must be called
+// under siadMu.
+func (a *Ant) stop() error {
 	a.staticLogger.Printf("%v: starting to close ant", a.Config.SiadConfig.DataDir)
-	err := a.Jr.Stop()
-	stopSiad(a.staticLogger, a.Config.DataDir, a.APIAddr, a.Config.APIPassword, a.siad.Process)
+	var err error
+	if a.running() {
+		err = a.Jr.Stop()
+	}
+	stopSiad(a.staticLogger, a.Config.DataDir, a.APIAddr, a.Config.APIPassword, a.siad)
+	a.closeProxies()
 	return err
 }
 
+// HasJob returns true if the ant has the job with the given name.
+func (a *Ant) HasJob(job string) bool {
+	for _, jc := range a.Config.Jobs {
+		if jc.Name == job {
+			return true
+		}
+	}
+	return false
+}
+
 // HasRenterTypeJob returns true if the ant has renter type of job (renter or
 // autoRenter)
 func (a *Ant) HasRenterTypeJob() bool {
-	for _, jobName := range a.Config.Jobs {
-		jobNameLower := strings.ToLower(jobName)
+	for _, jc := range a.JobConfigs() {
+		jobNameLower := strings.ToLower(jc.Name)
 		if strings.Contains(jobNameLower, "renter") {
 			return true
 		}
@@ -398,6 +564,62 @@ func (a *Ant) HasRenterTypeJob() bool {
 	return false
 }
 
+// renterParams returns the parameters of the ant's first renter type job
+// config, or the default renter parameters if the ant doesn't have one.
+func (a *Ant) renterParams() RenterParams {
+	for _, jc := range a.JobConfigs() {
+		job, err := NewJobFromConfig(jc)
+		if err != nil {
+			continue
+		}
+		if rj, ok := job.(*RenterAntJob); ok {
+			return rj.RenterParams
+		}
+	}
+	return DefaultRenterParams()
+}
+
+// Metrics returns a snapshot of the metrics collected by the ant's jobs.
+func (a *Ant) Metrics() MetricsSnapshot {
+	jr := a.JobRunner()
+	if jr == nil {
+		return NewJobMetrics().Snapshot()
+	}
+	return jr.staticMetrics.Snapshot()
+}
+
+// JobRunner returns the ant's current job runner. The job runner is replaced
+// when the ant's siad is started again.
+func (a *Ant) JobRunner() *JobRunner {
+	a.jrMu.Lock()
+	defer a.jrMu.Unlock()
+	return a.Jr
+}
+
+// WalletSeed returns the wallet seed of the ant's job runner, it is empty if
+// the ant isn't running.
+func (a *Ant) WalletSeed() string {
+	jr := a.JobRunner()
+	if jr == nil {
+		return ""
+	}
+	return jr.StaticWalletSeed
+}
+
+// running returns true if the ant's job runner wasn't stopped. It must be
+// called under siadMu.
+func (a *Ant) running() bool {
+	if a.Jr == nil {
+		return false
+	}
+	select {
+	case <-a.Jr.StaticTG.StopChan():
+		return false
+	default:
+		return true
+	}
+}
+
 // PrintDebugInfo prints out helpful debug information, arguments define what
 // is printed.
 func (a *Ant) PrintDebugInfo(contractInfo, hostInfo, renterInfo bool) error {
@@ -515,48 +737,86 @@ func (a *Ant) PrintDebugInfo(contractInfo, hostInfo, renterInfo bool) error {
 	return nil
 }
 
-// StartJob starts the job indicated by `job` after an ant has been
-// initialized. Arguments are passed to the job using args.
-func (a *Ant) StartJob(antsSyncWG *sync.WaitGroup, job string, args ...interface{}) error {
-	if a.Jr == nil {
-		return errors.New("ant is not running")
+// recordSiadVersion records the version of the running siad in the ant's job
+// metrics.
+func (a *Ant) recordSiadVersion() {
+	dvg, err := a.StaticClient.DaemonVersionGet()
+	if err != nil {
+		a.staticLogger.Errorf("%v: can't get siad version: %v", a.Config.DataDir, err)
+		return
 	}
+	version := dvg.Version
+	if dvg.GitRevision != "" {
+		version += " (" + dvg.GitRevision + ")"
+	}
+	a.Jr.staticMetrics.RecordSiadVersion(version)
+}
 
-	switch job {
-	case "generic":
-		go a.Jr.jobGeneric()
-	case "miner":
-		go a.Jr.blockMining()
-	case "host":
-		go a.Jr.jobHost()
-	case "noAllowanceRenter":
-		go a.Jr.renter(walletFull)
-	case "renter":
-		go a.Jr.renter(allowanceSet)
-	case "autoRenter":
-		go a.Jr.renter(backgroundJobsStarted)
-	case "gateway":
-		go a.Jr.gatewayConnectability()
-	case "bigspender":
-		go a.Jr.bigSpender()
-	case "littlesupplier":
-		go a.Jr.littleSupplier(args[0].(types.UnlockHash))
-	default:
-		return errors.New("no such job")
+// StartJob starts the registered job indicated by `job` after an ant has been
+// initialized.
+func (a *Ant) StartJob(job string) error {
+	j, err := NewJob(job)
+	if err != nil {
+		return err
 	}
+	return a.RunJob(j)
+}
+
+// RunJob starts the given job after an ant has been initialized. The job runs
+// in the ant's job runner thread group, so stopping the ant stops the job. The
+// job's status can be queried by JobStatuses.
+func (a *Ant) RunJob(job Job) error {
+	jr := a.JobRunner()
+	if jr == nil {
+		return errors.New("ant is not running")
+	}
+	a.jobsMu.Lock()
+	a.nextJobID++
+	tj := &trackedJob{
+		staticJob: job,
+		status:    JobStatus{ID: a.nextJobID, Name: job.Name()},
+	}
+	a.jobsMu.Unlock()
+	if err := a.launchJob(jr, tj); err != nil {
+		return err
+	}
+	a.managedAddJob(tj)
 
 	return nil
 }
 
 // StartSiad starts ant using the given siad binary on the previously closed
-// ant.
+// ant. It returns ErrAntRunning if the ant wasn't closed.
 func (a *Ant) StartSiad(siadPath string) error {
+	a.siadMu.Lock()
+	defer a.siadMu.Unlock()
+	if a.running() {
+		return ErrAntRunning
+	}
+	a.stopSupervisor()
+	return a.start(siadPath)
+}
+
+// start starts the previously closed ant using the given siad binary. It
+// must be called under siadMu.
+func (a *Ant) start(siadPath string) error {
 	// Update path to new siad binary
 	a.Config.SiadConfig.SiadPath = siadPath
 
+	// Start the proxies in front of siad
+	err := a.startProxies()
+	if err != nil {
+		return errors.AddContext(err, "unable to start proxies")
+	}
+	defer func() {
+		if err != nil {
+			a.closeProxies()
+		}
+	}()
+
 	// Construct the ant's Siad instance
 	a.staticLogger.Printf("%v: starting new siad process using %v", a.Config.SiadConfig.DataDir, siadPath)
-	siad, err := newSiad(a.staticLogger, a.Config.SiadConfig)
+	siad, err := startSiad(context.Background(), a.staticLogger, a.Config.SiadConfig)
 	if err != nil {
 		return errors.AddContext(err, "unable to create new siad process")
 	}
@@ -565,7 +825,7 @@ func (a *Ant) StartSiad(siadPath string) error {
 	// Ensure siad is always stopped if an error is returned.
 	defer func() {
 		if err != nil {
-			stopSiad(a.staticLogger, a.Config.DataDir, a.Config.APIAddr, a.Config.APIPassword, siad.Process)
+			stopSiad(a.staticLogger, a.Config.DataDir, a.Config.APIAddr, a.Config.APIPassword, siad)
 		}
 	}()
 
@@ -577,13 +837,23 @@ func (a *Ant) StartSiad(siadPath string) error {
 	if err != nil {
 		return errors.AddContext(err, "can't update jobrunner after siad update")
 	}
+	abort := make(chan struct{})
+	a.jrMu.Lock()
 	a.Jr = jr
+	a.abortStart = abort
+	a.jrMu.Unlock()
+	a.recordSiadVersion()
+	a.managedStopDraining()
+	go a.threadedWatchEvents(jr)
 
 	// Give a new siad process some warm-up time
 	a.staticLogger.Debugf("%v: siad warm-up...", a.Config.SiadConfig.DataDir)
 	select {
 	case <-a.Jr.StaticTG.StopChan():
 		return nil
+	case <-abort:
+		a.staticLogger.Debugf("%v: siad warm-up was aborted", a.Config.SiadConfig.DataDir)
+		return nil
 	case <-time.After(updateSiadWarmUpTime):
 	}
 	a.staticLogger.Debugf("%v: siad warm-up finished", a.Config.SiadConfig.DataDir)
@@ -601,19 +871,26 @@ func (a *Ant) StartSiad(siadPath string) error {
 
 	// Restart jobs
 	a.staticLogger.Debugf("%v: restarting ant's jobs", a.Config.SiadConfig.DataDir)
-	for _, job := range a.Config.Jobs {
+	for _, jc := range a.JobConfigs() {
 		// Here err should be reused (err =) instead of redeclared (err :=), so
 		// that defer can catch this error.
-		err = a.StartJob(a.Jr.staticAntsSyncWG, job)
+		var job Job
+		job, err = NewJobFromConfig(jc)
+		if err != nil {
+			return errors.AddContext(err, "can't create ant's job")
+		}
+		if startedByAntfarm(job) {
+			continue
+		}
+		err = a.RunJob(job)
 		if err != nil {
 			return errors.AddContext(err, "can't restart ant's job")
 		}
 	}
 
 	// Start balance maintainer if desired currency was set
-	if a.Config.DesiredCurrency > 0 {
-		go a.Jr.balanceMaintainer(types.SiacoinPrecision.Mul64(a.Config.DesiredCurrency))
-	}
+	a.managedStartBalanceMaintainer(a.Jr)
+	a.startSupervisor()
 
 	return nil
 }
@@ -639,16 +916,16 @@ func (a *Ant) UpdateSiad(siadPath string) error {
 // WaitConfirmedSiacoinBalance waits until ant wallet confirmed Siacoins meet
 // comparison condition.
 func (a *Ant) WaitConfirmedSiacoinBalance(cmpOp BalanceComparisonOperator, value types.Currency, timeout time.Duration) error {
-	c := a.StaticClient
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return a.WaitConfirmedSiacoinBalanceWithContext(ctx, cmpOp, value)
+}
 
-	frequency := time.Millisecond * 500
-	tries := int(timeout / frequency)
-	return build.Retry(tries, frequency, func() error {
-		wg, err := c.WalletGet()
-		if err != nil {
-			return errors.AddContext(err, "can't get wallet info")
-		}
-		cmp := wg.ConfirmedSiacoinBalance.Cmp(value)
+// WaitConfirmedSiacoinBalanceWithContext waits until ant wallet confirmed
+// Siacoins meet comparison condition or the context is done.
+func (a *Ant) WaitConfirmedSiacoinBalanceWithContext(ctx context.Context, cmpOp BalanceComparisonOperator, value types.Currency) error {
+	return a.waitForState(ctx, func(s watchState) error {
+		cmp := s.balance.Cmp(value)
 		switch {
 		case cmpOp == BalanceLess && cmp < 0:
 			return nil
@@ -661,32 +938,32 @@ func (a *Ant) WaitConfirmedSiacoinBalance(cmpOp BalanceComparisonOperator, value
 		case cmpOp == BalanceGreater && cmp > 0:
 			return nil
 		default:
-			return fmt.Errorf("actual balance %v is expected to be %v expected balance %v", wg.ConfirmedSiacoinBalance, cmpOp, value)
+			return fmt.Errorf("actual balance %v is expected to be %v expected balance %v", s.balance, cmpOp, value)
 		}
-	})
+	}, EventBalanceChanged)
 }
 
 // WaitForBlockHeight blocks until the ant reaches the given block height or
-// the timeout is reached.
+// the timeout is reached. The height is observed by the ant's event watcher,
+// which is asked to poll siad at least every frequency while waiting.
 func (a *Ant) WaitForBlockHeight(blockHeight types.BlockHeight, timeout, frequency time.Duration) error {
-	// Get client
-	c := a.StaticClient
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go a.threadedRequestPolls(ctx, frequency)
+	return a.WaitForBlockHeightWithContext(ctx, blockHeight)
+}
 
+// WaitForBlockHeightWithContext blocks until the ant reaches the given block
+// height or the context is done.
+func (a *Ant) WaitForBlockHeightWithContext(ctx context.Context, blockHeight types.BlockHeight) error {
 	// Wait for block height
 	a.staticLogger.Debugf("%v: waiting for block height %v", a.Config.DataDir, blockHeight)
-	tries := int(timeout / frequency)
-	err := build.Retry(tries, frequency, func() error {
-		cg, err := c.ConsensusGet()
-		if err != nil {
-			return errors.AddContext(err, "can't get consensus")
-		}
-		bh := cg.Height
-		if bh >= blockHeight {
+	err := a.waitForState(ctx, func(s watchState) error {
+		if s.height >= blockHeight {
 			return nil
 		}
-
-		return fmt.Errorf("block height not reached. Current height: %v, expected height: %v", bh, blockHeight)
-	})
+		return fmt.Errorf("block height not reached. Current height: %v, expected height: %v", s.height, blockHeight)
+	}, EventNewBlock)
 	if err != nil {
 		er := fmt.Errorf("waiting for block height failed: %v", err)
 		a.staticLogger.Debugf("%v: %v", a.Config.DataDir, er)
@@ -698,6 +975,14 @@ func (a *Ant) WaitForBlockHeight(blockHeight types.BlockHeight, timeout, frequen
 
 // WaitForContractsToRenew blocks until renter contracts are renewed.
 func (a *Ant) WaitForContractsToRenew(contractsCount int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return a.WaitForContractsToRenewWithContext(ctx, contractsCount)
+}
+
+// WaitForContractsToRenewWithContext blocks until renter contracts are
+// renewed or the context is done.
+func (a *Ant) WaitForContractsToRenewWithContext(ctx context.Context, contractsCount int) error {
 	// Check ant is renter
 	if !a.HasRenterTypeJob() {
 		return errors.New("The ant doesn't have renter job")
@@ -705,7 +990,7 @@ func (a *Ant) WaitForContractsToRenew(contractsCount int, timeout time.Duration)
 	a.staticLogger.Debugf("%v: waiting for renter contracts to renew", a.Config.SiadConfig.DataDir)
 
 	// Get current active contracts
-	rc, err := a.Jr.staticClient.RenterContractsGet()
+	rc, err := a.StaticClient.RenterContractsGet()
 	if err != nil {
 		return errors.AddContext(err, "can't get renter contracts")
 	}
@@ -723,26 +1008,18 @@ func (a *Ant) WaitForContractsToRenew(contractsCount int, timeout time.Duration)
 	}
 
 	// Wait for block height after all active contracts end
-	start := time.Now()
-	err = a.WaitForBlockHeight(contractsEndHeight+1, timeout, time.Second)
+	err = a.WaitForBlockHeightWithContext(ctx, contractsEndHeight+1)
 	if err != nil {
 		return errors.AddContext(err, "waiting for contracts end height failed")
 	}
 
 	// Wait for new contracts form
-	newContractsTimeout := timeout - time.Since(start)
-	frequency := time.Second
-	tries := int(newContractsTimeout/frequency) + 1
-	err = build.Retry(tries, frequency, func() error {
-		rc, err := a.Jr.staticClient.RenterContractsGet()
-		if err != nil {
-			return errors.AddContext(err, "can't get renter contracts")
-		}
-		if len(rc.ActiveContracts) != contractsCount {
-			return fmt.Errorf("count of active contracts: expected: %d, actual: %d", contractsCount, len(rc.ActiveContracts))
+	err = a.waitForState(ctx, func(s watchState) error {
+		if s.activeContracts != contractsCount {
+			return fmt.Errorf("count of renewed active contracts: expected: %d, actual: %d", contractsCount, s.activeContracts)
 		}
 		return nil
-	})
+	}, EventContractFormed, EventContractRenewed)
 	if err != nil {
 		er := fmt.Errorf("waiting for block contracts renew failed: %v", err)
 		a.staticLogger.Debugf("%v: %v", a.Config.DataDir, er)
@@ -760,7 +1037,7 @@ func (a *Ant) WaitForRenterWorkersPriceTableUpdatesAndCooldown(priceTableUpdateT
 	a.staticLogger.Debugf("%v: waiting for renter workers price tables updates...", a.Config.SiadConfig.DataDir)
 	start := time.Now()
 	updateTimes := make(map[types.FileContractID]time.Time)
-	rwg, err := a.Jr.staticClient.RenterWorkersGet()
+	rwg, err := a.StaticClient.RenterWorkersGet()
 	if err != nil {
 		return errors.AddContext(err, "can't get renter workers info")
 	}
@@ -770,7 +1047,7 @@ func (a *Ant) WaitForRenterWorkersPriceTableUpdatesAndCooldown(priceTableUpdateT
 	frequency := time.Second
 	tries := int(priceTableUpdateTimeout/frequency) + 1
 	err = build.Retry(tries, frequency, func() error {
-		rwg, err := a.Jr.staticClient.RenterWorkersGet()
+		rwg, err := a.StaticClient.RenterWorkersGet()
 		if err != nil {
 			return errors.AddContext(err, "can't get renter workers info")
 		}
@@ -791,8 +1068,12 @@ func (a *Ant) WaitForRenterWorkersPriceTableUpdatesAndCooldown(priceTableUpdateT
 	a.staticLogger.Debugf("%v: waiting for renter workers price tables updates finished in %v", a.Config.SiadConfig.DataDir, time.Since(start))
 
 	// Give a little time for possible cooldowns to start
+	jr := a.JobRunner()
+	if jr == nil {
+		return errors.New("ant is not running")
+	}
 	select {
-	case <-a.Jr.StaticTG.StopChan():
+	case <-jr.StaticTG.StopChan():
 		return nil
 	case <-time.After(time.Second):
 	}
@@ -802,7 +1083,7 @@ func (a *Ant) WaitForRenterWorkersPriceTableUpdatesAndCooldown(priceTableUpdateT
 	start = time.Now()
 	tries = int(cooldownTimeout/frequency) + 1
 	err = build.Retry(tries, frequency, func() error {
-		rwg, err := a.Jr.staticClient.RenterWorkersGet()
+		rwg, err := a.StaticClient.RenterWorkersGet()
 		if err != nil {
 			return errors.AddContext(err, "can't get renter workers info")
 		}
@@ -824,11 +1105,12 @@ func (a *Ant) WaitForRenterWorkersPriceTableUpdatesAndCooldown(priceTableUpdateT
 
 // WalletAddress returns a wallet address that this ant can receive coins on.
 func (a *Ant) WalletAddress() (*types.UnlockHash, error) {
-	if a.Jr == nil {
+	jr := a.JobRunner()
+	if jr == nil {
 		return nil, errors.New("ant is not running")
 	}
 
-	addressGet, err := a.Jr.staticClient.WalletAddressGet()
+	addressGet, err := jr.staticClient.WalletAddressGet()
 	if err != nil {
 		return nil, err
 	}
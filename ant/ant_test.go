@@ -246,7 +246,7 @@ func TestStartJob(t *testing.T) {
 	}()
 
 	// Nonexistent job should throw an error
-	err = ant.StartJob(&sync.WaitGroup{}, "thisjobdoesnotexist")
+	err = ant.StartJob("thisjobdoesnotexist")
 	if err == nil {
 		t.Fatal("StartJob should return an error with a nonexistent job")
 	}
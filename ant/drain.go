@@ -0,0 +1,86 @@
+package ant
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// drainCheckFrequency defines how frequently a draining ant checks for
+	// in-flight transfers.
+	drainCheckFrequency = time.Millisecond * 100
+)
+
+var (
+	// errAntDraining is returned when a transfer is started on a draining
+	// ant.
+	errAntDraining = errors.New("ant is draining, new transfers are not started")
+)
+
+// Drain stops the ant's renter jobs from starting new uploads and downloads
+// and blocks until the in-flight transfers finish or the context is done. The
+// ant starts transfers again after its siad is restarted.
+func (a *Ant) Drain(ctx context.Context) error {
+	a.transfersMu.Lock()
+	a.draining = true
+	a.transfersMu.Unlock()
+	a.staticLogger.Printf("%v: draining ant", a.Config.DataDir)
+
+	for {
+		n := a.managedInFlightTransfers()
+		if n == 0 {
+			a.staticLogger.Printf("%v: ant was drained", a.Config.DataDir)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.AddContext(ctx.Err(), fmt.Sprintf("%v transfers are still in flight", n))
+		case <-time.After(drainCheckFrequency):
+		}
+	}
+}
+
+// managedDraining returns true if the ant is draining.
+func (a *Ant) managedDraining() bool {
+	a.transfersMu.Lock()
+	defer a.transfersMu.Unlock()
+	return a.draining
+}
+
+// managedInFlightTransfers returns the number of the ant's in-flight uploads
+// and downloads.
+func (a *Ant) managedInFlightTransfers() int {
+	a.transfersMu.Lock()
+	defer a.transfersMu.Unlock()
+	return a.inFlightTransfers
+}
+
+// managedStartTransfer registers a new in-flight upload or download. It
+// returns errAntDraining if the ant is draining. A successfully started
+// transfer must be finished with managedFinishTransfer.
+func (a *Ant) managedStartTransfer() error {
+	a.transfersMu.Lock()
+	defer a.transfersMu.Unlock()
+	if a.draining {
+		return errAntDraining
+	}
+	a.inFlightTransfers++
+	return nil
+}
+
+// managedFinishTransfer unregisters a finished in-flight upload or download.
+func (a *Ant) managedFinishTransfer() {
+	a.transfersMu.Lock()
+	defer a.transfersMu.Unlock()
+	a.inFlightTransfers--
+}
+
+// managedStopDraining lets the ant start transfers again.
+func (a *Ant) managedStopDraining() {
+	a.transfersMu.Lock()
+	defer a.transfersMu.Unlock()
+	a.draining = false
+}
@@ -0,0 +1,58 @@
+package ant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/test"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestDrain verifies draining the ant's in-flight transfers.
+func TestDrain(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	logger := test.NewTestLogger(t, test.TestDir(t.Name()))
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	a := &Ant{staticLogger: logger}
+	if err := a.managedStartTransfer(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Draining times out while the transfer is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := a.Drain(ctx); !errors.Contains(err, context.DeadlineExceeded) {
+		t.Fatalf("expected drain timeout, got %v", err)
+	}
+
+	// New transfers are not started while draining
+	if err := a.managedStartTransfer(); !errors.Contains(err, errAntDraining) {
+		t.Fatalf("expected %v, got %v", errAntDraining, err)
+	}
+
+	// Draining finishes after the in-flight transfer finishes
+	done := make(chan error)
+	go func() {
+		done <- a.Drain(context.Background())
+	}()
+	time.Sleep(time.Millisecond * 10)
+	a.managedFinishTransfer()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Transfers are started again after draining is stopped
+	a.managedStopDraining()
+	if err := a.managedStartTransfer(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,543 @@
+package ant
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/node/api"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// EventType defines type for event Type enum
+type EventType string
+
+// EventType constants define values for event Type enum
+const (
+	// EventNewBlock defines an event published when the ant's current block
+	// changes.
+	EventNewBlock EventType = "newBlock"
+
+	// EventBalanceChanged defines an event published when the ant's confirmed
+	// Siacoin balance changes.
+	EventBalanceChanged EventType = "balanceChanged"
+
+	// EventContractFormed defines an event published when the renter forms a
+	// contract with a new host.
+	EventContractFormed EventType = "contractFormed"
+
+	// EventContractRenewed defines an event published when the renter forms a
+	// contract with a host it already had a contract with.
+	EventContractRenewed EventType = "contractRenewed"
+
+	// EventUploadCompleted defines an event published when a renter file
+	// reaches 100% upload progress.
+	EventUploadCompleted EventType = "uploadCompleted"
+
+	// EventPeerConnected defines an event published when a new peer connects
+	// to the ant's gateway.
+	EventPeerConnected EventType = "peerConnected"
+
+	// EventSiadCrashed defines an event published when the ant's siad exits
+	// without being stopped by the ant.
+	EventSiadCrashed EventType = "siadCrashed"
+
+	// EventSiadRestarted defines an event published when the ant's
+	// supervisor restarted a crashed siad.
+	EventSiadRestarted EventType = "siadRestarted"
+)
+
+// eventTypes lists all event types, subscribing without types subscribes to
+// all of them.
+var eventTypes = []EventType{
+	EventNewBlock,
+	EventBalanceChanged,
+	EventContractFormed,
+	EventContractRenewed,
+	EventUploadCompleted,
+	EventPeerConnected,
+	EventSiadCrashed,
+	EventSiadRestarted,
+}
+
+const (
+	// eventWatchFrequency defines how frequently the ant's event watcher
+	// polls siad.
+	eventWatchFrequency = time.Second
+
+	// eventSubscriptionBuffer defines the number of events buffered for a
+	// subscriber. Events are dropped for subscribers with a full buffer.
+	eventSubscriptionBuffer = 100
+)
+
+type (
+	// Event is a change of an ant's state observed by the ant's event
+	// watcher. Fields not related to the event type are empty.
+	Event struct {
+		Type EventType
+		Time time.Time
+
+		// Ant is the name of the ant, or its data directory if the ant has
+		// no name.
+		Ant string
+
+		// BlockHeight is the ant's block height when the event was observed.
+		BlockHeight types.BlockHeight
+
+		BlockID     *types.BlockID        `json:",omitempty"`
+		Balance     *types.Currency       `json:",omitempty"`
+		ContractID  *types.FileContractID `json:",omitempty"`
+		HostAddress modules.NetAddress    `json:",omitempty"`
+		SiaPath     string                `json:",omitempty"`
+		Peer        modules.NetAddress    `json:",omitempty"`
+		Crash       *Crash                `json:",omitempty"`
+	}
+
+	// EventBroker publishes events to its subscribers.
+	EventBroker struct {
+		// subscribers maps the subscribers' channels to the event types they
+		// subscribed to, interest counts the subscribers of each event type.
+		subscribers map[chan Event]map[EventType]struct{}
+		interest    map[EventType]int
+
+		// forwards contains the brokers the events are forwarded to. Their
+		// subscribers count as subscribers of this broker.
+		forwards map[*EventBroker]struct{}
+
+		closed bool
+		mu     sync.Mutex
+	}
+
+	// watchState is the ant's state observed by the event watcher.
+	watchState struct {
+		// polled contains the time of the latest successful poll of the
+		// state behind each event type. The event watcher polls only the
+		// state of event types with subscribers.
+		polled map[EventType]time.Time
+
+		height  types.BlockHeight
+		blockID types.BlockID
+		balance types.Currency
+
+		// activeContracts is the number of the renter's active contracts.
+		activeContracts int
+
+		// contracts contains the IDs of the renter's known contracts,
+		// contractHosts contains the hosts the renter had contracts with.
+		contracts     map[types.FileContractID]struct{}
+		contractHosts map[string]struct{}
+
+		peers    map[modules.NetAddress]struct{}
+		uploaded map[modules.SiaPath]struct{}
+	}
+)
+
+// NewEventBroker creates a new event broker.
+func NewEventBroker() *EventBroker {
+	return &EventBroker{
+		subscribers: make(map[chan Event]map[EventType]struct{}),
+		interest:    make(map[EventType]int),
+		forwards:    make(map[*EventBroker]struct{}),
+	}
+}
+
+// Close closes the channels of all subscribers, events published afterwards
+// are dropped.
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.subscribers {
+		close(c)
+	}
+	b.subscribers = make(map[chan Event]map[EventType]struct{})
+	b.interest = make(map[EventType]int)
+	b.forwards = make(map[*EventBroker]struct{})
+	b.closed = true
+}
+
+// Forward publishes the events published by the broker also to the given
+// broker until the returned function is called.
+func (b *EventBroker) Forward(to *EventBroker) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return func() {}
+	}
+	b.forwards[to] = struct{}{}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.forwards, to)
+	}
+}
+
+// Publish sends the event to all subscribers of the event's type without
+// blocking.
+func (b *EventBroker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c, subscribed := range b.subscribers {
+		if _, ok := subscribed[e.Type]; !ok {
+			continue
+		}
+		select {
+		case c <- e:
+		default:
+		}
+	}
+	for to := range b.forwards {
+		to.Publish(e)
+	}
+}
+
+// Subscribe returns a channel receiving published events of the given types,
+// or of all types if no type is given, and a function cancelling the
+// subscription and closing the channel. The channel is also closed when the
+// broker is closed.
+func (b *EventBroker) Subscribe(types ...EventType) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan Event, eventSubscriptionBuffer)
+	if b.closed {
+		close(c)
+		return c, func() {}
+	}
+	if len(types) == 0 {
+		types = eventTypes
+	}
+	subscribed := make(map[EventType]struct{})
+	for _, t := range types {
+		if _, ok := subscribed[t]; !ok {
+			subscribed[t] = struct{}{}
+			b.interest[t]++
+		}
+	}
+	b.subscribers[c] = subscribed
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[c]; ok {
+				for t := range subscribed {
+					b.interest[t]--
+				}
+				delete(b.subscribers, c)
+				close(c)
+			}
+		})
+	}
+}
+
+// wants returns true if the broker or any broker it forwards to has
+// subscribers of any of the given event types.
+func (b *EventBroker) wants(types ...EventType) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, t := range types {
+		if b.interest[t] > 0 {
+			return true
+		}
+	}
+	for to := range b.forwards {
+		if to.wants(types...) {
+			return true
+		}
+	}
+	return false
+}
+
+// Events returns the broker publishing the ant's events.
+func (a *Ant) Events() *EventBroker {
+	return a.staticEvents
+}
+
+// eventAntName returns the name of the ant used in its events, the ant's data
+// directory if the ant has no name.
+func (a *Ant) eventAntName() string {
+	if a.Config.Name == "" {
+		return a.Config.DataDir
+	}
+	return a.Config.Name
+}
+
+// managedWatchState returns the ant's state observed by the event watcher.
+// The returned state doesn't contain the watcher's maps except polled.
+func (a *Ant) managedWatchState() watchState {
+	a.watchMu.Lock()
+	defer a.watchMu.Unlock()
+	polled := make(map[EventType]time.Time, len(a.watchState.polled))
+	for t, pollTime := range a.watchState.polled {
+		polled[t] = pollTime
+	}
+	return watchState{
+		polled:          polled,
+		height:          a.watchState.height,
+		blockID:         a.watchState.blockID,
+		balance:         a.watchState.balance,
+		activeContracts: a.watchState.activeContracts,
+	}
+}
+
+// managedWatchUpdated returns a channel closed when the event watcher updates
+// the ant's watched state.
+func (a *Ant) managedWatchUpdated() <-chan struct{} {
+	a.watchMu.Lock()
+	defer a.watchMu.Unlock()
+	if a.watchUpdated == nil {
+		a.watchUpdated = make(chan struct{})
+	}
+	return a.watchUpdated
+}
+
+// waitForState blocks until the check of the ant's watched state succeeds.
+// The waiter subscribes to the given event types, so that the event watcher
+// polls the state behind them, and checks only state polled after the wait
+// started. The state is checked again on each update by the watcher. The last
+// check error is returned when the context is done.
+func (a *Ant) waitForState(ctx context.Context, check func(watchState) error, types ...EventType) error {
+	if a.staticEvents == nil {
+		return errors.New("ant has no event watcher")
+	}
+	start := time.Now()
+	_, unsubscribe := a.staticEvents.Subscribe(types...)
+	defer unsubscribe()
+
+	// Don't wait for the next regular poll of the subscribed state
+	select {
+	case a.staticPollNow <- struct{}{}:
+	default:
+	}
+	for {
+		updated := a.managedWatchUpdated()
+		s := a.managedWatchState()
+		var err error
+		for _, t := range types {
+			if s.polled[t].Before(start) {
+				err = fmt.Errorf("ant's state wasn't observed yet")
+			}
+		}
+		if err == nil {
+			err = check(s)
+		}
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			return errors.Compose(ctx.Err(), err)
+		}
+	}
+}
+
+// threadedRequestPolls asks the event watcher to poll siad every frequency
+// until the context is done.
+func (a *Ant) threadedRequestPolls(ctx context.Context, frequency time.Duration) {
+	if frequency <= 0 {
+		return
+	}
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case a.staticPollNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// threadedWatchEvents polls the ant's siad and publishes events about the
+// observed changes until the job runner is stopped.
+func (a *Ant) threadedWatchEvents(jr *JobRunner) {
+	if err := jr.StaticTG.Add(); err != nil {
+		return
+	}
+	defer jr.StaticTG.Done()
+
+	for {
+		if err := a.managedPollEvents(); err != nil {
+			a.staticLogger.Debugf("%v: can't poll ant's events: %v", a.Config.DataDir, err)
+		}
+		select {
+		case <-jr.StaticTG.StopChan():
+			return
+		case <-a.staticPollNow:
+		case <-time.After(eventWatchFrequency):
+		}
+	}
+}
+
+// managedPollEvents polls the ant's siad once, updates the watched state and
+// publishes events about the changes. Only the state behind the event types
+// with subscribers is polled. A failing siad call doesn't prevent updating
+// the state observed by the other calls, the errors of the failing calls are
+// returned.
+func (a *Ant) managedPollEvents() error {
+	b := a.staticEvents
+	pollConsensus := b.wants(EventNewBlock)
+	pollWallet := b.wants(EventBalanceChanged)
+	pollGateway := b.wants(EventPeerConnected)
+	pollContracts := b.wants(EventContractFormed, EventContractRenewed) && a.HasRenterTypeJob()
+	pollFiles := b.wants(EventUploadCompleted)
+	if !pollConsensus && !pollWallet && !pollGateway && !pollContracts && !pollFiles {
+		return nil
+	}
+
+	// Poll siad
+	c := a.StaticClient
+	pollTime := time.Now()
+	var errs error
+	var cg api.ConsensusGET
+	if pollConsensus {
+		var err error
+		if cg, err = c.ConsensusGet(); err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, "can't get consensus"))
+			pollConsensus = false
+		}
+	}
+	var wg api.WalletGET
+	if pollWallet {
+		var err error
+		if wg, err = c.WalletGet(); err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, "can't get wallet"))
+			pollWallet = false
+		}
+	}
+	var gg api.GatewayGET
+	if pollGateway {
+		var err error
+		if gg, err = c.GatewayGet(); err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, "can't get gateway"))
+			pollGateway = false
+		}
+	}
+	var rc api.RenterContracts
+	if pollContracts {
+		var err error
+		if rc, err = c.RenterContractsGet(); err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, "can't get renter contracts"))
+			pollContracts = false
+		}
+	}
+	var rf api.RenterFiles
+	if pollFiles {
+		var err error
+		if rf, err = c.RenterFilesGet(false); err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, "can't get renter files"))
+			pollFiles = false
+		}
+	}
+
+	// Update the watched state and collect the events
+	a.watchMu.Lock()
+	s := &a.watchState
+	if s.polled == nil {
+		s.polled = make(map[EventType]time.Time)
+		s.peers = make(map[modules.NetAddress]struct{})
+		s.contracts = make(map[types.FileContractID]struct{})
+		s.contractHosts = make(map[string]struct{})
+	}
+	height := s.height
+	if pollConsensus {
+		height = cg.Height
+	}
+	now := time.Now()
+	newEvent := func(t EventType) Event {
+		return Event{Type: t, Time: now, Ant: a.eventAntName(), BlockHeight: height}
+	}
+	var events []Event
+	if _, polled := s.polled[EventNewBlock]; pollConsensus && (!polled || cg.CurrentBlock != s.blockID) {
+		e := newEvent(EventNewBlock)
+		id := cg.CurrentBlock
+		e.BlockID = &id
+		events = append(events, e)
+		s.height, s.blockID = cg.Height, cg.CurrentBlock
+	}
+	if _, polled := s.polled[EventBalanceChanged]; pollWallet && (!polled || !wg.ConfirmedSiacoinBalance.Equals(s.balance)) {
+		e := newEvent(EventBalanceChanged)
+		balance := wg.ConfirmedSiacoinBalance
+		e.Balance = &balance
+		events = append(events, e)
+		s.balance = balance
+	}
+	if pollGateway {
+		peers := make(map[modules.NetAddress]struct{})
+		for _, p := range gg.Peers {
+			peers[p.NetAddress] = struct{}{}
+			if _, ok := s.peers[p.NetAddress]; !ok {
+				e := newEvent(EventPeerConnected)
+				e.Peer = p.NetAddress
+				events = append(events, e)
+			}
+		}
+		s.peers = peers
+	}
+	if pollContracts {
+		s.activeContracts = len(rc.ActiveContracts)
+		for _, rContract := range rc.ActiveContracts {
+			if _, ok := s.contracts[rContract.ID]; ok {
+				continue
+			}
+			host := rContract.HostPublicKey.String()
+			e := newEvent(EventContractFormed)
+			if _, ok := s.contractHosts[host]; ok {
+				e.Type = EventContractRenewed
+			}
+			id := rContract.ID
+			e.ContractID = &id
+			e.HostAddress = rContract.NetAddress
+			events = append(events, e)
+			s.contracts[rContract.ID] = struct{}{}
+			s.contractHosts[host] = struct{}{}
+		}
+	}
+	if pollFiles {
+		uploaded := make(map[modules.SiaPath]struct{})
+		for _, f := range rf.Files {
+			if f.UploadProgress < 100 {
+				continue
+			}
+			uploaded[f.SiaPath] = struct{}{}
+			if _, ok := s.uploaded[f.SiaPath]; !ok {
+				e := newEvent(EventUploadCompleted)
+				e.SiaPath = f.SiaPath.String()
+				events = append(events, e)
+			}
+		}
+		s.uploaded = uploaded
+	}
+
+	// Record the poll time of the polled state
+	polled := map[EventType]bool{
+		EventNewBlock:        pollConsensus,
+		EventBalanceChanged:  pollWallet,
+		EventPeerConnected:   pollGateway,
+		EventContractFormed:  pollContracts,
+		EventContractRenewed: pollContracts,
+		EventUploadCompleted: pollFiles,
+	}
+	for t, ok := range polled {
+		if ok {
+			s.polled[t] = pollTime
+		}
+	}
+	if a.watchUpdated != nil {
+		close(a.watchUpdated)
+		a.watchUpdated = nil
+	}
+	a.watchMu.Unlock()
+
+	for _, e := range events {
+		b.Publish(e)
+	}
+	return errs
+}
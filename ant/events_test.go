@@ -0,0 +1,254 @@
+package ant
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/test"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestEventBroker verifies publishing events to subscribers.
+func TestEventBroker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	b := NewEventBroker()
+	events1, unsubscribe1 := b.Subscribe()
+	events2, unsubscribe2 := b.Subscribe()
+
+	// Both subscribers receive the event
+	b.Publish(Event{Type: EventNewBlock, BlockHeight: 1})
+	for _, events := range []<-chan Event{events1, events2} {
+		if e := <-events; e.Type != EventNewBlock || e.BlockHeight != 1 {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	}
+
+	// Unsubscribed channel is closed, unsubscribing twice is fine
+	unsubscribe1()
+	unsubscribe1()
+	if _, ok := <-events1; ok {
+		t.Fatal("expected closed channel after unsubscribe")
+	}
+
+	// Events are dropped for a subscriber with a full buffer
+	for i := 0; i < eventSubscriptionBuffer+10; i++ {
+		b.Publish(Event{Type: EventBalanceChanged})
+	}
+	if len(events2) != eventSubscriptionBuffer {
+		t.Fatalf("expected %v buffered events, got %v", eventSubscriptionBuffer, len(events2))
+	}
+
+	// Subscribers receive only the event types they subscribed to
+	events4, unsubscribe4 := b.Subscribe(EventUploadCompleted)
+	b.Publish(Event{Type: EventNewBlock})
+	b.Publish(Event{Type: EventUploadCompleted})
+	if e := <-events4; e.Type != EventUploadCompleted || len(events4) != 0 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if !b.wants(EventUploadCompleted) || !b.wants(EventNewBlock) {
+		t.Fatal("expected the subscribed types to be wanted")
+	}
+	unsubscribe2()
+	if b.wants(EventNewBlock) {
+		t.Fatal("expected no interest without subscribers")
+	}
+	unsubscribe4()
+	if b.wants(EventUploadCompleted) {
+		t.Fatal("expected no interest without subscribers")
+	}
+
+	// Events are forwarded and the forward target's subscribers count as
+	// subscribers
+	to := NewEventBroker()
+	stopForwarding := b.Forward(to)
+	events5, unsubscribe5 := to.Subscribe(EventPeerConnected)
+	if !b.wants(EventPeerConnected) || b.wants(EventNewBlock) {
+		t.Fatal("expected the forward target's subscribed types to be wanted")
+	}
+	b.Publish(Event{Type: EventPeerConnected})
+	if e := <-events5; e.Type != EventPeerConnected {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	stopForwarding()
+	if b.wants(EventPeerConnected) {
+		t.Fatal("expected no interest after forwarding stopped")
+	}
+	unsubscribe5()
+
+	// Closing the broker closes the subscriptions
+	events2, unsubscribe2 = b.Subscribe()
+	b.Close()
+	for range events2 {
+	}
+	unsubscribe2()
+	events3, _ := b.Subscribe()
+	if _, ok := <-events3; ok {
+		t.Fatal("expected closed channel subscribing to a closed broker")
+	}
+}
+
+// TestWaitForState verifies waiting for the ant's watched state.
+func TestWaitForState(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	a := &Ant{staticEvents: NewEventBroker(), staticPollNow: make(chan struct{}, 1)}
+	heightReached := func(s watchState) error {
+		if s.height < 10 {
+			return errors.New("block height not reached")
+		}
+		return nil
+	}
+
+	// waitForState waits for the state with a timeout
+	waitForState := func(a *Ant, timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return a.waitForState(ctx, heightReached, EventNewBlock)
+	}
+
+	// update simulates the event watcher polling the given height
+	update := func(height types.BlockHeight) {
+		a.watchMu.Lock()
+		defer a.watchMu.Unlock()
+		if a.watchState.polled == nil {
+			a.watchState.polled = make(map[EventType]time.Time)
+		}
+		a.watchState.polled[EventNewBlock] = time.Now()
+		a.watchState.height = height
+		if a.watchUpdated != nil {
+			close(a.watchUpdated)
+			a.watchUpdated = nil
+		}
+	}
+
+	// The state wasn't observed yet, the waiter asked the watcher to poll
+	if err := waitForState(a, time.Millisecond*10); !errors.Contains(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout before the state is observed, got %v", err)
+	}
+	select {
+	case <-a.staticPollNow:
+	default:
+		t.Fatal("expected a poll request")
+	}
+
+	// The check passes after the watcher updates the state, the waiter's
+	// event type is wanted meanwhile
+	done := make(chan error)
+	go func() {
+		done <- waitForState(a, time.Second*10)
+	}()
+	<-a.staticPollNow
+	if !a.staticEvents.wants(EventNewBlock) || a.staticEvents.wants(EventBalanceChanged) {
+		t.Fatal("expected only the waiter's event type to be wanted")
+	}
+	update(9)
+	update(10)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if a.staticEvents.wants(EventNewBlock) {
+		t.Fatal("expected no interest after the wait")
+	}
+
+	// State observed before the wait started isn't checked
+	go func() {
+		done <- waitForState(a, time.Second*10)
+	}()
+	<-a.staticPollNow
+	select {
+	case err := <-done:
+		t.Fatalf("expected the wait to block until the state is polled, got %v", err)
+	case <-time.After(time.Millisecond * 10):
+	}
+	update(10)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiting is aborted when the context is cancelled
+	update(5)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.waitForState(ctx, heightReached, EventNewBlock); !errors.Contains(err, context.Canceled) {
+		t.Fatalf("expected cancelled wait, got %v", err)
+	}
+
+	// Polls are requested at the given frequency until the context is done
+	ctx, cancel = context.WithCancel(context.Background())
+	go a.threadedRequestPolls(ctx, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-a.staticPollNow
+	}
+	cancel()
+
+	// An ant without an event watcher
+	if err := waitForState(&Ant{}, time.Millisecond); err == nil {
+		t.Fatal("expected error waiting on an ant without event watcher")
+	}
+}
+
+// TestPollEvents verifies that the event watcher polls only the state needed
+// by subscribers and that a failing siad call doesn't block the other state.
+func TestPollEvents(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a mock siad
+	dataDir := test.TestDir(t.Name())
+	mock, config := newMockSiadConfig(t, dataDir)
+	defer func() {
+		if err := mock.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create the ant
+	ant, err := New(&sync.WaitGroup{}, logger, AntConfig{SiadConfig: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ant.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Siad isn't polled without subscribers
+	requests := mock.Requests("GET /gateway") + mock.Requests("GET /consensus")
+	time.Sleep(eventWatchFrequency * 3)
+	if n := mock.Requests("GET /gateway") + mock.Requests("GET /consensus"); n != requests {
+		t.Fatalf("expected no polling without subscribers, got %v requests", n-requests)
+	}
+
+	// A failing gateway call doesn't block waiting for the block height
+	_, unsubscribe := ant.Events().Subscribe()
+	defer unsubscribe()
+	mock.SetError("GET /gateway", errors.New("injected gateway error"))
+	mock.MineBlocks(2)
+	if err := ant.WaitForBlockHeight(2, time.Minute, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if mock.Requests("GET /gateway") == 0 {
+		t.Fatal("expected the gateway to be polled for a subscriber")
+	}
+}
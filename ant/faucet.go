@@ -0,0 +1,47 @@
+package ant
+
+import (
+	"time"
+
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// faucetRequestFrequency defines how often an ant waiting for its
+	// balance requests funds from the faucet again.
+	faucetRequestFrequency = time.Second * 10
+)
+
+var (
+	// errNoFaucet is returned when an ant using a faucet requests funds
+	// before the faucet was set.
+	errNoFaucet = errors.New("ant has no faucet")
+)
+
+// Faucet funds ants which don't mine their Siacoins.
+type Faucet interface {
+	// Fund sends Siacoins to the ant's wallet so that its balance, including
+	// unconfirmed incoming Siacoins, reaches the target balance.
+	Fund(a *Ant, target types.Currency) error
+}
+
+// SetFaucet sets the faucet the ant requests funds from if the ant's
+// UseFaucet is set.
+func (a *Ant) SetFaucet(f Faucet) {
+	a.faucetMu.Lock()
+	defer a.faucetMu.Unlock()
+	a.faucet = f
+}
+
+// managedRequestFunds requests funds up to the target balance from the ant's
+// faucet.
+func (a *Ant) managedRequestFunds(target types.Currency) error {
+	a.faucetMu.Lock()
+	f := a.faucet
+	a.faucetMu.Unlock()
+	if f == nil {
+		return errNoFaucet
+	}
+	return f.Fund(a, target)
+}
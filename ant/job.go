@@ -0,0 +1,273 @@
+package ant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// Job defines an ant job. Jobs are registered by name using RegisterJob, so
+// that they can be referenced in ant configs, or started directly using
+// Ant.RunJob. Exported fields of a job implementation are its typed config.
+type Job interface {
+	// Name returns the name of the job.
+	Name() string
+
+	// Run runs the job using the given job runner. The context is cancelled
+	// when the job is stopped or the job runner is stopped, Run is expected
+	// to return soon after. A returned error marks the job as failed.
+	Run(ctx context.Context, j *JobRunner) error
+}
+
+// JobFactory creates a new instance of a job with default config.
+type JobFactory func() Job
+
+// JobConfig defines a job in an ant config. In JSON it is either a job name
+// string, e.g. "renter", or an object with the job name and parameters
+// overriding the job's default config, e.g.
+// {"Name": "autoRenter", "Params": {"UploadFileSize": 1000000}}.
+type JobConfig struct {
+	Name   string
+	Params json.RawMessage `json:",omitempty"`
+}
+
+// jobRegistry contains the factories of all registered jobs.
+var jobRegistry = struct {
+	factories map[string]JobFactory
+	mu        sync.Mutex
+}{
+	factories: make(map[string]JobFactory),
+}
+
+// Built-in job types
+type (
+	// GenericJob unlocks the wallet and waits for ants to sync.
+	GenericJob struct{}
+
+	// MinerJob mines blocks indefinitely.
+	MinerJob struct{}
+
+	// HostJob mines some currency and starts a host offering storage to the
+	// antfarm.
+	HostJob struct {
+		// DesiredBalanceSiacoins defines how many Siacoins the host mines
+		// before it starts hosting.
+		DesiredBalanceSiacoins uint64
+
+		// StorageFolderSize defines the size of the host's storage folder in
+		// bytes.
+		StorageFolderSize uint64
+	}
+
+	// RenterAntJob prepares the renter up to the job's phase: the
+	// noAllowanceRenter job waits for a sufficiently full wallet, the renter
+	// job additionally sets the allowance and waits for the renter to be
+	// upload ready, the autoRenter job additionally starts periodic uploads,
+	// downloads and deletes.
+	RenterAntJob struct {
+		staticName  string
+		staticPhase renterPreparationPhase
+
+		RenterParams
+	}
+
+	// GatewayJob checks the gateway connectability.
+	GatewayJob struct{}
+
+	// BigSpenderJob periodically sends large transactions.
+	BigSpenderJob struct {
+		// SpendIntervalSeconds defines how frequently a transaction is sent.
+		SpendIntervalSeconds uint64
+
+		// SpendThresholdSiacoins defines the amount of Siacoins sent in a
+		// transaction when the wallet balance allows it.
+		SpendThresholdSiacoins uint64
+	}
+
+	// LittleSupplierJob periodically sends small transactions to
+	// SendAddress. The job fails without SendAddress, the antfarm starts it
+	// with the address of its bigspender ant.
+	LittleSupplierJob struct {
+		SendAddress types.UnlockHash
+	}
+)
+
+// init registers the built-in jobs.
+func init() {
+	RegisterJob("generic", func() Job { return &GenericJob{} })
+	RegisterJob("miner", func() Job { return &MinerJob{} })
+	RegisterJob("host", func() Job {
+		return &HostJob{
+			DesiredBalanceSiacoins: hostDesiredBalance,
+			StorageFolderSize:      hostStorageFolderSize,
+		}
+	})
+	RegisterJob("noAllowanceRenter", func() Job {
+		return &RenterAntJob{staticName: "noAllowanceRenter", staticPhase: walletFull, RenterParams: DefaultRenterParams()}
+	})
+	RegisterJob("renter", func() Job {
+		return &RenterAntJob{staticName: "renter", staticPhase: allowanceSet, RenterParams: DefaultRenterParams()}
+	})
+	RegisterJob("autoRenter", func() Job {
+		return &RenterAntJob{staticName: "autoRenter", staticPhase: backgroundJobsStarted, RenterParams: DefaultRenterParams()}
+	})
+	RegisterJob("gateway", func() Job { return &GatewayJob{} })
+	RegisterJob("bigspender", func() Job {
+		return &BigSpenderJob{
+			SpendIntervalSeconds:   spendIntervalSeconds,
+			SpendThresholdSiacoins: spendThresholdSiacoins,
+		}
+	})
+	RegisterJob("littlesupplier", func() Job { return &LittleSupplierJob{} })
+}
+
+// RegisterJob registers a job factory under the given job name, so that the
+// job can be used in ant configs. It is intended to be called from init
+// functions and panics if a job with the same name is already registered.
+func RegisterJob(name string, factory JobFactory) {
+	jobRegistry.mu.Lock()
+	defer jobRegistry.mu.Unlock()
+	if factory == nil {
+		panic("ant: job factory for " + name + " is nil")
+	}
+	if _, ok := jobRegistry.factories[name]; ok {
+		panic("ant: job " + name + " is already registered")
+	}
+	jobRegistry.factories[name] = factory
+}
+
+// NewJob creates a new instance of the registered job with the given name.
+func NewJob(name string) (Job, error) {
+	jobRegistry.mu.Lock()
+	factory, ok := jobRegistry.factories[name]
+	jobRegistry.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no such job: %v", name)
+	}
+	return factory(), nil
+}
+
+// NewJobFromConfig creates a new instance of the registered job defined by
+// the given job config, with the config's parameters applied.
+func NewJobFromConfig(jc JobConfig) (Job, error) {
+	job, err := NewJob(jc.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(jc.Params) == 0 {
+		return job, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(jc.Params))
+	d.DisallowUnknownFields()
+	if err := d.Decode(job); err != nil {
+		return nil, errors.AddContext(err, fmt.Sprintf("invalid parameters of job %v", jc.Name))
+	}
+	return job, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts both a job name string
+// and a job config object.
+func (jc *JobConfig) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*jc = JobConfig{Name: name}
+		return nil
+	}
+	type jobConfig JobConfig
+	var c jobConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return errors.AddContext(err, "job config must be a job name or an object")
+	}
+	*jc = JobConfig(c)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. A job config without parameters is
+// marshalled as the job name string.
+func (jc JobConfig) MarshalJSON() ([]byte, error) {
+	if len(jc.Params) == 0 {
+		return json.Marshal(jc.Name)
+	}
+	type jobConfig JobConfig
+	return json.Marshal(jobConfig(jc))
+}
+
+// RegisteredJobs returns the sorted names of all registered jobs.
+func RegisteredJobs() []string {
+	jobRegistry.mu.Lock()
+	defer jobRegistry.mu.Unlock()
+	var names []string
+	for name := range jobRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Name implements Job.
+func (GenericJob) Name() string { return "generic" }
+
+// Run implements Job.
+func (GenericJob) Run(ctx context.Context, j *JobRunner) error { return j.jobGeneric(ctx) }
+
+// Name implements Job.
+func (MinerJob) Name() string { return "miner" }
+
+// Run implements Job.
+func (MinerJob) Run(ctx context.Context, j *JobRunner) error { return j.blockMining(ctx) }
+
+// Name implements Job.
+func (HostJob) Name() string { return "host" }
+
+// Run implements Job.
+func (hj HostJob) Run(ctx context.Context, j *JobRunner) error {
+	return j.jobHost(ctx, types.SiacoinPrecision.Mul64(hj.DesiredBalanceSiacoins), hj.StorageFolderSize)
+}
+
+// Name implements Job.
+func (rj RenterAntJob) Name() string { return rj.staticName }
+
+// Run implements Job.
+func (rj RenterAntJob) Run(ctx context.Context, j *JobRunner) error {
+	return j.renter(ctx, rj.staticPhase, rj.RenterParams)
+}
+
+// Name implements Job.
+func (GatewayJob) Name() string { return "gateway" }
+
+// Run implements Job.
+func (GatewayJob) Run(ctx context.Context, j *JobRunner) error { return j.gatewayConnectability(ctx) }
+
+// Name implements Job.
+func (BigSpenderJob) Name() string { return "bigspender" }
+
+// Run implements Job.
+func (bj BigSpenderJob) Run(ctx context.Context, j *JobRunner) error {
+	return j.bigSpender(ctx, time.Duration(bj.SpendIntervalSeconds)*time.Second, types.SiacoinPrecision.Mul64(bj.SpendThresholdSiacoins))
+}
+
+// Name implements Job.
+func (LittleSupplierJob) Name() string { return "littlesupplier" }
+
+// startedByAntfarm returns true if the job is a littlesupplier job without a
+// send address. The antfarm starts such a job with the address of its
+// bigspender ant, so the ant doesn't start it from its job config.
+func startedByAntfarm(job Job) bool {
+	lj, ok := job.(*LittleSupplierJob)
+	return ok && lj.SendAddress == (types.UnlockHash{})
+}
+
+// Run implements Job.
+func (lj LittleSupplierJob) Run(ctx context.Context, j *JobRunner) error {
+	if lj.SendAddress == (types.UnlockHash{}) {
+		return errors.New("littlesupplier job requires a send address")
+	}
+	return j.littleSupplier(ctx, lj.SendAddress)
+}
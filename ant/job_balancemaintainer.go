@@ -1,9 +1,11 @@
 package ant
 
 import (
+	"context"
 	"time"
 
 	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
 )
 
 const (
@@ -16,9 +18,85 @@ const (
 	balanceMaintainerErrorSleepDuration = time.Second * 5
 )
 
+// DesiredCurrency returns the ant's desired Siacoin balance.
+func (a *Ant) DesiredCurrency() uint64 {
+	a.balanceMaintainerMu.Lock()
+	defer a.balanceMaintainerMu.Unlock()
+	return a.Config.DesiredCurrency
+}
+
+// SetDesiredCurrency changes the ant's desired Siacoin balance maintained by
+// mining or by the faucet. The running balance maintainer is replaced, 0 stops
+// maintaining the balance and stops the miner.
+func (a *Ant) SetDesiredCurrency(desiredCurrency uint64) error {
+	jr := a.JobRunner()
+	if jr == nil {
+		return errors.New("ant is not running")
+	}
+	a.balanceMaintainerMu.Lock()
+	defer a.balanceMaintainerMu.Unlock()
+	wasRunning := a.stopBalanceMaintainer()
+	a.Config.DesiredCurrency = desiredCurrency
+	if desiredCurrency == 0 {
+		if wasRunning && !a.Config.UseFaucet {
+			if err := jr.staticClient.MinerStopGet(); err != nil {
+				return errors.AddContext(err, "can't stop miner")
+			}
+		}
+		return nil
+	}
+	a.startBalanceMaintainer(jr)
+	return nil
+}
+
+// managedStartBalanceMaintainer starts the balance maintainer on the given job
+// runner if the ant has a desired currency set. A balance maintainer left from
+// the previous job runner is stopped first.
+func (a *Ant) managedStartBalanceMaintainer(jr *JobRunner) {
+	a.balanceMaintainerMu.Lock()
+	defer a.balanceMaintainerMu.Unlock()
+	a.stopBalanceMaintainer()
+	if a.Config.DesiredCurrency > 0 {
+		a.startBalanceMaintainer(jr)
+	}
+}
+
+// startBalanceMaintainer starts the balance maintainer for the ant's desired
+// currency on the given job runner. It must be called under
+// balanceMaintainerMu.
+func (a *Ant) startBalanceMaintainer(jr *JobRunner) {
+	ctx, cancel := context.WithCancel(jr.StaticTG.StopCtx())
+	done := make(chan struct{})
+	a.balanceMaintainerCancel, a.balanceMaintainerDone = cancel, done
+	desiredBalance := types.SiacoinPrecision.Mul64(a.Config.DesiredCurrency)
+	useFaucet := a.Config.UseFaucet
+	go func() {
+		defer close(done)
+		if useFaucet {
+			jr.faucetBalanceMaintainer(ctx, desiredBalance)
+			return
+		}
+		jr.balanceMaintainer(ctx, desiredBalance)
+	}()
+}
+
+// stopBalanceMaintainer stops the running balance maintainer and waits until
+// it returns. It returns false if the balance maintainer wasn't running. It
+// must be called under balanceMaintainerMu.
+func (a *Ant) stopBalanceMaintainer() bool {
+	if a.balanceMaintainerCancel == nil {
+		return false
+	}
+	a.balanceMaintainerCancel()
+	<-a.balanceMaintainerDone
+	a.balanceMaintainerCancel, a.balanceMaintainerDone = nil, nil
+	return true
+}
+
 // balanceMaintainer mines when the balance is below desiredBalance. The miner
-// is stopped if the balance exceeds the desired balance.
-func (j *JobRunner) balanceMaintainer(desiredBalance types.Currency) {
+// is stopped if the balance exceeds the desired balance. The balance
+// maintainer runs until the context is done.
+func (j *JobRunner) balanceMaintainer(ctx context.Context, desiredBalance types.Currency) {
 	err := j.StaticTG.Add()
 	if err != nil {
 		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
@@ -45,7 +123,7 @@ func (j *JobRunner) balanceMaintainer(desiredBalance types.Currency) {
 		if err != nil {
 			j.staticLogger.Errorf("%v: can't get wallet info: %v", j.staticDataDir, err)
 			select {
-			case <-j.StaticTG.StopChan():
+			case <-ctx.Done():
 				return
 			case <-time.After(balanceMaintainerErrorSleepDuration):
 			}
@@ -59,7 +137,7 @@ func (j *JobRunner) balanceMaintainer(desiredBalance types.Currency) {
 			if err = j.staticClient.MinerStartGet(); err != nil {
 				j.staticLogger.Errorf("%v: can't start miner: %v", j.staticDataDir, err)
 				select {
-				case <-j.StaticTG.StopChan():
+				case <-ctx.Done():
 					return
 				case <-time.After(balanceMaintainerErrorSleepDuration):
 				}
@@ -71,7 +149,7 @@ func (j *JobRunner) balanceMaintainer(desiredBalance types.Currency) {
 			if err = j.staticClient.MinerStopGet(); err != nil {
 				j.staticLogger.Errorf("%v: can't stop miner: %v", j.staticDataDir, err)
 				select {
-				case <-j.StaticTG.StopChan():
+				case <-ctx.Done():
 					return
 				case <-time.After(balanceMaintainerErrorSleepDuration):
 				}
@@ -80,7 +158,40 @@ func (j *JobRunner) balanceMaintainer(desiredBalance types.Currency) {
 		}
 
 		select {
-		case <-j.StaticTG.StopChan():
+		case <-ctx.Done():
+			return
+		case <-time.After(walletBalanceCheckInterval):
+		}
+	}
+}
+
+// faucetBalanceMaintainer requests funds from the ant's faucet when the
+// balance is below desiredBalance. The faucet balance maintainer runs until
+// the context is done.
+func (j *JobRunner) faucetBalanceMaintainer(ctx context.Context, desiredBalance types.Currency) {
+	err := j.StaticTG.Add()
+	if err != nil {
+		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
+		return
+	}
+	defer j.StaticTG.Done()
+
+	// Every walletBalanceCheckInterval, check if the balance has reached the
+	// desiredBalance. If it hasn't, request the missing funds from the faucet.
+	// The faucet takes unconfirmed incoming funds into account, so a pending
+	// transfer is not requested twice.
+	for {
+		walletInfo, err := j.staticClient.WalletGet()
+		if err != nil {
+			j.staticLogger.Errorf("%v: can't get wallet info: %v", j.staticDataDir, err)
+		} else if walletInfo.ConfirmedSiacoinBalance.Cmp(desiredBalance) < 0 {
+			if err := j.staticAnt.managedRequestFunds(desiredBalance); err != nil {
+				j.staticLogger.Errorf("%v: can't request funds from faucet: %v", j.staticDataDir, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
 			return
 		case <-time.After(walletBalanceCheckInterval):
 		}
@@ -1,6 +1,8 @@
 package ant
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
@@ -12,19 +14,10 @@ const (
 
 // gatewayConnectability will print an error to the log if the node has zero
 // peers at any time.
-func (j *JobRunner) gatewayConnectability() {
-	err := j.StaticTG.Add()
-	if err != nil {
-		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
-		return
-	}
-	defer j.StaticTG.Done()
-
+func (j *JobRunner) gatewayConnectability(ctx context.Context) error {
 	// Wait for ants to be synced if the wait group was set
-	synced := j.waitForAntsSync()
-	if !synced {
-		j.staticLogger.Errorf("%v: waiting for ants to sync failed", j.staticDataDir)
-		return
+	if err := j.waitForAntsSync(ctx); err != nil {
+		return err
 	}
 
 	// Check the gateway connections in a loop
@@ -32,8 +25,8 @@ func (j *JobRunner) gatewayConnectability() {
 		// Start with a sleep to allow other ants to start up before the first
 		// check. This also eliminates the need for an error sleep.
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(gatewayConnectabilityCheckInterval):
 		}
 
@@ -46,8 +39,11 @@ func (j *JobRunner) gatewayConnectability() {
 			continue
 		}
 		if len(gatewayInfo.Peers) < 2 {
-			j.staticLogger.Errorf("%v: ant has less than two peers: %v", j.staticDataDir, gatewayInfo.Peers)
+			er := fmt.Errorf("ant has less than two peers: %v", gatewayInfo.Peers)
+			j.staticLogger.Errorf("%v: %v", j.staticDataDir, er)
+			j.recordError(ctx, MetricGatewayChecksFailed, er)
 			continue
 		}
+		j.staticMetrics.IncCounter(MetricGatewayChecksSucceeded)
 	}
 }
@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -37,11 +38,19 @@ const (
 	// miningCheckFrequency defines how often the host will check for desired
 	// balance during mining
 	miningCheckFrequency = time.Second
+
+	// hostDesiredBalance defines the default amount of Siacoins the host
+	// mines before it starts hosting.
+	hostDesiredBalance = 50000
 )
 
 var (
 	// errAntStopped defines a reusable error when ant was stopped
 	errAntStopped = errors.New("ant was stopped")
+
+	// hostStorageFolderSize defines the default size of the host's storage
+	// folder.
+	hostStorageFolderSize = modules.SectorSize * 4096
 )
 
 // hostJobRunner extends generic jobRunner with host specific fields.
@@ -54,30 +63,24 @@ type hostJobRunner struct {
 	staticHostNetAddress modules.NetAddress
 }
 
-// jobHost unlocks the wallet, mines some currency, and starts a host offering
-// storage to the ant farm.
-func (j *JobRunner) jobHost() {
-	err := j.StaticTG.Add()
-	if err != nil {
-		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
-		return
-	}
-	defer j.StaticTG.Done()
-
+// jobHost unlocks the wallet, mines desiredBalance currency (or requests it
+// from the faucet), and starts a host offering storage of storageFolderSize
+// bytes to the ant farm.
+func (j *JobRunner) jobHost(ctx context.Context, desiredBalance types.Currency, storageFolderSize uint64) error {
 	// Wait for ants to be synced if the wait group was set
-	synced := j.waitForAntsSync()
-	if !synced {
-		j.staticLogger.Errorf("%v: waiting for ants to sync failed", j.staticDataDir)
-		return
+	if err := j.waitForAntsSync(ctx); err != nil {
+		return err
 	}
 
-	// Mine at least 50,000 SC
-	desiredbalance := types.NewCurrency64(50000).Mul(types.SiacoinPrecision)
+	// Mine at least the desired balance, or request it from the faucet if
+	// the ant uses a faucet
+	useFaucet := j.staticAnt.Config.UseFaucet
 	start := time.Now()
+	var lastRequest time.Time
 	for {
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(miningCheckFrequency):
 		}
 		walletInfo, err := j.staticClient.WalletGet()
@@ -85,12 +88,25 @@ func (j *JobRunner) jobHost() {
 			j.staticLogger.Errorf("%v: error getting wallet info: %v", j.staticDataDir, err)
 			continue
 		}
-		if walletInfo.ConfirmedSiacoinBalance.Cmp(desiredbalance) > 0 {
+		if walletInfo.ConfirmedSiacoinBalance.Cmp(desiredBalance) > 0 {
 			break
 		}
+		if useFaucet && time.Since(lastRequest) > faucetRequestFrequency {
+			// The faucet funds up to the target, request a siacoin more
+			// than the desired balance to exceed it
+			lastRequest = time.Now()
+			target := desiredBalance.Add(types.SiacoinPrecision)
+			if err := j.staticAnt.managedRequestFunds(target); err != nil {
+				j.staticLogger.Errorf("%v: can't request funds from faucet: %v", j.staticDataDir, err)
+			}
+		}
 		if time.Since(start) > miningTimeout {
-			j.staticLogger.Errorf("%v: could not mine enough currency within %v timeout", j.staticDataDir, miningTimeout)
-			return
+			er := fmt.Errorf("could not mine enough currency within %v timeout", miningTimeout)
+			if useFaucet {
+				er = fmt.Errorf("could not get enough currency from faucet within %v timeout", miningTimeout)
+			}
+			j.recordError(ctx, MetricHostInitialBalanceFailed, er)
+			return er
 		}
 	}
 
@@ -99,29 +115,24 @@ func (j *JobRunner) jobHost() {
 	// jobHost after the ant upgrade.
 	hostdir, err := filepath.Abs(filepath.Join(j.staticDataDir, "hostdata"))
 	if err != nil {
-		j.staticLogger.Errorf("%v: can't get hostdata directory absolute path: %v", j.staticDataDir, err)
-		return
+		return errors.AddContext(err, "can't get hostdata directory absolute path")
 	}
 	_, err = os.Stat(hostdir)
 	if err != nil && !os.IsNotExist(err) {
-		j.staticLogger.Errorf("%v: can't get hostdata directory info: %v", j.staticDataDir, err)
-		return
+		return errors.AddContext(err, "can't get hostdata directory info")
 	}
 	// Folder doesn't exist
 	if os.IsNotExist(err) {
 		// Create a temporary folder for hosting
 		err = os.MkdirAll(hostdir, 0700)
 		if err != nil {
-			j.staticLogger.Errorf("%v: can't create hostdata directory: %v", j.staticDataDir, err)
-			return
+			return errors.AddContext(err, "can't create hostdata directory")
 		}
 
 		// Add the storage folder.
-		size := modules.SectorSize * 4096
-		err = j.staticClient.HostStorageFoldersAddPost(hostdir, size)
+		err = j.staticClient.HostStorageFoldersAddPost(hostdir, storageFolderSize)
 		if err != nil {
-			j.staticLogger.Errorf("%v: can't add storage folder: %v", j.staticDataDir, err)
-			return
+			return errors.AddContext(err, "can't add storage folder")
 		}
 	}
 
@@ -129,8 +140,7 @@ func (j *JobRunner) jobHost() {
 	j.staticLogger.Debugf("%v: accept contracts", j.staticDataDir)
 	err = j.staticClient.HostModifySettingPost(client.HostParamAcceptingContracts, true)
 	if err != nil {
-		j.staticLogger.Errorf("%v: can't accept contracts: %v", j.staticDataDir, err)
-		return
+		return errors.AddContext(err, "can't accept contracts")
 	}
 
 	// Announce host to the network, check periodically that host announcement
@@ -138,14 +148,13 @@ func (j *JobRunner) jobHost() {
 	// storage revenue doesn't decrease.
 	hjr, err := j.newHostJobRunner()
 	if err != nil {
-		j.staticLogger.Errorf("%v: can't create host job runner: %v", j.staticDataDir, err)
-		return
+		return errors.AddContext(err, "can't create host job runner")
 	}
 	for {
 		// Return immediately when closing ant
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		default:
 		}
 
@@ -156,19 +165,22 @@ func (j *JobRunner) jobHost() {
 			err := j.staticClient.HostAnnouncePost()
 			if err != nil {
 				j.staticLogger.Errorf("%v: host announcement failed: %v", j.staticDataDir, err)
+				j.recordError(ctx, MetricAnnouncementsFailed, err)
 				select {
-				case <-j.StaticTG.StopChan():
-					return
+				case <-ctx.Done():
+					return nil
 				case <-time.After(hostAPIErrorFrequency):
 					continue
 				}
 			}
 			hjr.managedSetAnnounced(true)
+			j.staticMetrics.IncCounter(MetricAnnouncements)
 
 			// Wait till host announcement transaction is in blockchain
-			err = hjr.managedWaitAnnounceTransactionInBlockchain()
+			err = hjr.managedWaitAnnounceTransactionInBlockchain(ctx)
 			if err != nil {
 				j.staticLogger.Errorf("%v: waiting for host announcement transaction failed: %v", j.staticDataDir, err)
+				j.staticMetrics.IncCounter(MetricAnnouncementsRetried)
 				hjr.managedSetAnnounced(false)
 				continue
 			}
@@ -179,14 +191,15 @@ func (j *JobRunner) jobHost() {
 		if err != nil {
 			j.staticLogger.Errorf("%v: checking host announcement transaction failed: %v", j.staticDataDir, err)
 			select {
-			case <-j.StaticTG.StopChan():
-				return
+			case <-ctx.Done():
+				return nil
 			case <-time.After(hostAPIErrorFrequency):
 				continue
 			}
 		}
 		if !found {
 			j.staticLogger.Debugf("%v: host announcement transaction was not found, it was probably re-orged", j.staticDataDir)
+			j.staticMetrics.IncCounter(MetricAnnouncementsRetried)
 			hjr.managedSetAnnounced(false)
 			continue
 		}
@@ -196,16 +209,16 @@ func (j *JobRunner) jobHost() {
 		if err != nil {
 			j.staticLogger.Errorf("%v: checking storage revenue failed: %v", j.staticDataDir, err)
 			select {
-			case <-j.StaticTG.StopChan():
-				return
+			case <-ctx.Done():
+				return nil
 			case <-time.After(hostAPIErrorFrequency):
 				continue
 			}
 		}
 
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(hostLoopFrequency):
 		}
 	}
@@ -299,6 +312,7 @@ func (hjr *hostJobRunner) managedCheckStorageRevenueNotDecreased() error {
 	if hostInfo.FinancialMetrics.StorageRevenue.Cmp(r) < 0 {
 		// Storage revenue has decreased!
 		hjr.staticLogger.Errorf("%v: storage revenue decreased! Was %v, is now %v", hjr.staticDataDir, hjr.lastStorageRevenue, hostInfo.FinancialMetrics.StorageRevenue)
+		hjr.staticMetrics.IncCounter(MetricStorageRevenueDecreased)
 	}
 
 	// Update previous revenue to new amount
@@ -318,7 +332,7 @@ func (hjr *hostJobRunner) managedSetAnnounced(announced bool) {
 
 // managedWaitAnnounceTransactionInBlockchain blocks till host announcement
 // transaction appears in the blockchain
-func (hjr *hostJobRunner) managedWaitAnnounceTransactionInBlockchain() error {
+func (hjr *hostJobRunner) managedWaitAnnounceTransactionInBlockchain(ctx context.Context) error {
 	var startBH types.BlockHeight
 	for {
 		// Get latest block height
@@ -337,7 +351,7 @@ func (hjr *hostJobRunner) managedWaitAnnounceTransactionInBlockchain() error {
 		found, err := hjr.managedAnnouncementTransactionInBlockRange(types.BlockHeight(0), currentBH)
 		if err != nil {
 			select {
-			case <-hjr.StaticTG.StopChan():
+			case <-ctx.Done():
 				return errAntStopped
 			case <-time.After(hostAPIErrorFrequency):
 				continue
@@ -357,7 +371,7 @@ func (hjr *hostJobRunner) managedWaitAnnounceTransactionInBlockchain() error {
 
 		// Wait for next iteration
 		select {
-		case <-hjr.StaticTG.StopChan():
+		case <-ctx.Done():
 			return errAntStopped
 		case <-time.After(hostTransactionCheckFrequency):
 			continue
@@ -1,6 +1,8 @@
 package ant
 
 import (
+	"context"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -8,6 +10,7 @@ import (
 	"go.sia.tech/sia-antfarm/test"
 	"go.sia.tech/siad/node/api/client"
 	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
 )
 
 // TestAnnounceHost tests host announcement, host job runner and its methods.
@@ -33,11 +36,11 @@ func TestAnnounceHost(t *testing.T) {
 	}()
 
 	// Create siad process
-	siad, err := newSiad(logger, config)
+	siad, err := newSiad(context.Background(), logger, config)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad.Process)
+	defer stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad)
 
 	// Create ant client
 	c, err := newClient(config.APIAddr, config.APIPassword)
@@ -67,7 +70,7 @@ func TestAnnounceHost(t *testing.T) {
 	// Keep mining so that host announcement gets to blockchain.
 	initialbalance := types.NewCurrency64(50e3).Mul(types.SiacoinPrecision)
 	desidedBalance := types.NewCurrency64(5e9).Mul(types.SiacoinPrecision)
-	go j.balanceMaintainer(desidedBalance)
+	go j.balanceMaintainer(j.StaticTG.StopCtx(), desidedBalance)
 	start := time.Now()
 	for {
 		select {
@@ -123,7 +126,7 @@ func TestAnnounceHost(t *testing.T) {
 
 	// Wait for host announcement transaction in blockchain.
 	// Test waitAnnounceTransactionInBlockchain().
-	err = hjr.managedWaitAnnounceTransactionInBlockchain()
+	err = hjr.managedWaitAnnounceTransactionInBlockchain(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -152,3 +155,96 @@ func TestAnnounceHost(t *testing.T) {
 		t.Fatal("host announcement was not found in the specific block")
 	}
 }
+
+// TestAnnounceHostReorg tests that the host job runner doesn't find a host
+// announcement transaction which was re-orged, using a mock siad.
+func TestAnnounceHostReorg(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a mock siad
+	dataDir := test.TestDir(t.Name())
+	mock, config := newMockSiadConfig(t, dataDir)
+	defer func() {
+		if err := mock.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create ant client
+	c, err := newClient(config.APIAddr, config.APIPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create ant
+	ant := &Ant{
+		staticAntsSyncWG: &sync.WaitGroup{},
+		staticLogger:     logger,
+		StaticClient:     c,
+	}
+
+	// Create jobRunnner on the mock siad
+	j, err := newJobRunner(logger, ant, config.DataDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := j.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set netAddress and create hostJobRunner
+	err = j.staticClient.HostModifySettingPost(client.HostParamNetAddress, "127.0.0.1:9982")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hjr, err := j.newHostJobRunner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Announce host and mine the announcement transaction
+	err = hjr.staticClient.HostAnnouncePost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.MineBlocks(2)
+	found, err := hjr.managedAnnouncementTransactionInBlockRange(types.BlockHeight(0), types.BlockHeight(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || hjr.managedAnnouncedBlockHeight() != 1 {
+		t.Fatal("host announcement was not found in the first mined block")
+	}
+
+	// Re-org the announcement transaction
+	if err := mock.Reorg(2); err != nil {
+		t.Fatal(err)
+	}
+	found, err = hjr.announcementTransactionInBlock(hjr.managedAnnouncedBlockHeight())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Fatal("re-orged host announcement should not be found")
+	}
+
+	// Checking the announcement fails with an injected error
+	mock.SetError("GET /consensus/blocks", errors.New("injected consensus error"))
+	_, err = hjr.announcementTransactionInBlock(hjr.managedAnnouncedBlockHeight())
+	if err == nil || !strings.Contains(err.Error(), "injected consensus error") {
+		t.Fatalf("expected injected consensus error, got %v", err)
+	}
+}
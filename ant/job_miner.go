@@ -1,9 +1,11 @@
 package ant
 
 import (
+	"context"
 	"time"
 
 	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
 )
 
 const (
@@ -23,28 +25,19 @@ const (
 // blockMining indefinitely mines blocks.  If more than 100
 // seconds passes before the wallet has received some amount of currency, this
 // job will print an error.
-func (j *JobRunner) blockMining() {
-	err := j.StaticTG.Add()
-	if err != nil {
-		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
-		return
-	}
-	defer j.StaticTG.Done()
-
+func (j *JobRunner) blockMining(ctx context.Context) error {
 	// Note:
 	// blockMining doesn't wait for WaitForSync
 
-	err = j.staticClient.MinerStartGet()
+	err := j.staticClient.MinerStartGet()
 	if err != nil {
-		j.staticLogger.Errorf("%v: can't start miner: %v", j.staticDataDir, err)
-		return
+		return errors.AddContext(err, "can't start miner")
 	}
 
 	// Get block frequency and set miner sleep time.
 	cg, err := j.staticClient.ConsensusGet()
 	if err != nil {
-		j.staticLogger.Errorf("%v: can't get consensus info: %v", j.staticDataDir, err)
-		return
+		return errors.AddContext(err, "can't get consensus info")
 	}
 	blockFrequency := cg.BlockFrequency // seconds per block
 	minerSleepTime := time.Second * time.Duration(blockFrequency/2)
@@ -59,8 +52,8 @@ func (j *JobRunner) blockMining() {
 	for {
 		// Wait before check.
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(blockCheckFrequency):
 		}
 
@@ -84,8 +77,8 @@ func (j *JobRunner) blockMining() {
 
 			// Sleep half of the block frequency interval.
 			select {
-			case <-j.StaticTG.StopChan():
-				return
+			case <-ctx.Done():
+				return nil
 			case <-time.After(minerSleepTime):
 			}
 
@@ -106,9 +99,12 @@ func (j *JobRunner) blockMining() {
 			}
 			if walletInfo.ConfirmedSiacoinBalance.Cmp(lastBalance) > 0 {
 				j.staticLogger.Printf("%v: Blockmining job succeeded", j.staticDataDir)
+				j.staticMetrics.IncCounter(MetricMinerBalanceChecksSucceeded)
 				lastBalance = walletInfo.ConfirmedSiacoinBalance
 			} else if time.Since(start) > balanceIncreaseCheckWarmup {
-				j.staticLogger.Errorf("%v: it took too long to receive new funds in miner job", j.staticDataDir)
+				er := errors.New("it took too long to receive new funds in miner job")
+				j.staticLogger.Errorf("%v: %v", j.staticDataDir, er)
+				j.recordError(ctx, MetricMinerBalanceChecksFailed, er)
 			}
 			lastBallanceCheck = time.Now()
 		}
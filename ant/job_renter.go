@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -20,14 +21,9 @@ import (
 	"go.sia.tech/siad/node/api"
 	"go.sia.tech/siad/node/api/client"
 	"go.sia.tech/siad/types"
-	"gitlab.com/NebulousLabs/fastrand"
 )
 
 const (
-	// downloadFileFrequency defines how frequently the renter job downloads
-	// files from the network.
-	downloadFileFrequency = uploadFileFrequency * 3 / 2
-
 	// initialBalanceWarningTimeout defines how long the renter will wait before
 	// reporting to the user that the required initial balance has not been
 	// reached.
@@ -42,39 +38,37 @@ const (
 	// renter allowance
 	setAllowanceFrequency = time.Second * 15
 
-	// uploadFileFrequency defines how frequently the renter job uploads files
-	// to the network.
-	uploadFileFrequency = time.Second * 60
+	// defaultUploadFileFrequencySeconds defines how frequently the renter job
+	// uploads files to the network by default. Files are downloaded 1.5 times
+	// less frequently.
+	defaultUploadFileFrequencySeconds = 60
 
 	// deleteFileFrequency defines how frequently the renter job deletes files
 	// from the network.
 	deleteFileFrequency = time.Minute * 2
 
-	// deleteFileThreshold defines the minimum number of files uploaded before
-	// deletion occurs.
-	deleteFileThreshold = 30
+	// defaultDeleteFileThreshold defines the default minimum number of files
+	// uploaded before deletion occurs.
+	defaultDeleteFileThreshold = 30
 
 	// uploadTimeout defines the maximum time allowed for an upload operation to
 	// complete, ie for an upload to reach 100%.
 	uploadTimeout = time.Minute * 10
 
-	// uploadFileCheckFrequency defines how frequently the renter job checks if
-	// file upload has reached 100%
-	uploadFileCheckFrequency = time.Second
-
-	// uploadFileCheckLogFrequency defines how frequently to log when upload
-	// gets stuck
+	// uploadFileCheckLogFrequency defines how frequently to log while waiting
+	// for an upload to complete
 	uploadFileCheckLogFrequency = time.Second * 20
 
 	// renterAllowancePeriod defines the block duration of the renter's allowance
 	renterAllowancePeriod = 100
 
-	// renterDataPieces defines the number of data pieces per erasure-coded chunk
-	renterDataPieces = 1
+	// defaultRenterDataPieces defines the default number of data pieces per
+	// erasure-coded chunk
+	defaultRenterDataPieces = 1
 
-	// renterParityPieces defines the number of parity pieces per erasure-coded
-	// chunk
-	renterParityPieces = 2
+	// defaultRenterParityPieces defines the default number of parity pieces
+	// per erasure-coded chunk
+	defaultRenterParityPieces = 2
 
 	// renterUploadReadyTimeout defines timeout for renter to become upload
 	// ready
@@ -84,9 +78,9 @@ const (
 	// if renter became upload ready
 	renterUploadReadyFrequency = time.Second * 5
 
-	// uploadFileSize defines the size of the test files to be uploaded.  Test
-	// files are filled with random data.
-	uploadFileSize = 1e8
+	// defaultUploadFileSize defines the default size of the test files to be
+	// uploaded.  Test files are filled with random data.
+	defaultUploadFileSize = 1e8
 
 	// fileAppearInDownloadListTimeout defines timeout of a file to appear in the
 	// download list
@@ -126,8 +120,8 @@ const (
 )
 
 var (
-	// Allowance is the set of allowance settings that will be used by
-	// renter
+	// Allowance is the set of default allowance settings that will be used
+	// by renter
 	Allowance = modules.Allowance{
 		Funds:       types.NewCurrency64(20e3).Mul(types.SiacoinPrecision),
 		Hosts:       4,
@@ -146,6 +140,30 @@ var (
 	requiredInitialBalance = types.NewCurrency64(100e3).Mul(types.SiacoinPrecision)
 )
 
+// RenterParams defines parameters of the renter jobs.
+type RenterParams struct {
+	// Allowance is the renter allowance set by the renter and autoRenter
+	// jobs.
+	Allowance modules.Allowance
+
+	// DataPieces and ParityPieces define the erasure coding of uploaded
+	// files.
+	DataPieces   uint64
+	ParityPieces uint64
+
+	// UploadFileSize defines the size of the files uploaded by the
+	// autoRenter job.
+	UploadFileSize uint64
+
+	// UploadFileFrequencySeconds defines how frequently the autoRenter job
+	// uploads files. Files are downloaded 1.5 times less frequently.
+	UploadFileFrequencySeconds uint64
+
+	// DeleteFileThreshold defines the minimum number of files uploaded by
+	// the autoRenter job before it starts deleting files.
+	DeleteFileThreshold int
+}
+
 // RenterFile stores the location and checksum of a file active on the renter.
 type RenterFile struct {
 	MerkleRoot crypto.Hash
@@ -159,14 +177,22 @@ type RenterJob struct {
 	staticLogger *persist.Logger
 	Files        []RenterFile
 
-	staticJR *JobRunner
-	mu       sync.Mutex
+	staticJR     *JobRunner
+	staticParams RenterParams
+	mu           sync.Mutex
+
+	// staticUploadRand, staticDownloadRand and staticDeleteRand are the
+	// random sources of the uploaded file contents, the downloaded files and
+	// the deleted files.
+	staticUploadRand   *antRand
+	staticDownloadRand *antRand
+	staticDeleteRand   *antRand
 }
 
 // createTempFile creates temporary file in the given temporary sub-directory,
 // with the given filename pattern. The file is filled with random data of the
-// given length.
-func createTempFile(dir, fileNamePattern string, fileSize uint64) (absFilePath string, merkleRoot crypto.Hash, err error) {
+// given length read from the random reader.
+func createTempFile(dir, fileNamePattern string, fileSize uint64, r io.Reader) (absFilePath string, merkleRoot crypto.Hash, err error) {
 	err = os.MkdirAll(dir, 0700)
 	if err != nil {
 		return "", crypto.Hash{}, fmt.Errorf("error creating an upload directory: %v", err)
@@ -185,7 +211,7 @@ func createTempFile(dir, fileNamePattern string, fileSize uint64) (absFilePath s
 	}
 
 	// Fill the file with random data.
-	merkleRoot, err = randFillFile(f, fileSize)
+	merkleRoot, err = randFillFile(f, r, fileSize)
 	if err != nil {
 		return "", crypto.Hash{}, fmt.Errorf("error filling file with random data: %v", err)
 	}
@@ -194,14 +220,49 @@ func createTempFile(dir, fileNamePattern string, fileSize uint64) (absFilePath s
 }
 
 // downloadFile is a helper function to download the given file from the
-// network to the given path.
-func downloadFile(r *RenterJob, fileToDownload modules.FileInfo, destPath string) error {
+// network to the given path. Waiting for the download is aborted when the
+// context is done.
+func downloadFile(ctx context.Context, r *RenterJob, fileToDownload modules.FileInfo, destPath string) (err error) {
 	siaPath := fileToDownload.SiaPath
-	destPath, err := filepath.Abs(destPath)
+	destPath, err = filepath.Abs(destPath)
 	if err != nil {
 		return fmt.Errorf("error getting absolute path from %v: %v", destPath, err)
 	}
+
+	// Register the in-flight download, so that draining the ant waits for it
+	if err := r.staticJR.staticAnt.managedStartTransfer(); err != nil {
+		return err
+	}
+	defer r.staticJR.staticAnt.managedFinishTransfer()
+
 	fromTime := time.Now()
+
+	// Collect download metrics. A download interrupted by stopping the ant
+	// or by cancelling the context is neither completed nor failed.
+	metrics := r.staticJR.staticMetrics
+	metrics.IncCounter(MetricDownloadsStarted)
+	var completed bool
+	defer func() {
+		if (err == nil && !completed) || errors.Contains(err, context.Canceled) {
+			return
+		}
+		transfer := TransferRecord{
+			Type:     TransferDownload,
+			SiaPath:  siaPath.String(),
+			Size:     fileToDownload.Filesize,
+			Start:    fromTime,
+			Duration: time.Since(fromTime),
+		}
+		if err != nil {
+			transfer.Error = err.Error()
+			metrics.IncCounter(MetricDownloadsFailed)
+		} else {
+			metrics.IncCounter(MetricDownloadsCompleted)
+			metrics.ObserveDuration(MetricDownloadDuration, transfer.Duration)
+		}
+		metrics.RecordTransfer(transfer)
+	}()
+
 	r.staticLogger.Debugf("%v: downloading\n\tsiaFile: %v\n\tto local file: %v", r.staticJR.staticDataDir, siaPath, destPath)
 	_, err = r.staticJR.staticClient.RenterDownloadGet(siaPath, destPath, 0, fileToDownload.Filesize, true, true, false)
 	if err != nil {
@@ -214,6 +275,8 @@ func downloadFile(r *RenterJob, fileToDownload modules.FileInfo, destPath string
 		select {
 		case <-r.staticJR.StaticTG.StopChan():
 			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-time.After(fileApearInDownloadListFrequency):
 		}
 
@@ -235,6 +298,8 @@ func downloadFile(r *RenterJob, fileToDownload modules.FileInfo, destPath string
 		select {
 		case <-r.staticJR.StaticTG.StopChan():
 			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-time.After(downloadFileCheckFrequency):
 		}
 
@@ -291,6 +356,7 @@ func downloadFile(r *RenterJob, fileToDownload modules.FileInfo, destPath string
 	}
 
 	r.staticLogger.Printf("%v: successfully downloaded\n\tsiaFile: %v\n\tto local file: %v\n\tdownload completed in: %v", r.staticJR.staticDataDir, siaPath, destPath, time.Since(start))
+	completed = true
 	return nil
 }
 
@@ -328,39 +394,59 @@ func MerkleRoot(r io.Reader) (h crypto.Hash, err error) {
 	return
 }
 
-// randFillFile will append 'size' bytes to the input file, returning the
-// merkle root of the bytes that were appended.
-func randFillFile(f *os.File, size uint64) (h crypto.Hash, err error) {
-	tee := io.TeeReader(io.LimitReader(fastrand.Reader, int64(size)), f)
+// randFillFile will append 'size' bytes read from the random reader to the
+// input file, returning the merkle root of the bytes that were appended.
+func randFillFile(f *os.File, r io.Reader, size uint64) (h crypto.Hash, err error) {
+	tee := io.TeeReader(io.LimitReader(r, int64(size)), f)
 	h, err = MerkleRoot(tee)
 	return
 }
 
-// NewRenterJob returns new renter job
+// NewRenterJob returns new renter job using the parameters of the ant's renter
+// job config. The job uses the ant's random source.
 func (j *JobRunner) NewRenterJob() RenterJob {
+	ar := j.staticAnt.staticRand
+	return j.newRenterJob(j.staticAnt.renterParams(), ar, ar, ar)
+}
+
+// newRenterJob returns new renter job using the given parameters and random
+// sources of the uploads, downloads and deletions.
+func (j *JobRunner) newRenterJob(params RenterParams, uploadRand, downloadRand, deleteRand *antRand) RenterJob {
 	return RenterJob{
-		staticLogger: j.staticLogger,
-		staticJR:     j,
+		staticLogger:       j.staticLogger,
+		staticJR:           j,
+		staticParams:       params,
+		staticUploadRand:   uploadRand,
+		staticDownloadRand: downloadRand,
+		staticDeleteRand:   deleteRand,
+	}
+}
+
+// DefaultRenterParams returns the default parameters of the renter jobs.
+func DefaultRenterParams() RenterParams {
+	return RenterParams{
+		Allowance:                  Allowance,
+		DataPieces:                 defaultRenterDataPieces,
+		ParityPieces:               defaultRenterParityPieces,
+		UploadFileSize:             defaultUploadFileSize,
+		UploadFileFrequencySeconds: defaultUploadFileFrequencySeconds,
+		DeleteFileThreshold:        defaultDeleteFileThreshold,
 	}
 }
 
+// uploadFileFrequency returns the upload file frequency as a duration.
+func (p RenterParams) uploadFileFrequency() time.Duration {
+	return time.Duration(p.UploadFileFrequencySeconds) * time.Second
+}
+
 // renter blocks until renter reaches the desired state defined in phase.
 // Either to have a sufficiently full wallet; to set the allowance and renter
 // to become upload ready; or to start periodic uploader, downloader and
-// deleter jobs.
-func (j *JobRunner) renter(phase renterPreparationPhase) {
-	err := j.StaticTG.Add()
-	if err != nil {
-		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
-		return
-	}
-	defer j.StaticTG.Done()
-
+// deleter jobs. The renter uses the given renter job parameters.
+func (j *JobRunner) renter(ctx context.Context, phase renterPreparationPhase, params RenterParams) error {
 	// Wait for ants to be synced
-	synced := j.waitForAntsSync()
-	if !synced {
-		j.staticLogger.Errorf("%v: waiting for ants to sync failed", j.staticDataDir)
-		return
+	if err := j.waitForAntsSync(ctx); err != nil {
+		return err
 	}
 
 	// Block until a minimum threshold of coins have been mined.
@@ -384,22 +470,22 @@ func (j *JobRunner) renter(phase renterPreparationPhase) {
 
 		// Wait before trying to get the balance again.
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(balanceCheckFrequency):
 		}
 	}
 	j.staticLogger.Debugf("%v: wallet filled successfully.", j.staticDataDir)
 
 	if phase == walletFull {
-		return
+		return nil
 	}
 
 	// Block until a renter allowance has successfully been set.
 	start = time.Now()
 	for {
 		j.staticLogger.Debugf("%v: attempting to set allowance.", j.staticDataDir)
-		err := j.staticClient.RenterPostAllowance(Allowance)
+		err := j.staticClient.RenterPostAllowance(params.Allowance)
 		j.staticLogger.Debugf("%v: allowance attempt complete", j.staticDataDir)
 		if err == nil {
 			// Success, we can exit the loop.
@@ -407,6 +493,7 @@ func (j *JobRunner) renter(phase renterPreparationPhase) {
 		}
 		// There was an error
 		j.staticLogger.Errorf("%v: trouble when setting renter allowance: %v", j.staticDataDir, err)
+		j.recordError(ctx, MetricAllowanceSetFailed, err)
 		if time.Since(start) > setAllowanceTimeout {
 			// Timeout was reached
 			j.staticLogger.Errorf("%v: couldn't set allowance within %v timeout", j.staticDataDir, setAllowanceTimeout)
@@ -414,42 +501,81 @@ func (j *JobRunner) renter(phase renterPreparationPhase) {
 
 		// Wait a bit before trying again.
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(setAllowanceFrequency):
 		}
 	}
 	j.staticLogger.Debugf("%v: renter allowance has been set successfully.", j.staticDataDir)
 
-	err = j.WaitForRenterUploadReady()
+	err := j.waitForRenterUploadReady(params)
 	if err != nil {
-		return
+		return err
 	}
 
 	if phase == allowanceSet {
-		return
+		return nil
 	}
 
-	// Start basic renter
-	rj := j.NewRenterJob()
-
-	// Spawn the uploader, downloader and deleter threads.
-	go rj.threadedUploader()
-	go rj.threadedDownloader()
-	go rj.threadedDeleter()
+	// Start basic renter. The uploader, downloader and deleter use their own
+	// random sources derived from the ant's seed and the job's ID, so that
+	// their values don't depend on the order the threads run in.
+	var jobID uint64
+	if tj := jobFromContext(ctx); tj != nil {
+		jobID = tj.managedStatus().ID
+	}
+	ar := j.staticAnt.staticRand
+	name := fmt.Sprintf("renter-%v", jobID)
+	rj := j.newRenterJob(params, ar.derive(name+"/upload"), ar.derive(name+"/download"), ar.derive(name+"/delete"))
+
+	// Spawn the uploader, downloader and deleter threads and keep running
+	// until the job is stopped.
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		rj.threadedUploader(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		rj.threadedDownloader(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		rj.threadedDeleter(ctx)
+	}()
+	wg.Wait()
+	return nil
 }
 
-// WaitForRenterUploadReady waits for renter upload ready with default timeout,
-// data pieces and parity pieces if the ant has renter job. If the ant doesn't
-// have renter job, it returns an error.
+// WaitForRenterUploadReady waits for renter upload ready with default timeout
+// and the data pieces and parity pieces of the ant's renter job config if the
+// ant has renter job. If the ant doesn't have renter job, it returns an error.
 func (j *JobRunner) WaitForRenterUploadReady() error {
 	if !j.staticAnt.HasRenterTypeJob() {
 		return errors.New("this ant hasn't renter job")
 	}
+	return j.waitForRenterUploadReady(j.staticAnt.renterParams())
+}
+
+// RenterUploadReady returns true if the ant's renter is ready to upload files
+// with the data pieces and parity pieces of the ant's renter job.
+func (a *Ant) RenterUploadReady() (bool, error) {
+	params := a.renterParams()
+	rur, err := a.StaticClient.RenterUploadReadyGet(params.DataPieces, params.ParityPieces)
+	if err != nil {
+		return false, errors.AddContext(err, "can't get renter upload ready status")
+	}
+	return rur.Ready, nil
+}
+
+// waitForRenterUploadReady waits for renter upload ready with default timeout
+// and the data pieces and parity pieces of the given renter job parameters.
+func (j *JobRunner) waitForRenterUploadReady(params RenterParams) error {
 	// Block until renter is upload ready or till timeout is reached
 	tries := int(renterUploadReadyTimeout/renterUploadReadyFrequency) + 1
 	err := build.Retry(tries, renterUploadReadyFrequency, func() error {
-		rur, err := j.staticClient.RenterUploadReadyGet(renterDataPieces, renterParityPieces)
+		rur, err := j.staticClient.RenterUploadReadyGet(params.DataPieces, params.ParityPieces)
 		if err != nil {
 			// Error getting RenterUploadReady
 			return fmt.Errorf("can't get renter upload ready status: %v", err)
@@ -472,36 +598,46 @@ func (j *JobRunner) WaitForRenterUploadReady() error {
 // Download will download the given file from the network to the given
 // destination path.
 func (r *RenterJob) Download(siaPath modules.SiaPath, destPath string) error {
+	return r.DownloadWithContext(context.Background(), siaPath, destPath)
+}
+
+// DownloadWithContext will download the given file from the network to the
+// given destination path. Waiting for the download is aborted when the
+// context is done.
+func (r *RenterJob) DownloadWithContext(ctx context.Context, siaPath modules.SiaPath, destPath string) error {
 	err := r.staticJR.StaticTG.Add()
 	if err != nil {
 		return errors.AddContext(err, "can't download a file")
 	}
 	defer r.staticJR.StaticTG.Done()
 
-	return r.managedDownload(siaPath, destPath)
+	return r.managedDownload(ctx, siaPath, destPath)
 }
 
-// managedDeleteRandom deletes a random file from the renter.
-func (r *RenterJob) managedDeleteRandom() error {
+// managedDeleteRandom deletes a random file from the renter. Failed deletions
+// are recorded in the job of the given context.
+func (r *RenterJob) managedDeleteRandom(ctx context.Context) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// no-op with fewer than 10 files
-	if len(r.Files) < deleteFileThreshold {
+	if len(r.Files) < r.staticParams.DeleteFileThreshold {
 		return nil
 	}
 
-	randindex := fastrand.Intn(len(r.Files))
+	randindex := r.staticDeleteRand.Intn(len(r.Files))
 
 	path, err := modules.NewSiaPath(r.Files[randindex].SourceFile)
 	if err != nil {
 		return err
 	}
 	if err := r.staticJR.staticClient.RenterFileDeletePost(path); err != nil {
+		r.staticJR.recordError(ctx, MetricDeletesFailed, err)
 		return err
 	}
 
 	r.staticLogger.Printf("%v: successfully deleted file.\n", r.staticJR.staticDataDir)
+	r.staticJR.staticMetrics.IncCounter(MetricDeletesCompleted)
 	err = os.Remove(r.Files[randindex].SourceFile)
 	if err != nil {
 		return errors.AddContext(err, "can't delete a source file")
@@ -513,7 +649,7 @@ func (r *RenterJob) managedDeleteRandom() error {
 
 // Download will managed download the given file from the network to the given
 // destination path.
-func (r *RenterJob) managedDownload(siaPath modules.SiaPath, destPath string) error {
+func (r *RenterJob) managedDownload(ctx context.Context, siaPath modules.SiaPath, destPath string) error {
 	// Check file is in renter file list and is available
 	renterFiles, err := r.staticJR.staticClient.RenterFilesGet(false) // cached=false
 	if err != nil {
@@ -547,7 +683,7 @@ func (r *RenterJob) managedDownload(siaPath modules.SiaPath, destPath string) er
 	}
 
 	// Download the file
-	err = downloadFile(r, fileToDownload, destPath)
+	err = downloadFile(ctx, r, fileToDownload, destPath)
 	if err != nil {
 		return errors.AddContext(err, "failed to download the file")
 	}
@@ -556,7 +692,7 @@ func (r *RenterJob) managedDownload(siaPath modules.SiaPath, destPath string) er
 }
 
 // managedDownloadRandomFile will managed download a random file from the network.
-func (r *RenterJob) managedDownloadRandomFile() error {
+func (r *RenterJob) managedDownloadRandomFile(ctx context.Context) error {
 	// Download a random file from the renter's file list
 	renterFiles, err := r.staticJR.staticClient.RenterFilesGet(false) // cached=false
 	if err != nil {
@@ -577,7 +713,7 @@ func (r *RenterJob) managedDownloadRandomFile() error {
 	}
 
 	// Download a file at random.
-	fileToDownload := availableFiles[fastrand.Intn(len(availableFiles))]
+	fileToDownload := availableFiles[r.staticDownloadRand.Intn(len(availableFiles))]
 
 	// Use ioutil.TempFile to get a random temporary filename.
 	f, err := ioutil.TempFile("", "antfarm-renter")
@@ -594,7 +730,7 @@ func (r *RenterJob) managedDownloadRandomFile() error {
 	}
 
 	// Download the file
-	err = downloadFile(r, fileToDownload, destPath)
+	err = downloadFile(ctx, r, fileToDownload, destPath)
 	if err != nil {
 		return errors.AddContext(err, "failed to download the file")
 	}
@@ -603,13 +739,20 @@ func (r *RenterJob) managedDownloadRandomFile() error {
 }
 
 // managedUpload will managed upload a file with given size to the network.
-func (r *RenterJob) managedUpload(fileSize uint64) (siaPath modules.SiaPath, err error) {
+// Waiting for the upload is aborted when the context is done.
+func (r *RenterJob) managedUpload(ctx context.Context, fileSize uint64) (siaPath modules.SiaPath, err error) {
+	// Register the in-flight upload, so that draining the ant waits for it
+	if err := r.staticJR.staticAnt.managedStartTransfer(); err != nil {
+		return modules.SiaPath{}, err
+	}
+	defer r.staticJR.staticAnt.managedFinishTransfer()
+
 	// Generate some random data to upload. The file needs to be closed before
 	// the upload to the network starts.
 	r.staticLogger.Debugf("%v: file upload preparation beginning.", r.staticJR.staticDataDir)
 	tempSubDir := filepath.Join(r.staticJR.staticDataDir, "renterSourceFiles")
 	pattern := "renterFile"
-	sourcePath, merkleRoot, err := createTempFile(tempSubDir, pattern, fileSize)
+	sourcePath, merkleRoot, err := createTempFile(tempSubDir, pattern, fileSize, r.staticUploadRand.Reader())
 	if err != nil {
 		return modules.SiaPath{}, errors.AddContext(err, "error creating file to upload")
 	}
@@ -629,108 +772,117 @@ func (r *RenterJob) managedUpload(fileSize uint64) (siaPath modules.SiaPath, err
 	r.Files = append(r.Files, rf)
 	r.mu.Unlock()
 
+	// Collect upload metrics. An upload interrupted by stopping the ant or by
+	// cancelling the context is neither completed nor failed. When the ant is
+	// stopped, an empty siaPath is returned.
+	metrics := r.staticJR.staticMetrics
+	metrics.IncCounter(MetricUploadsStarted)
+	uploadStart := time.Now()
+	uploadSiaPath := siaPath
+	defer func() {
+		if (err == nil && siaPath.IsEmpty()) || errors.Contains(err, context.Canceled) {
+			return
+		}
+		transfer := TransferRecord{
+			Type:     TransferUpload,
+			SiaPath:  uploadSiaPath.String(),
+			Size:     fileSize,
+			Start:    uploadStart,
+			Duration: time.Since(uploadStart),
+		}
+		if err != nil {
+			transfer.Error = err.Error()
+			metrics.IncCounter(MetricUploadsFailed)
+		} else {
+			metrics.IncCounter(MetricUploadsCompleted)
+			metrics.ObserveDuration(MetricUploadDuration, transfer.Duration)
+		}
+		metrics.RecordTransfer(transfer)
+	}()
+
+	// Subscribe to the ant's events before the upload starts, so that the
+	// upload completed event can't be missed
+	a := r.staticJR.staticAnt
+	events, unsubscribe := a.staticEvents.Subscribe(EventUploadCompleted)
+	defer unsubscribe()
+
 	// Upload the file to network
 	r.staticLogger.Debugf("%v: beginning file upload.", r.staticJR.staticDataDir)
-	err = r.staticJR.staticClient.RenterUploadPost(sourcePath, siaPath, renterDataPieces, renterParityPieces)
+	err = r.staticJR.staticClient.RenterUploadPost(sourcePath, siaPath, r.staticParams.DataPieces, r.staticParams.ParityPieces)
 	if err != nil {
 		return modules.SiaPath{}, errors.AddContext(err, "error uploading a file to network")
 	}
 	r.staticLogger.Debugf("%v: /renter/upload call completed successfully.  Waiting for the upload to complete", r.staticJR.staticDataDir)
 
-	// Block until the upload has reached 100%
-	start := time.Now()
-	var lastUploadProgress float64
-	// Set lastUploadProgressLogTimestamp to past so the upload progress check
-	// is able to log immediately
-	lastUploadProgressLogTimestamp := time.Now().Add(-uploadFileCheckLogFrequency)
-	for {
+	// Block until the ant's event watcher observes the upload reaching 100%
+	timeout := time.NewTimer(uploadTimeout)
+	defer timeout.Stop()
+	logTicker := time.NewTicker(uploadFileCheckLogFrequency)
+	defer logTicker.Stop()
+	for uploaded := false; !uploaded; {
 		select {
 		case <-r.staticJR.StaticTG.StopChan():
 			return modules.SiaPath{}, nil
-		case <-time.After(uploadFileCheckFrequency):
-		}
-
-		rfg, err := r.staticJR.staticClient.RenterFilesGet(false) // cached=false
-		if err != nil {
-			return modules.SiaPath{}, errors.AddContext(err, "error calling /renter/files")
-		}
-
-		// Check upload progress
-		var uploadProgress float64
-		for _, file := range rfg.Files {
-			if file.SiaPath == siaPath {
-				uploadProgress = file.UploadProgress
-				break
-			}
-		}
-		r.staticLogger.Debugf("%v: upload progress: %v%%", r.staticJR.staticDataDir, uploadProgress)
-		if uploadProgress == 100 {
-			// The file has finished uploading
-			break
-		}
-
-		// If there is no progress in the upload log number of active hosts and
-		// contracts. Repeat the contracts number log every according to the
-		// given frequency.
-		if uploadProgress == lastUploadProgress && time.Since(lastUploadProgressLogTimestamp) > uploadFileCheckLogFrequency {
-			lastUploadProgressLogTimestamp = time.Now()
-			// Log number of hostdb active hosts
-			hdag, err := r.staticJR.staticClient.HostDbActiveGet()
-			if err != nil {
-				r.staticLogger.Errorf("%v: can't get hostdb active hosts: %v", r.staticJR.staticDataDir, err)
-			} else {
-				r.staticLogger.Debugf("%v: number of HostDB Active Hosts: %v", r.staticJR.staticDataDir, len(hdag.Hosts))
-			}
-
-			// Log number of each type of contract
-			rc, err := r.staticJR.staticClient.RenterAllContractsGet()
-			if err != nil {
-				r.staticLogger.Errorf("%v: can't get renter contracts: %v", r.staticJR.staticDataDir, err)
-			} else {
-				var msg string
-				msg += fmt.Sprintf("%v: number of Contracts: %v\n", r.staticJR.staticDataDir, len(rc.Contracts))
-				msg += fmt.Sprintf("%v: number of ActiveContracts: %v\n", r.staticJR.staticDataDir, len(rc.ActiveContracts))
-				msg += fmt.Sprintf("%v: number of DisabledContracts: %v\n", r.staticJR.staticDataDir, len(rc.DisabledContracts))
-				msg += fmt.Sprintf("%v: number of ExpiredContracts: %v\n", r.staticJR.staticDataDir, len(rc.ExpiredContracts))
-				msg += fmt.Sprintf("%v: number of ExpiredRefreshedContracts: %v\n", r.staticJR.staticDataDir, len(rc.ExpiredRefreshedContracts))
-				msg += fmt.Sprintf("%v: number of InactiveContracts: %v\n", r.staticJR.staticDataDir, len(rc.InactiveContracts))
-				msg += fmt.Sprintf("%v: number of PassiveContracts: %v\n", r.staticJR.staticDataDir, len(rc.PassiveContracts))
-				msg += fmt.Sprintf("%v: number of RecoverableContracts: %v\n", r.staticJR.staticDataDir, len(rc.RecoverableContracts))
-				msg += fmt.Sprintf("%v: number of RefreshedContracts: %v\n", r.staticJR.staticDataDir, len(rc.RefreshedContracts))
-				r.staticLogger.Debugln(msg)
-			}
-		}
-		lastUploadProgress = uploadProgress
-
-		// Check timeout
-		if time.Since(start) > uploadTimeout {
+		case <-ctx.Done():
+			return modules.SiaPath{}, ctx.Err()
+		case e := <-events:
+			uploaded = e.Type == EventUploadCompleted && e.SiaPath == siaPath.String()
+		case <-timeout.C:
 			// Log error
-			err := fmt.Errorf("file with siaPath %v could not be fully uploaded within %v timeout. Progress reached: %v%%", siaPath, uploadTimeout, uploadProgress)
+			err := fmt.Errorf("file with siaPath %v could not be fully uploaded within %v timeout", siaPath, uploadTimeout)
 			r.staticLogger.Errorf("%v: %v", r.staticJR.staticDataDir, err)
 			return modules.SiaPath{}, err
+		case <-logTicker.C:
+			// The upload is taking long, log number of active hosts and
+			// contracts.
+			r.managedLogUploadStuck()
 		}
 	}
 	r.staticLogger.Printf("%v: file has been successfully uploaded to 100%%.", r.staticJR.staticDataDir)
 	return siaPath, nil
 }
 
-// threadedDeleter deletes one random file from the renter every 100 seconds
-// once 10 or more files have been uploaded.
-func (r *RenterJob) threadedDeleter() {
-	err := r.staticJR.StaticTG.Add()
+// managedLogUploadStuck logs the number of hostdb active hosts and the number
+// of each type of contract when an upload gets stuck.
+func (r *RenterJob) managedLogUploadStuck() {
+	// Log number of hostdb active hosts
+	hdag, err := r.staticJR.staticClient.HostDbActiveGet()
+	if err != nil {
+		r.staticLogger.Errorf("%v: can't get hostdb active hosts: %v", r.staticJR.staticDataDir, err)
+	} else {
+		r.staticLogger.Debugf("%v: number of HostDB Active Hosts: %v", r.staticJR.staticDataDir, len(hdag.Hosts))
+	}
+
+	// Log number of each type of contract
+	rc, err := r.staticJR.staticClient.RenterAllContractsGet()
 	if err != nil {
+		r.staticLogger.Errorf("%v: can't get renter contracts: %v", r.staticJR.staticDataDir, err)
 		return
 	}
-	defer r.staticJR.StaticTG.Done()
+	var msg string
+	msg += fmt.Sprintf("%v: number of Contracts: %v\n", r.staticJR.staticDataDir, len(rc.Contracts))
+	msg += fmt.Sprintf("%v: number of ActiveContracts: %v\n", r.staticJR.staticDataDir, len(rc.ActiveContracts))
+	msg += fmt.Sprintf("%v: number of DisabledContracts: %v\n", r.staticJR.staticDataDir, len(rc.DisabledContracts))
+	msg += fmt.Sprintf("%v: number of ExpiredContracts: %v\n", r.staticJR.staticDataDir, len(rc.ExpiredContracts))
+	msg += fmt.Sprintf("%v: number of ExpiredRefreshedContracts: %v\n", r.staticJR.staticDataDir, len(rc.ExpiredRefreshedContracts))
+	msg += fmt.Sprintf("%v: number of InactiveContracts: %v\n", r.staticJR.staticDataDir, len(rc.InactiveContracts))
+	msg += fmt.Sprintf("%v: number of PassiveContracts: %v\n", r.staticJR.staticDataDir, len(rc.PassiveContracts))
+	msg += fmt.Sprintf("%v: number of RecoverableContracts: %v\n", r.staticJR.staticDataDir, len(rc.RecoverableContracts))
+	msg += fmt.Sprintf("%v: number of RefreshedContracts: %v\n", r.staticJR.staticDataDir, len(rc.RefreshedContracts))
+	r.staticLogger.Debugln(msg)
+}
 
+// threadedDeleter deletes one random file from the renter every 100 seconds
+// once 10 or more files have been uploaded.
+func (r *RenterJob) threadedDeleter(ctx context.Context) {
 	for {
 		select {
-		case <-r.staticJR.StaticTG.StopChan():
+		case <-ctx.Done():
 			return
 		case <-time.After(deleteFileFrequency):
 		}
 
-		if err := r.managedDeleteRandom(); err != nil {
+		if err := r.managedDeleteRandom(ctx); err != nil {
 			r.staticLogger.Errorf("%v: can't delete random file: %v", r.staticJR.staticDataDir, err)
 		}
 	}
@@ -738,24 +890,23 @@ func (r *RenterJob) threadedDeleter() {
 
 // threadedDownloader is a function that continuously runs for the renter job,
 // downloading a file at random every 400 seconds.
-func (r *RenterJob) threadedDownloader() {
-	err := r.staticJR.StaticTG.Add()
-	if err != nil {
-		return
-	}
-	defer r.staticJR.StaticTG.Done()
-
+func (r *RenterJob) threadedDownloader(ctx context.Context) {
 	// Wait for the first file to be uploaded before starting the download
 	// loop.
 	for {
 		select {
-		case <-r.staticJR.StaticTG.StopChan():
+		case <-ctx.Done():
 			return
-		case <-time.After(downloadFileFrequency):
+		case <-time.After(r.staticParams.uploadFileFrequency() * 3 / 2):
+		}
+
+		// Don't start new downloads while the ant is draining.
+		if r.staticJR.staticAnt.managedDraining() {
+			continue
 		}
 
 		// Download a file.
-		if err := r.managedDownloadRandomFile(); err != nil {
+		if err := r.managedDownloadRandomFile(ctx); err != nil && ctx.Err() == nil {
 			r.staticLogger.Errorf("%v: can't download random file: %v", r.staticJR.staticDataDir, err)
 		}
 	}
@@ -764,29 +915,29 @@ func (r *RenterJob) threadedDownloader() {
 // threadedUploader is a function that continuously runs for the renter job,
 // uploading a 500MB file every 240 seconds (10 blocks). The renter should have
 // already set an allowance.
-func (r *RenterJob) threadedUploader() {
-	err := r.staticJR.StaticTG.Add()
-	if err != nil {
-		return
-	}
-	defer r.staticJR.StaticTG.Done()
-
-	// Make the source files directory
-	err = os.Mkdir(filepath.Join(r.staticJR.staticDataDir, "renterSourceFiles"), 0700)
+func (r *RenterJob) threadedUploader(ctx context.Context) {
+	// Make the source files directory, it exists when the job is restarted
+	err := os.MkdirAll(filepath.Join(r.staticJR.staticDataDir, "renterSourceFiles"), 0700)
 	if err != nil {
+		r.staticLogger.Errorf("%v: can't create source files directory: %v", r.staticJR.staticDataDir, err)
 		return
 	}
 
 	for {
 		// Wait a while between upload attempts.
 		select {
-		case <-r.staticJR.StaticTG.StopChan():
+		case <-ctx.Done():
 			return
-		case <-time.After(uploadFileFrequency):
+		case <-time.After(r.staticParams.uploadFileFrequency()):
+		}
+
+		// Don't start new uploads while the ant is draining.
+		if r.staticJR.staticAnt.managedDraining() {
+			continue
 		}
 
 		// Upload a file.
-		if _, err := r.managedUpload(uploadFileSize); err != nil {
+		if _, err := r.managedUpload(ctx, r.staticParams.UploadFileSize); err != nil && ctx.Err() == nil {
 			r.staticLogger.Errorf("%v: can't upload file: %v", r.staticJR.staticDataDir, err)
 		}
 	}
@@ -794,11 +945,17 @@ func (r *RenterJob) threadedUploader() {
 
 // Upload will upload a file with given size to the network.
 func (r *RenterJob) Upload(fileSize uint64) (siaPath modules.SiaPath, err error) {
+	return r.UploadWithContext(context.Background(), fileSize)
+}
+
+// UploadWithContext will upload a file with given size to the network.
+// Waiting for the upload is aborted when the context is done.
+func (r *RenterJob) UploadWithContext(ctx context.Context, fileSize uint64) (siaPath modules.SiaPath, err error) {
 	err = r.staticJR.StaticTG.Add()
 	if err != nil {
 		return modules.SiaPath{}, errors.AddContext(err, "can't upload a file")
 	}
 	defer r.staticJR.StaticTG.Done()
 
-	return r.managedUpload(fileSize)
+	return r.managedUpload(ctx, fileSize)
 }
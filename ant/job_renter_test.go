@@ -0,0 +1,129 @@
+package ant
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/siadmock"
+	"go.sia.tech/sia-antfarm/test"
+	"go.sia.tech/siad/modules"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// TestDownloadRetry tests that a renter job's download fails on a failing
+// download request and on a download reporting an error, and that retrying
+// the download succeeds once the error is gone, using a mock siad.
+func TestDownloadRetry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a mock siad
+	dataDir := test.TestDir(t.Name())
+	mock, config := newMockSiadConfig(t, dataDir)
+	defer func() {
+		if err := mock.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create ant client
+	c, err := newClient(config.APIAddr, config.APIPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create ant
+	ant := &Ant{
+		staticAntsSyncWG: &sync.WaitGroup{},
+		staticLogger:     logger,
+		StaticClient:     c,
+	}
+
+	// Create jobRunnner on the mock siad and a renter job
+	j, err := newJobRunner(logger, ant, config.DataDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := j.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rj := j.newRenterJob(DefaultRenterParams(), nil, nil, nil)
+
+	// Add an available file to the mock's renter
+	fileSize := uint64(1000)
+	sourcePath, _, err := createTempFile(filepath.Join(dataDir, "renterSourceFiles"), "renterFile", fileSize, fastrand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPath, err := modules.NewSiaPath("renterFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.Update(func(s *siadmock.State) {
+		s.Files = append(s.Files, modules.FileInfo{
+			SiaPath:   siaPath,
+			LocalPath: sourcePath,
+			Filesize:  fileSize,
+			Available: true,
+		})
+	})
+	destPath := filepath.Join(dataDir, "downloads", "renterFile")
+
+	// The download fails when the download request fails
+	mock.SetErrorTimes("GET /renter/download", errors.New("injected download request error"), 1)
+	err = rj.Download(siaPath, destPath)
+	if err == nil || !strings.Contains(err.Error(), "injected download request error") {
+		t.Fatalf("expected injected download request error, got %v", err)
+	}
+
+	// The download fails when siad reports a download error
+	mock.Update(func(s *siadmock.State) {
+		s.DownloadError = "injected download error"
+	})
+	err = rj.Download(siaPath, destPath)
+	if err == nil || !strings.Contains(err.Error(), "injected download error") {
+		t.Fatalf("expected injected download error, got %v", err)
+	}
+
+	// Retrying the download succeeds once the error is gone
+	mock.Update(func(s *siadmock.State) {
+		s.DownloadError = ""
+	})
+	if err := rj.Download(siaPath, destPath); err != nil {
+		t.Fatal(err)
+	}
+	source, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := ioutil.ReadFile(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, downloaded) {
+		t.Fatal("downloaded file doesn't match the uploaded file")
+	}
+
+	// The failed and the completed downloads are recorded in the metrics
+	counters := j.staticMetrics.Snapshot().Counters
+	if counters[MetricDownloadsStarted] != 3 || counters[MetricDownloadsFailed] != 2 || counters[MetricDownloadsCompleted] != 1 {
+		t.Fatalf("expected 3 started, 2 failed and 1 completed downloads, got %v", counters)
+	}
+}
@@ -0,0 +1,170 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testJob is a job used to test the job registry.
+type testJob struct {
+	Value int
+}
+
+// Name implements Job.
+func (testJob) Name() string { return "testJob" }
+
+// Run implements Job.
+func (testJob) Run(_ context.Context, _ *JobRunner) error { return nil }
+
+// TestJobRegistry verifies registering and creating jobs.
+func TestJobRegistry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Built-in jobs are registered
+	for _, name := range []string{"generic", "miner", "host", "noAllowanceRenter", "renter", "autoRenter", "gateway", "bigspender", "littlesupplier"} {
+		job, err := NewJob(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Name() != name {
+			t.Fatalf("expected job name %v, got %v", name, job.Name())
+		}
+	}
+
+	// A littlesupplier job without a send address fails
+	if err := (LittleSupplierJob{}).Run(context.Background(), nil); err == nil {
+		t.Fatal("expected error running littlesupplier job without send address")
+	}
+
+	// Unknown job
+	if _, err := NewJob("thisjobdoesnotexist"); err == nil {
+		t.Fatal("expected error creating an unregistered job")
+	}
+
+	// Register a custom job, each NewJob call returns a new instance
+	RegisterJob("testJob", func() Job { return &testJob{Value: 1} })
+	job, err := NewJob("testJob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tj, ok := job.(*testJob)
+	if !ok || tj.Value != 1 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	tj.Value = 2
+	job, err = NewJob("testJob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.(*testJob).Value != 1 {
+		t.Fatal("job instances should not be shared")
+	}
+	var found bool
+	for _, name := range RegisteredJobs() {
+		found = found || name == "testJob"
+	}
+	if !found {
+		t.Fatal("custom job is not listed in registered jobs")
+	}
+
+	// Registering a job twice panics
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic registering a job twice")
+			}
+		}()
+		RegisterJob("testJob", func() Job { return &testJob{} })
+	}()
+}
+
+// TestJobConfigJSON verifies marshalling and unmarshalling job configs.
+func TestJobConfigJSON(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	data := `["miner", {"Name": "autoRenter", "Params": {"UploadFileSize": 1000}}]`
+	var jobs []JobConfig
+	if err := json.Unmarshal([]byte(data), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Name != "miner" || jobs[1].Name != "autoRenter" {
+		t.Fatalf("unexpected job configs %+v", jobs)
+	}
+	if len(jobs[0].Params) != 0 || len(jobs[1].Params) == 0 {
+		t.Fatalf("unexpected job params %+v", jobs)
+	}
+
+	// Jobs without params are marshalled as strings
+	b, err := json.Marshal(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `["miner",{"Name":"autoRenter","Params":{"UploadFileSize":1000}}]`
+	if string(b) != expected {
+		t.Fatalf("expected %v, got %v", expected, string(b))
+	}
+
+	// Invalid job config
+	if err := json.Unmarshal([]byte(`[1]`), &jobs); err == nil {
+		t.Fatal("expected error unmarshalling an invalid job config")
+	}
+}
+
+// TestNewJobFromConfig verifies that job params override the job defaults.
+func TestNewJobFromConfig(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Defaults
+	job, err := NewJobFromConfig(JobConfig{Name: "autoRenter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rj := job.(*RenterAntJob)
+	if !reflect.DeepEqual(rj.RenterParams, DefaultRenterParams()) {
+		t.Fatalf("expected default renter params, got %+v", rj.RenterParams)
+	}
+
+	// Overridden params, other params keep defaults
+	params := json.RawMessage(`{"UploadFileSize": 1000, "DataPieces": 2}`)
+	job, err = NewJobFromConfig(JobConfig{Name: "autoRenter", Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rj = job.(*RenterAntJob)
+	if rj.Name() != "autoRenter" || rj.UploadFileSize != 1000 || rj.DataPieces != 2 {
+		t.Fatalf("unexpected renter params %+v", rj.RenterParams)
+	}
+	if rj.ParityPieces != defaultRenterParityPieces || rj.Allowance.Hosts != Allowance.Hosts {
+		t.Fatalf("expected default params to be kept, got %+v", rj.RenterParams)
+	}
+
+	params = json.RawMessage(`{"SpendIntervalSeconds": 5}`)
+	job, err = NewJobFromConfig(JobConfig{Name: "bigspender", Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bj := job.(*BigSpenderJob)
+	if bj.SpendIntervalSeconds != 5 || bj.SpendThresholdSiacoins != spendThresholdSiacoins {
+		t.Fatalf("unexpected bigspender params %+v", bj)
+	}
+
+	// Unknown params and jobs
+	params = json.RawMessage(`{"UploadFileSizes": 1000}`)
+	if _, err := NewJobFromConfig(JobConfig{Name: "autoRenter", Params: params}); err == nil {
+		t.Fatal("expected error with an unknown param")
+	}
+	if _, err := NewJobFromConfig(JobConfig{Name: "thisjobdoesnotexist"}); err == nil {
+		t.Fatal("expected error with an unknown job")
+	}
+}
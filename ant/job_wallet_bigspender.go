@@ -1,42 +1,41 @@
 package ant
 
 import (
+	"context"
 	"time"
 
 	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
 )
 
-var (
-	spendInterval  = time.Second * 30
-	spendThreshold = types.NewCurrency64(5e4).Mul(types.SiacoinPrecision)
-)
+const (
+	// spendIntervalSeconds defines the default interval of big spender
+	// transactions.
+	spendIntervalSeconds = 30
 
-func (j *JobRunner) bigSpender() {
-	err := j.StaticTG.Add()
-	if err != nil {
-		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
-		return
-	}
-	defer j.StaticTG.Done()
+	// spendThresholdSiacoins defines the default amount of Siacoins sent in
+	// a big spender transaction.
+	spendThresholdSiacoins = 5e4
+)
 
+// bigSpender sends spendThreshold Siacoins to a void address every
+// spendInterval if the wallet balance allows it.
+func (j *JobRunner) bigSpender(ctx context.Context, spendInterval time.Duration, spendThreshold types.Currency) error {
 	// Wait for ants to be synced if the wait group was set
-	synced := j.waitForAntsSync()
-	if !synced {
-		j.staticLogger.Errorf("%v: waiting for ants to sync failed", j.staticDataDir)
-		return
+	if err := j.waitForAntsSync(ctx); err != nil {
+		return err
 	}
 
 	for {
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(spendInterval):
 		}
 
 		walletGet, err := j.staticClient.WalletGet()
 		if err != nil {
-			j.staticLogger.Errorf("%v: can't get wallet info: %v", j.staticDataDir, err)
-			return
+			return errors.AddContext(err, "can't get wallet info")
 		}
 
 		if walletGet.ConfirmedSiacoinBalance.Cmp(spendThreshold) < 0 {
@@ -49,9 +48,11 @@ func (j *JobRunner) bigSpender() {
 		_, err = j.staticClient.WalletSiacoinsPost(spendThreshold, voidaddress, false)
 		if err != nil {
 			j.staticLogger.Errorf("%v: can't send Siacoins: %v", j.staticDataDir, err)
+			j.recordError(ctx, MetricTransactionsFailed, err)
 			continue
 		}
 
 		j.staticLogger.Printf("%v: large transaction send successful", j.staticDataDir)
+		j.staticMetrics.IncCounter(MetricTransactionsSent)
 	}
 }
@@ -1,9 +1,11 @@
 package ant
 
 import (
+	"context"
 	"time"
 
 	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
 )
 
 var (
@@ -11,32 +13,24 @@ var (
 	sendAmount   = types.NewCurrency64(1000).Mul(types.SiacoinPrecision)
 )
 
-func (j *JobRunner) littleSupplier(sendAddress types.UnlockHash) {
-	err := j.StaticTG.Add()
-	if err != nil {
-		j.staticLogger.Errorf("%v: can't add thread group: %v", j.staticDataDir, err)
-		return
-	}
-	defer j.StaticTG.Done()
-
+// littleSupplier sends sendAmount Siacoins to sendAddress every sendInterval
+// if the wallet balance allows it.
+func (j *JobRunner) littleSupplier(ctx context.Context, sendAddress types.UnlockHash) error {
 	// Wait for ants to be synced if the wait group was set
-	synced := j.waitForAntsSync()
-	if !synced {
-		j.staticLogger.Errorf("%v: waiting for ants to sync failed", j.staticDataDir)
-		return
+	if err := j.waitForAntsSync(ctx); err != nil {
+		return err
 	}
 
 	for {
 		select {
-		case <-j.StaticTG.StopChan():
-			return
+		case <-ctx.Done():
+			return nil
 		case <-time.After(sendInterval):
 		}
 
 		walletGet, err := j.staticClient.WalletGet()
 		if err != nil {
-			j.staticLogger.Errorf("%v: can't get wallet info: %v", j.staticDataDir, err)
-			return
+			return errors.AddContext(err, "can't get wallet info")
 		}
 
 		if walletGet.ConfirmedSiacoinBalance.Cmp(sendAmount) < 0 {
@@ -46,6 +40,9 @@ func (j *JobRunner) littleSupplier(sendAddress types.UnlockHash) {
 		_, err = j.staticClient.WalletSiacoinsPost(sendAmount, sendAddress, false)
 		if err != nil {
 			j.staticLogger.Errorf("%v: can't send Siacoins: %v", j.staticDataDir, err)
+			j.recordError(ctx, MetricTransactionsFailed, err)
+			continue
 		}
+		j.staticMetrics.IncCounter(MetricTransactionsSent)
 	}
 }
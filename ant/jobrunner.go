@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"sync"
 
 	"go.sia.tech/sia-antfarm/persist"
@@ -21,13 +22,18 @@ type JobRunner struct {
 	StaticWalletSeed string
 	staticDataDir    string
 	StaticTG         threadgroup.ThreadGroup
+
+	// staticMetrics collects metrics of the jobs run by the job runner. The
+	// metrics are preserved when the job runner is recreated.
+	staticMetrics *JobMetrics
 }
 
 // newJobRunner creates a new job runner using the provided parameters. If the
 // existingWalletSeed is empty, it expects the connected api to be newly
 // initialized, and it will initialize a new wallet. If existingWalletSeed is
 // set, it expects previous node directory structure including existing wallet.
-// In both cases the wallet is unlocked for usage in the jobs. siadirectory is
+// If the ant has a seed, an empty existingWalletSeed is derived from it. In
+// both cases the wallet is unlocked for usage in the jobs. siadirectory is
 // used in logging to identify the job runner.
 func newJobRunner(logger *persist.Logger, ant *Ant, siadirectory string, existingWalletSeed string) (*JobRunner, error) {
 	jr := &JobRunner{
@@ -36,6 +42,16 @@ func newJobRunner(logger *persist.Logger, ant *Ant, siadirectory string, existin
 		staticAnt:        ant,
 		staticClient:     ant.StaticClient,
 		staticDataDir:    ant.Config.DataDir,
+		staticMetrics:    NewJobMetrics(),
+	}
+
+	// Derive the wallet seed from the ant's seed
+	if existingWalletSeed == "" && ant.Config.Seed != "" {
+		seed, err := walletSeedFromSeed(ant.Config.Seed)
+		if err != nil {
+			return nil, errors.AddContext(err, "can't derive wallet seed")
+		}
+		existingWalletSeed = seed
 	}
 
 	// Get the wallet
@@ -119,9 +135,17 @@ func (j *JobRunner) Stop() error {
 	return nil
 }
 
-// waitForAntsSync returns true if wait has finished, false if jobRunner was
-// stopped.
-func (j *JobRunner) waitForAntsSync() bool {
+// Metrics returns the job metrics registry of the job runner.
+func (j *JobRunner) Metrics() *JobMetrics {
+	return j.staticMetrics
+}
+
+// waitForAntsSync waits until the ants are synced, the job running with the
+// given context is in waiting-for-sync state meanwhile. It returns an error if
+// the job was stopped.
+func (j *JobRunner) waitForAntsSync(ctx context.Context) error {
+	setJobState(ctx, JobStateWaitingForSync)
+
 	// Send antsSyncWG wait done to channel
 	c := make(chan struct{})
 	go func() {
@@ -132,9 +156,10 @@ func (j *JobRunner) waitForAntsSync() bool {
 	// Wait for antsSyncWG or stop channel
 	select {
 	case <-c:
-		return true
-	case <-j.StaticTG.StopChan():
-		return false
+		setJobState(ctx, JobStateRunning)
+		return nil
+	case <-ctx.Done():
+		return errJobStopped
 	}
 }
 
@@ -146,6 +171,7 @@ func recreateJobRunner(j *JobRunner) (*JobRunner, error) {
 	if err != nil {
 		return &JobRunner{}, errors.AddContext(err, "couldn't create an updated job runner")
 	}
+	newJR.staticMetrics = j.staticMetrics
 
 	return newJR, nil
 }
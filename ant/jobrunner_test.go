@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"sync"
 	"testing"
 
@@ -30,11 +31,11 @@ func TestNewJobRunner(t *testing.T) {
 	}()
 
 	// Create siad process
-	siad, err := newSiad(logger, config)
+	siad, err := newSiad(context.Background(), logger, config)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad.Process)
+	defer stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad)
 
 	// Create ant client
 	client, err := newClient(config.APIAddr, config.APIPassword)
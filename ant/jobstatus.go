@@ -0,0 +1,370 @@
+package ant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// JobState defines type for job State enum
+type JobState string
+
+// JobState constants define values for job State enum
+const (
+	// JobStateWaitingForSync defines a job waiting for ants to sync.
+	JobStateWaitingForSync JobState = "waiting-for-sync"
+
+	// JobStateRunning defines a running job.
+	JobStateRunning JobState = "running"
+
+	// JobStateFinished defines a job which finished without an error, e.g. a
+	// renter job once the renter is upload ready.
+	JobStateFinished JobState = "finished"
+
+	// JobStateFailed defines a job which returned an error.
+	JobStateFailed JobState = "failed"
+
+	// JobStateStopped defines a job which was stopped.
+	JobStateStopped JobState = "stopped"
+)
+
+const (
+	// maxJobHistory defines the maximum number of jobs tracked by an ant.
+	// When it is reached, the oldest jobs which are not active are dropped.
+	maxJobHistory = 100
+)
+
+var (
+	// errJobStopped is returned when a job was stopped while waiting.
+	errJobStopped = errors.New("job was stopped")
+)
+
+type (
+	// JobStatus contains the status of a job started on an ant.
+	JobStatus struct {
+		ID    uint64
+		Name  string
+		State JobState
+		Start time.Time
+
+		// LastError contains the last error recorded by the job or the error
+		// the job failed with.
+		LastError string `json:",omitempty"`
+	}
+
+	// trackedJob is a job started on an ant with its status.
+	trackedJob struct {
+		staticJob Job
+
+		status JobStatus
+		cancel context.CancelFunc
+		done   chan struct{}
+		mu     sync.Mutex
+	}
+
+	// jobContextKey is the context key of the tracked job running with the
+	// context.
+	jobContextKey struct{}
+)
+
+// active returns true if the job is waiting for sync or running.
+func (s JobStatus) active() bool {
+	return s.State == JobStateWaitingForSync || s.State == JobStateRunning
+}
+
+// managedStatus returns the job status.
+func (tj *trackedJob) managedStatus() JobStatus {
+	tj.mu.Lock()
+	defer tj.mu.Unlock()
+	return tj.status
+}
+
+// managedSetState sets the job state.
+func (tj *trackedJob) managedSetState(state JobState) {
+	tj.mu.Lock()
+	defer tj.mu.Unlock()
+	tj.status.State = state
+}
+
+// managedSetError sets the job's last error.
+func (tj *trackedJob) managedSetError(err error) {
+	tj.mu.Lock()
+	defer tj.mu.Unlock()
+	tj.status.LastError = err.Error()
+}
+
+// jobFromContext returns the tracked job running with the given context or
+// nil.
+func jobFromContext(ctx context.Context) *trackedJob {
+	tj, _ := ctx.Value(jobContextKey{}).(*trackedJob)
+	return tj
+}
+
+// setJobState sets the state of the tracked job running with the given
+// context, if there is any.
+func setJobState(ctx context.Context, state JobState) {
+	if tj := jobFromContext(ctx); tj != nil {
+		tj.managedSetState(state)
+	}
+}
+
+// recordError records the error in the job metrics and as the last error of
+// the tracked job running with the given context.
+func (j *JobRunner) recordError(ctx context.Context, name MetricName, err error) {
+	j.staticMetrics.RecordError(name, err)
+	if tj := jobFromContext(ctx); tj != nil {
+		tj.managedSetError(err)
+	}
+}
+
+// launchJob runs the tracked job in the job runner's thread group and tracks
+// its state.
+func (a *Ant) launchJob(jr *JobRunner, tj *trackedJob) error {
+	if err := jr.StaticTG.Add(); err != nil {
+		return errors.AddContext(err, "can't add thread group")
+	}
+	ctx, cancel := context.WithCancel(jr.StaticTG.StopCtx())
+	ctx = context.WithValue(ctx, jobContextKey{}, tj)
+	done := make(chan struct{})
+
+	tj.mu.Lock()
+	tj.status.State = JobStateRunning
+	tj.status.Start = time.Now()
+	tj.status.LastError = ""
+	tj.cancel = cancel
+	tj.done = done
+	tj.mu.Unlock()
+
+	go func() {
+		defer jr.StaticTG.Done()
+		defer cancel()
+		err := tj.staticJob.Run(ctx, jr)
+
+		tj.mu.Lock()
+		switch {
+		case ctx.Err() != nil:
+			tj.status.State = JobStateStopped
+		case err != nil:
+			tj.status.State = JobStateFailed
+			tj.status.LastError = err.Error()
+			a.staticLogger.Errorf("%v: job %v (%v) failed: %v", a.Config.DataDir, tj.status.Name, tj.status.ID, err)
+		default:
+			tj.status.State = JobStateFinished
+		}
+		tj.mu.Unlock()
+		close(done)
+	}()
+	jr.staticMetrics.RecordJob(tj.staticJob.Name())
+	return nil
+}
+
+// managedJob returns the tracked job with the given ID.
+func (a *Ant) managedJob(id uint64) (*trackedJob, error) {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	for _, tj := range a.jobs {
+		if tj.managedStatus().ID == id {
+			return tj, nil
+		}
+	}
+	return nil, fmt.Errorf("job %v doesn't exist", id)
+}
+
+// managedAddJob adds the job to the tracked jobs and drops the oldest jobs
+// which are not active if there are too many tracked jobs.
+func (a *Ant) managedAddJob(tj *trackedJob) {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	a.jobs = append(a.jobs, tj)
+	for i := 0; len(a.jobs) > maxJobHistory && i < len(a.jobs); {
+		if a.jobs[i].managedStatus().active() {
+			i++
+			continue
+		}
+		a.jobs = append(a.jobs[:i], a.jobs[i+1:]...)
+	}
+}
+
+// JobStatuses returns the statuses of the jobs started on the ant.
+func (a *Ant) JobStatuses() []JobStatus {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	statuses := make([]JobStatus, 0, len(a.jobs))
+	for _, tj := range a.jobs {
+		statuses = append(statuses, tj.managedStatus())
+	}
+	return statuses
+}
+
+// HasActiveJob returns true if a job with the given name is waiting for sync
+// or running on the ant.
+func (a *Ant) HasActiveJob(name string) bool {
+	for _, s := range a.JobStatuses() {
+		if s.Name == name && s.active() {
+			return true
+		}
+	}
+	return false
+}
+
+// JobStatus returns the status of the job with the given ID.
+func (a *Ant) JobStatus(id uint64) (JobStatus, error) {
+	tj, err := a.managedJob(id)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return tj.managedStatus(), nil
+}
+
+// StopJob stops the job with the given ID and waits until the job returns.
+// Other jobs of the ant keep running.
+func (a *Ant) StopJob(id uint64) error {
+	tj, err := a.managedJob(id)
+	if err != nil {
+		return err
+	}
+	tj.mu.Lock()
+	active := tj.status.active()
+	cancel, done := tj.cancel, tj.done
+	tj.mu.Unlock()
+	if !active {
+		return fmt.Errorf("job %v is not running", id)
+	}
+	cancel()
+	<-done
+	a.staticLogger.Printf("%v: job %v (%v) stopped", a.Config.DataDir, tj.staticJob.Name(), id)
+	return nil
+}
+
+// RestartJob restarts the job with the given ID, stopping it first if it is
+// still running. The restarted job keeps its ID.
+func (a *Ant) RestartJob(id uint64) error {
+	jr := a.JobRunner()
+	if jr == nil {
+		return errors.New("ant is not running")
+	}
+	tj, err := a.managedJob(id)
+	if err != nil {
+		return err
+	}
+	tj.mu.Lock()
+	cancel, done := tj.cancel, tj.done
+	tj.mu.Unlock()
+	cancel()
+	<-done
+	if err := a.launchJob(jr, tj); err != nil {
+		return errors.AddContext(err, "can't restart job")
+	}
+	a.staticLogger.Printf("%v: job %v (%v) restarted", a.Config.DataDir, tj.staticJob.Name(), id)
+	return nil
+}
+
+// JobConfigs returns the configs of the ant's jobs.
+func (a *Ant) JobConfigs() []JobConfig {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	return append([]JobConfig(nil), a.Config.Jobs...)
+}
+
+// UpdateJobs changes the ant's job configs to the given configs. Running jobs
+// whose configs were removed are stopped and jobs whose configs were added are
+// started, jobs with unchanged configs keep running.
+func (a *Ant) UpdateJobs(jobs []JobConfig) error {
+	if a.JobRunner() == nil {
+		return errors.New("ant is not running")
+	}
+	removed, added := diffJobConfigs(a.JobConfigs(), jobs)
+
+	// Create the added jobs first, so that an invalid job config doesn't
+	// leave the jobs half updated
+	var newJobs []Job
+	for _, jc := range added {
+		job, err := NewJobFromConfig(jc)
+		if err != nil {
+			return errors.AddContext(err, "can't create ant's job")
+		}
+		newJobs = append(newJobs, job)
+	}
+	for _, jc := range removed {
+		if err := a.managedStopJobFromConfig(jc); err != nil {
+			return err
+		}
+	}
+	for _, job := range newJobs {
+		if err := a.RunJob(job); err != nil {
+			return errors.AddContext(err, "can't start ant's job")
+		}
+	}
+
+	a.jobsMu.Lock()
+	a.Config.Jobs = append([]JobConfig(nil), jobs...)
+	a.jobsMu.Unlock()
+	return nil
+}
+
+// managedStopJobFromConfig stops an active job created from the given job
+// config. A job with the same parameters is preferred over a job with the
+// same name only. Nothing is stopped if there is no such active job, e.g. when
+// the job has already finished.
+func (a *Ant) managedStopJobFromConfig(jc JobConfig) error {
+	job, err := NewJobFromConfig(jc)
+	if err != nil {
+		job = nil
+	}
+	var id uint64
+	a.jobsMu.Lock()
+	for _, tj := range a.jobs {
+		status := tj.managedStatus()
+		if tj.staticJob.Name() != jc.Name || !status.active() {
+			continue
+		}
+		if reflect.DeepEqual(tj.staticJob, job) {
+			id = status.ID
+			break
+		}
+		if id == 0 {
+			id = status.ID
+		}
+	}
+	a.jobsMu.Unlock()
+	if id == 0 {
+		a.staticLogger.Debugf("%v: no active %v job to stop", a.Config.DataDir, jc.Name)
+		return nil
+	}
+	return a.StopJob(id)
+}
+
+// diffJobConfigs returns the job configs which are in old but not in new, and
+// the job configs which are in new but not in old. Job configs are equal if
+// their names and parameters are equal.
+func diffJobConfigs(old, new []JobConfig) (removed, added []JobConfig) {
+	key := func(jc JobConfig) string {
+		var params bytes.Buffer
+		if err := json.Compact(&params, jc.Params); err != nil {
+			params.Write(jc.Params)
+		}
+		return jc.Name + "\x00" + params.String()
+	}
+	// missing returns the configs of a which are not in b
+	missing := func(a, b []JobConfig) (m []JobConfig) {
+		counts := make(map[string]int)
+		for _, jc := range b {
+			counts[key(jc)]++
+		}
+		for _, jc := range a {
+			if k := key(jc); counts[k] > 0 {
+				counts[k]--
+				continue
+			}
+			m = append(m, jc)
+		}
+		return m
+	}
+	return missing(old, new), missing(new, old)
+}
@@ -0,0 +1,194 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/test"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// funcJob is a job running the given function, used to test job tracking.
+type funcJob struct {
+	run func(ctx context.Context, j *JobRunner) error
+}
+
+// Name implements Job.
+func (funcJob) Name() string { return "funcJob" }
+
+// Run implements Job.
+func (fj funcJob) Run(ctx context.Context, j *JobRunner) error { return fj.run(ctx, j) }
+
+// waitForJobState waits until the ant's job with the given ID reaches the
+// given state.
+func waitForJobState(a *Ant, id uint64, state JobState) error {
+	for start := time.Now(); time.Since(start) < time.Second*10; time.Sleep(time.Millisecond * 10) {
+		s, err := a.JobStatus(id)
+		if err != nil {
+			return err
+		}
+		if s.State == state {
+			return nil
+		}
+	}
+	s, _ := a.JobStatus(id)
+	return errors.New("job state is " + string(s.State) + ", expected " + string(state))
+}
+
+// TestJobStatus verifies tracking, stopping and restarting jobs.
+func TestJobStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create an ant with a job runner without siad
+	var antsSyncWG sync.WaitGroup
+	antsSyncWG.Add(1)
+	a := &Ant{staticLogger: logger}
+	a.Jr = &JobRunner{
+		staticLogger:     logger,
+		staticAntsSyncWG: &antsSyncWG,
+		staticMetrics:    NewJobMetrics(),
+	}
+	defer func() {
+		if err := a.Jr.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Start a job waiting for sync and running until stopped
+	var runs int
+	var mu sync.Mutex
+	longJob := funcJob{run: func(ctx context.Context, j *JobRunner) error {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		if err := j.waitForAntsSync(ctx); err != nil {
+			return err
+		}
+		j.recordError(ctx, MetricTransactionsFailed, errors.New("recorded error"))
+		<-ctx.Done()
+		return nil
+	}}
+	if err := a.RunJob(longJob); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForJobState(a, 1, JobStateWaitingForSync); err != nil {
+		t.Fatal(err)
+	}
+	antsSyncWG.Done()
+	if err := waitForJobState(a, 1, JobStateRunning); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start a failing and a finishing job
+	failingJob := funcJob{run: func(context.Context, *JobRunner) error { return errors.New("job error") }}
+	finishingJob := funcJob{run: func(context.Context, *JobRunner) error { return nil }}
+	if err := a.RunJob(failingJob); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RunJob(finishingJob); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForJobState(a, 2, JobStateFailed); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForJobState(a, 3, JobStateFinished); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := a.JobStatus(2); s.LastError != "job error" {
+		t.Fatalf("unexpected failed job status %+v", s)
+	}
+	statuses := a.JobStatuses()
+	if len(statuses) != 3 || statuses[0].ID != 1 || statuses[0].LastError != "recorded error" {
+		t.Fatalf("unexpected job statuses %+v", statuses)
+	}
+
+	// Stop the running job, the other jobs can't be stopped
+	if err := a.StopJob(1); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := a.JobStatus(1); s.State != JobStateStopped {
+		t.Fatalf("expected stopped job, got %+v", s)
+	}
+	if err := a.StopJob(2); err == nil {
+		t.Fatal("expected error stopping a failed job")
+	}
+	if err := a.StopJob(4); err == nil {
+		t.Fatal("expected error stopping a nonexistent job")
+	}
+
+	// Restart the stopped job, it keeps its ID
+	if err := a.RestartJob(1); err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); ; time.Sleep(time.Millisecond * 10) {
+		mu.Lock()
+		r := runs
+		mu.Unlock()
+		if r == 2 {
+			break
+		}
+		if time.Since(start) > time.Second*10 {
+			t.Fatalf("expected the job to run twice, got %v", r)
+		}
+	}
+	if err := waitForJobState(a, 1, JobStateRunning); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.JobStatuses()) != 3 {
+		t.Fatal("restarted job should not be tracked twice")
+	}
+
+	// Stopping the job runner stops the job
+	if err := a.Jr.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := a.JobStatus(1); s.State != JobStateStopped {
+		t.Fatalf("expected stopped job, got %+v", s)
+	}
+	a.Jr = &JobRunner{staticMetrics: NewJobMetrics()}
+}
+
+// TestDiffJobConfigs verifies diffing job configs.
+func TestDiffJobConfigs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	renter := JobConfig{Name: "renter", Params: json.RawMessage(`{"UploadFileSize": 100}`)}
+	renterSpaces := JobConfig{Name: "renter", Params: json.RawMessage(`{ "UploadFileSize" : 100 }`)}
+	renterOther := JobConfig{Name: "renter", Params: json.RawMessage(`{"UploadFileSize": 200}`)}
+	gateway := JobConfig{Name: "gateway"}
+
+	tests := []struct {
+		name           string
+		old, new       []JobConfig
+		removed, added []JobConfig
+	}{
+		{"unchanged", []JobConfig{gateway, renter}, []JobConfig{renterSpaces, gateway}, nil, nil},
+		{"added", []JobConfig{gateway}, []JobConfig{gateway, gateway}, nil, []JobConfig{gateway}},
+		{"removed", []JobConfig{gateway, renter}, []JobConfig{renter}, []JobConfig{gateway}, nil},
+		{"changed params", []JobConfig{renter}, []JobConfig{renterOther}, []JobConfig{renter}, []JobConfig{renterOther}},
+	}
+	for _, tt := range tests {
+		removed, added := diffJobConfigs(tt.old, tt.new)
+		if !reflect.DeepEqual(removed, tt.removed) || !reflect.DeepEqual(added, tt.added) {
+			t.Errorf("%v: expected removed %v and added %v, got %v and %v", tt.name, tt.removed, tt.added, removed, added)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package ant
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricName defines type for job metric names
+type MetricName string
+
+// Counter metric names of the built-in jobs
+const (
+	// Renter metrics
+	MetricAllowanceSetFailed MetricName = "renter_allowance_set_failed"
+	MetricDeletesCompleted   MetricName = "renter_deletes_completed"
+	MetricDeletesFailed      MetricName = "renter_deletes_failed"
+	MetricDownloadsCompleted MetricName = "renter_downloads_completed"
+	MetricDownloadsFailed    MetricName = "renter_downloads_failed"
+	MetricDownloadsStarted   MetricName = "renter_downloads_started"
+	MetricUploadsCompleted   MetricName = "renter_uploads_completed"
+	MetricUploadsFailed      MetricName = "renter_uploads_failed"
+	MetricUploadsStarted     MetricName = "renter_uploads_started"
+
+	// Host metrics
+	MetricAnnouncements            MetricName = "host_announcements"
+	MetricAnnouncementsFailed      MetricName = "host_announcements_failed"
+	MetricAnnouncementsRetried     MetricName = "host_announcements_retried"
+	MetricStorageRevenueDecreased  MetricName = "host_storage_revenue_decreased"
+	MetricHostInitialBalanceFailed MetricName = "host_initial_balance_failed"
+
+	// Miner metrics
+	MetricMinerBalanceChecksFailed    MetricName = "miner_balance_checks_failed"
+	MetricMinerBalanceChecksSucceeded MetricName = "miner_balance_checks_succeeded"
+
+	// Gateway metrics
+	MetricGatewayChecksFailed    MetricName = "gateway_checks_failed"
+	MetricGatewayChecksSucceeded MetricName = "gateway_checks_succeeded"
+
+	// Wallet metrics
+	MetricTransactionsFailed MetricName = "wallet_transactions_failed"
+	MetricTransactionsSent   MetricName = "wallet_transactions_sent"
+)
+
+// Histogram metric names of the built-in jobs. Histograms of durations are
+// observed in seconds.
+const (
+	MetricDownloadDuration MetricName = "renter_download_duration_seconds"
+	MetricUploadDuration   MetricName = "renter_upload_duration_seconds"
+)
+
+const (
+	// maxRecordedErrors defines the maximum number of job errors kept in job
+	// metrics, older errors are dropped.
+	maxRecordedErrors = 100
+
+	// maxRecordedTransfers defines the maximum number of transfers kept in job
+	// metrics, older transfers are dropped.
+	maxRecordedTransfers = 1000
+)
+
+// TransferType defines type for transfer Type enum
+type TransferType string
+
+// TransferType constants define values for transfer Type enum
+const (
+	TransferDownload TransferType = "download"
+	TransferUpload   TransferType = "upload"
+)
+
+var (
+	// durationBuckets defines upper bounds (in seconds) of histogram buckets
+	// used for durations.
+	durationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+)
+
+type (
+	// JobMetrics is a registry of counters and histograms collected by
+	// ant's jobs. Besides the metrics it keeps a history of the ant's run:
+	// started jobs, job errors, transfers and used siad versions. It is safe
+	// for concurrent use.
+	JobMetrics struct {
+		counters     map[MetricName]uint64
+		histograms   map[MetricName]*HistogramSnapshot
+		jobs         map[string]uint64
+		errors       []JobError
+		transfers    []TransferRecord
+		siadVersions []string
+		mu           sync.Mutex
+	}
+
+	// JobError records a job error.
+	JobError struct {
+		Time   time.Time
+		Metric MetricName
+		Error  string
+	}
+
+	// TransferRecord records a finished upload or download. Error is empty if
+	// the transfer succeeded.
+	TransferRecord struct {
+		Type     TransferType
+		SiaPath  string
+		Size     uint64
+		Start    time.Time
+		Duration time.Duration
+		Error    string `json:",omitempty"`
+	}
+
+	// HistogramSnapshot contains observations of a histogram metric.
+	HistogramSnapshot struct {
+		// Buckets contains upper bounds of histogram buckets.
+		Buckets []float64
+
+		// BucketCounts contains number of observations less than or equal to
+		// the upper bound of the bucket with the same index, i.e. the counts
+		// are cumulative.
+		BucketCounts []uint64
+
+		Count uint64
+		Sum   float64
+		Min   float64
+		Max   float64
+	}
+
+	// MetricsSnapshot contains values of all job metrics at a point of time.
+	MetricsSnapshot struct {
+		Counters   map[MetricName]uint64
+		Histograms map[MetricName]HistogramSnapshot
+
+		// Jobs contains how many times each job was started.
+		Jobs map[string]uint64
+
+		Errors       []JobError
+		Transfers    []TransferRecord
+		SiadVersions []string
+	}
+)
+
+// NewJobMetrics returns a new empty job metrics registry.
+func NewJobMetrics() *JobMetrics {
+	return &JobMetrics{
+		counters:   make(map[MetricName]uint64),
+		histograms: make(map[MetricName]*HistogramSnapshot),
+		jobs:       make(map[string]uint64),
+	}
+}
+
+// IncCounter increments the counter with the given name.
+func (m *JobMetrics) IncCounter(name MetricName) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+}
+
+// RecordError increments the counter with the given name and records the
+// error.
+func (m *JobMetrics) RecordError(name MetricName, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+	m.errors = append(m.errors, JobError{
+		Time:   time.Now(),
+		Metric: name,
+		Error:  err.Error(),
+	})
+	if len(m.errors) > maxRecordedErrors {
+		m.errors = m.errors[len(m.errors)-maxRecordedErrors:]
+	}
+}
+
+// RecordJob records that the job with the given name was started.
+func (m *JobMetrics) RecordJob(job string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job]++
+}
+
+// RecordSiadVersion records the siad version the ant runs. The version is
+// not recorded again if it equals the last recorded version.
+func (m *JobMetrics) RecordSiadVersion(version string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n := len(m.siadVersions); n > 0 && m.siadVersions[n-1] == version {
+		return
+	}
+	m.siadVersions = append(m.siadVersions, version)
+}
+
+// RecordTransfer records a finished upload or download.
+func (m *JobMetrics) RecordTransfer(t TransferRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transfers = append(m.transfers, t)
+	if len(m.transfers) > maxRecordedTransfers {
+		m.transfers = m.transfers[len(m.transfers)-maxRecordedTransfers:]
+	}
+}
+
+// Observe adds the given value to the histogram with the given name.
+func (m *JobMetrics) Observe(name MetricName, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.histograms[name]
+	if !ok {
+		h = &HistogramSnapshot{
+			Buckets:      durationBuckets,
+			BucketCounts: make([]uint64, len(durationBuckets)),
+			Min:          value,
+			Max:          value,
+		}
+		m.histograms[name] = h
+	}
+	for i, upperBound := range h.Buckets {
+		if value <= upperBound {
+			h.BucketCounts[i]++
+		}
+	}
+	if value < h.Min {
+		h.Min = value
+	}
+	if value > h.Max {
+		h.Max = value
+	}
+	h.Count++
+	h.Sum += value
+}
+
+// ObserveDuration adds the given duration in seconds to the histogram with
+// the given name.
+func (m *JobMetrics) ObserveDuration(name MetricName, d time.Duration) {
+	m.Observe(name, d.Seconds())
+}
+
+// Snapshot returns a copy of the current values of all metrics.
+func (m *JobMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := MetricsSnapshot{
+		Counters:   make(map[MetricName]uint64, len(m.counters)),
+		Histograms: make(map[MetricName]HistogramSnapshot, len(m.histograms)),
+		Jobs:       make(map[string]uint64, len(m.jobs)),
+
+		Errors:       append([]JobError{}, m.errors...),
+		Transfers:    append([]TransferRecord{}, m.transfers...),
+		SiadVersions: append([]string{}, m.siadVersions...),
+	}
+	for job, count := range m.jobs {
+		s.Jobs[job] = count
+	}
+	for name, value := range m.counters {
+		s.Counters[name] = value
+	}
+	for name, h := range m.histograms {
+		hCopy := *h
+		hCopy.BucketCounts = append([]uint64{}, h.BucketCounts...)
+		s.Histograms[name] = hCopy
+	}
+	return s
+}
+
+// CounterNames returns sorted names of the counters in the snapshot.
+func (s MetricsSnapshot) CounterNames() []MetricName {
+	names := make([]MetricName, 0, len(s.Counters))
+	for name := range s.Counters {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// HistogramNames returns sorted names of the histograms in the snapshot.
+func (s MetricsSnapshot) HistogramNames() []MetricName {
+	names := make([]MetricName, 0, len(s.Histograms))
+	for name := range s.Histograms {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
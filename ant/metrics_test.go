@@ -0,0 +1,105 @@
+package ant
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestJobMetrics tests counters and histograms of the job metrics registry.
+func TestJobMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	m := NewJobMetrics()
+	m.IncCounter(MetricUploadsStarted)
+	m.IncCounter(MetricUploadsStarted)
+	m.IncCounter(MetricUploadsCompleted)
+	m.ObserveDuration(MetricUploadDuration, 3*time.Second)
+	m.ObserveDuration(MetricUploadDuration, 45*time.Second)
+
+	s := m.Snapshot()
+	if s.Counters[MetricUploadsStarted] != 2 {
+		t.Fatalf("expected 2 started uploads, got %v", s.Counters[MetricUploadsStarted])
+	}
+	if s.Counters[MetricUploadsFailed] != 0 {
+		t.Fatalf("expected 0 failed uploads, got %v", s.Counters[MetricUploadsFailed])
+	}
+	names := s.CounterNames()
+	if len(names) != 2 || names[0] != MetricUploadsCompleted || names[1] != MetricUploadsStarted {
+		t.Fatalf("unexpected sorted counter names: %v", names)
+	}
+
+	h, ok := s.Histograms[MetricUploadDuration]
+	if !ok {
+		t.Fatal("upload duration histogram is missing")
+	}
+	if h.Count != 2 || h.Sum != 48 || h.Min != 3 || h.Max != 45 {
+		t.Fatalf("unexpected histogram values: %+v", h)
+	}
+	for i, upperBound := range h.Buckets {
+		var expected uint64
+		if upperBound >= 45 {
+			expected = 2
+		} else if upperBound >= 3 {
+			expected = 1
+		}
+		if h.BucketCounts[i] != expected {
+			t.Fatalf("bucket %v: expected count %v, got %v", upperBound, expected, h.BucketCounts[i])
+		}
+	}
+
+	// Snapshot is a copy
+	m.ObserveDuration(MetricUploadDuration, time.Second)
+	if s.Histograms[MetricUploadDuration].Count != 2 || s.Histograms[MetricUploadDuration].BucketCounts[0] != 0 {
+		t.Fatal("snapshot was modified by a new observation")
+	}
+}
+
+// TestJobMetricsRunHistory verifies that job metrics record jobs, errors,
+// transfers and siad versions.
+func TestJobMetricsRunHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	m := NewJobMetrics()
+	m.RecordJob("renter")
+	m.RecordJob("renter")
+	m.RecordError(MetricDeletesFailed, errors.New("delete failed"))
+	m.RecordTransfer(TransferRecord{Type: TransferUpload, SiaPath: "file", Size: 10})
+	m.RecordSiadVersion("1.5.7")
+	m.RecordSiadVersion("1.5.7")
+	m.RecordSiadVersion("1.5.8")
+
+	s := m.Snapshot()
+	if s.Jobs["renter"] != 2 {
+		t.Fatalf("expected renter job to be started twice, got %v", s.Jobs["renter"])
+	}
+	if s.Counters[MetricDeletesFailed] != 1 {
+		t.Fatalf("expected error to increment the counter, got %v", s.Counters[MetricDeletesFailed])
+	}
+	if len(s.Errors) != 1 || s.Errors[0].Metric != MetricDeletesFailed || s.Errors[0].Error != "delete failed" {
+		t.Fatalf("unexpected recorded errors: %+v", s.Errors)
+	}
+	if len(s.Transfers) != 1 || s.Transfers[0].Size != 10 {
+		t.Fatalf("unexpected recorded transfers: %+v", s.Transfers)
+	}
+	if len(s.SiadVersions) != 2 || s.SiadVersions[0] != "1.5.7" || s.SiadVersions[1] != "1.5.8" {
+		t.Fatalf("unexpected recorded siad versions: %v", s.SiadVersions)
+	}
+
+	// Errors are limited
+	for i := 0; i < maxRecordedErrors; i++ {
+		m.RecordError(MetricDeletesFailed, fmt.Errorf("error %v", i))
+	}
+	s = m.Snapshot()
+	if len(s.Errors) != maxRecordedErrors || s.Errors[0].Error != "error 0" {
+		t.Fatalf("expected %v last errors to be kept, got %v starting with %v", maxRecordedErrors, len(s.Errors), s.Errors[0].Error)
+	}
+}
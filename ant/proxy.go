@@ -0,0 +1,47 @@
+package ant
+
+import (
+	"go.sia.tech/sia-antfarm/proxy"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// startProxies starts the traffic shaping proxies in front of the ant's siad
+// RPC, host and SiaMux addresses. It is a no-op if the ant has no proxy
+// configured or the proxies are already running.
+func (a *Ant) startProxies() error {
+	if a.Config.Proxy == nil || a.proxies != nil {
+		return nil
+	}
+	c := a.Config.SiadConfig
+	addrs := []struct {
+		public, internal string
+	}{
+		{c.RPCAddr, c.InternalRPCAddr},
+		{c.HostAddr, c.InternalHostAddr},
+		{c.SiaMuxAddr, c.InternalSiaMuxAddr},
+	}
+	for _, addr := range addrs {
+		if addr.internal == "" {
+			a.closeProxies()
+			return errors.New("ant with a proxy requires internal siad addresses")
+		}
+		p, err := proxy.New(addr.public, addr.internal, *a.Config.Proxy)
+		if err != nil {
+			a.closeProxies()
+			return errors.AddContext(err, "can't start proxy for "+addr.public)
+		}
+		a.proxies = append(a.proxies, p)
+	}
+	a.staticLogger.Debugf("%v: started proxies with config %+v", c.DataDir, *a.Config.Proxy)
+	return nil
+}
+
+// closeProxies closes the ant's proxies.
+func (a *Ant) closeProxies() {
+	for _, p := range a.proxies {
+		if err := p.Close(); err != nil {
+			a.staticLogger.Errorf("%v: can't close proxy: %v", a.Config.DataDir, err)
+		}
+	}
+	a.proxies = nil
+}
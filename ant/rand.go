@@ -0,0 +1,78 @@
+package ant
+
+import (
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"sync"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// antRand is the source of an ant's random file contents and random choices.
+// If the ant has a seed, the values are derived deterministically from the
+// seed, otherwise they are taken from fastrand. It is safe for concurrent use,
+// a nil antRand takes the values from fastrand.
+type antRand struct {
+	// r is nil if the ant has no seed.
+	r    *rand.Rand
+	seed string
+	mu   sync.Mutex
+}
+
+// newAntRand creates a new ant random source from the given seed, an empty
+// seed means random values.
+func newAntRand(seed string) *antRand {
+	if seed == "" {
+		return &antRand{}
+	}
+	h := crypto.HashAll("rand", seed)
+	return &antRand{
+		r:    rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(h[:])))),
+		seed: seed,
+	}
+}
+
+// derive returns a random source with its own stream derived from the seed
+// and the given name, so that the values of goroutines using different
+// derived sources don't depend on each other's timing. Without a seed, the
+// derived source takes the values from fastrand.
+func (ar *antRand) derive(name string) *antRand {
+	if ar == nil || ar.r == nil {
+		return &antRand{}
+	}
+	return newAntRand(ar.seed + "/" + name)
+}
+
+// Intn returns a random number in [0,n).
+func (ar *antRand) Intn(n int) int {
+	if ar == nil || ar.r == nil {
+		return fastrand.Intn(n)
+	}
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	return ar.r.Intn(n)
+}
+
+// Reader returns a reader of random bytes. With a seed, each returned reader
+// produces its own deterministic stream, so that concurrent readers don't
+// affect each other's contents.
+func (ar *antRand) Reader() io.Reader {
+	if ar == nil || ar.r == nil {
+		return fastrand.Reader
+	}
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	return rand.New(rand.NewSource(ar.r.Int63()))
+}
+
+// walletSeedFromSeed derives the ant's wallet seed from the ant's seed.
+func walletSeedFromSeed(seed string) (string, error) {
+	var s modules.Seed
+	h := crypto.HashAll("wallet", seed)
+	copy(s[:], h[:])
+	return modules.SeedToString(s, mnemonics.English)
+}
@@ -0,0 +1,87 @@
+package ant
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"go.sia.tech/siad/modules"
+	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
+)
+
+// TestAntRand verifies that the random file contents, random choices and
+// wallet seeds of ants with the same seed are equal.
+func TestAntRand(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// values returns random choices and file contents of the ant random
+	// source
+	values := func(ar *antRand) ([]int, []byte) {
+		var choices []int
+		for i := 0; i < 10; i++ {
+			choices = append(choices, ar.Intn(1000))
+		}
+		content := make([]byte, 64)
+		if _, err := io.ReadFull(ar.Reader(), content); err != nil {
+			t.Fatal(err)
+		}
+		return choices, content
+	}
+	choices1, content1 := values(newAntRand("seed"))
+	choices2, content2 := values(newAntRand("seed"))
+	_, content3 := values(newAntRand("other seed"))
+	for i := range choices1 {
+		if choices1[i] != choices2[i] {
+			t.Fatalf("expected equal choices, got %v and %v", choices1, choices2)
+		}
+	}
+	if !bytes.Equal(content1, content2) {
+		t.Fatal("expected equal file contents of equal seeds")
+	}
+	if bytes.Equal(content1, content3) {
+		t.Fatal("expected different file contents of different seeds")
+	}
+
+	// Ants without a seed use fastrand
+	var nilRand *antRand
+	if _, content := values(nilRand); bytes.Equal(content, content1) {
+		t.Fatal("expected random file contents without a seed")
+	}
+	if _, content := values(nilRand.derive("upload")); bytes.Equal(content, content1) {
+		t.Fatal("expected random file contents of a derived source without a seed")
+	}
+
+	// Derived sources are deterministic and independent of the parent
+	// source's and each other's use
+	parent := newAntRand("seed")
+	upload := parent.derive("upload")
+	values(parent)
+	values(parent.derive("download"))
+	_, derived1 := values(upload)
+	_, derived2 := values(newAntRand("seed").derive("upload"))
+	if !bytes.Equal(derived1, derived2) {
+		t.Fatal("expected equal file contents of equally derived sources")
+	}
+	if bytes.Equal(derived1, content1) {
+		t.Fatal("expected different file contents of the derived and the parent source")
+	}
+
+	// Wallet seeds are valid and deterministic
+	seed1, err := walletSeedFromSeed("seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed2, err := walletSeedFromSeed("seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed1 != seed2 {
+		t.Fatalf("expected equal wallet seeds, got %v and %v", seed1, seed2)
+	}
+	if _, err := modules.StringToSeed(seed1, mnemonics.English); err != nil {
+		t.Fatalf("invalid wallet seed %v: %v", seed1, err)
+	}
+}
@@ -7,12 +7,14 @@ package ant
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"go.sia.tech/sia-antfarm/persist"
@@ -32,6 +34,10 @@ const (
 	// waitForFullSetupTimeout defines timeout for waiting for Sia daemon to
 	// finish full setup
 	waitForFullSetupTimeout = time.Second * 20
+
+	// siadLogFilename is the name of the file in the ant's data directory
+	// siad's stdout and stderr are appended to.
+	siadLogFilename = "sia-output.log"
 )
 
 // SiadConfig contains the necessary config information to create a new siad
@@ -47,24 +53,136 @@ type SiadConfig struct {
 	SiaMuxWsAddr                  string
 	AllowHostLocalNetAddress      bool
 	RenterDisableIPViolationCheck bool
+
+	// InternalRPCAddr, InternalHostAddr and InternalSiaMuxAddr are the
+	// addresses siad listens on when the ant runs behind a proxy. The proxy
+	// listens on RPCAddr, HostAddr and SiaMuxAddr and forwards connections to
+	// the internal addresses.
+	InternalRPCAddr    string `json:",omitempty"`
+	InternalHostAddr   string `json:",omitempty"`
+	InternalSiaMuxAddr string `json:",omitempty"`
+
+	// ExternalSiad defines whether the ant connects to a siad API which is
+	// already served at APIAddr, e.g. by a mock siad in tests, instead of
+	// spawning a siad process. The external siad isn't killed when the ant
+	// is closed, it is only asked to stop through its API.
+	ExternalSiad bool `json:",omitempty"`
+}
+
+// listenAddr returns the address siad should listen on, i.e. the internal
+// address if the ant runs behind a proxy, otherwise the public address.
+func listenAddr(publicAddr, internalAddr string) string {
+	if internalAddr != "" {
+		return internalAddr
+	}
+	return publicAddr
+}
+
+// startSiad starts the ant's siad and waits for its API to become available.
+// It returns the spawned siad process, or nil if the ant uses an external
+// siad.
+func startSiad(ctx context.Context, logger *persist.Logger, config SiadConfig) (*siadProcess, error) {
+	if config.ExternalSiad {
+		return nil, waitForExternalSiad(ctx, config)
+	}
+	return newSiad(ctx, logger, config)
+}
+
+// waitForExternalSiad blocks until the API of the external siad at the
+// configured API address responds. It returns an error if a timeout occurs or
+// the context is cancelled.
+func waitForExternalSiad(ctx context.Context, config SiadConfig) error {
+	c, err := newClient(config.APIAddr, config.APIPassword)
+	if err != nil {
+		return errors.AddContext(err, "can't create a new client")
+	}
+	start := time.Now()
+	for {
+		_, err := c.DaemonVersionGet()
+		if err == nil {
+			return nil
+		}
+		if time.Since(start) > waitForFullSetupTimeout {
+			return errors.AddContext(err, fmt.Sprintf("external siad API isn't available within %v timeout", waitForFullSetupTimeout))
+		}
+		select {
+		case <-ctx.Done():
+			return errors.AddContext(ctx.Err(), "waiting for external siad was cancelled")
+		case <-time.After(waitForFullSetupFrequency):
+		}
+	}
+}
+
+// siadProcess is a spawned siad process. The process is waited for by a single
+// goroutine, so that the setup, the ant's supervisor and stopping siad can all
+// observe its exit.
+type siadProcess struct {
+	staticCmd *exec.Cmd
+
+	// staticLogOffset is the size of the siad log file before the process
+	// was started, the process' output starts at this offset.
+	staticLogOffset int64
+
+	// staticExited is closed when the process exits, exitErr is the error
+	// returned by waiting for the command, e.g. an *exec.ExitError if the
+	// process exited with a non-zero status or was killed. It is set before
+	// staticExited is closed and must not be read before.
+	staticExited chan struct{}
+	exitErr      error
+
+	// staticStopped is closed when the process is stopped by the ant, so
+	// that its exit isn't a crash.
+	staticStopped chan struct{}
+	stopOnce      sync.Once
+}
+
+// threadedWait waits for the siad process to exit. The command is waited for
+// instead of the process, so that a non-zero exit status is an error.
+func (p *siadProcess) threadedWait() {
+	p.exitErr = p.staticCmd.Wait()
+	close(p.staticExited)
+}
+
+// markStopped marks the siad process as stopped by the ant. It is safe to
+// call on a nil process and to call multiple times.
+func (p *siadProcess) markStopped() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.staticStopped)
+	})
+}
+
+// stopped returns true if the siad process was stopped by the ant.
+func (p *siadProcess) stopped() bool {
+	select {
+	case <-p.staticStopped:
+		return true
+	default:
+		return false
+	}
 }
 
 // newSiad spawns a new siad process using os/exec and waits for the api to
 // become available.  siadPath is the path to Siad, passed directly to
-// exec.Command.  An error is returned if starting siad fails, otherwise a
-// pointer to siad's os.Cmd object is returned.  The data directory `datadir`
-// is passed as siad's `--sia-directory`.
-func newSiad(logger *persist.Logger, config SiadConfig) (*exec.Cmd, error) {
+// exec.Command.  An error is returned if starting siad fails or the context is
+// cancelled, otherwise the spawned siad process is returned.  The data
+// directory `datadir` is passed as siad's `--sia-directory`.
+func newSiad(ctx context.Context, logger *persist.Logger, config SiadConfig) (*siadProcess, error) {
 	if err := checkSiadConstants(config.SiadPath); err != nil {
 		return nil, errors.AddContext(err, "error with siad constants")
 	}
 	// Create a logfile for Sia's stderr and stdout.
-	logFilename := "sia-output.log"
-	logFilePath := filepath.Join(config.DataDir, logFilename)
+	logFilePath := filepath.Join(config.DataDir, siadLogFilename)
 	logfile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, modules.DefaultFilePerm)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to create log file")
 	}
+	logInfo, err := logfile.Stat()
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to stat log file")
+	}
 
 	// Create siad config arguments
 	args := []string{
@@ -72,8 +190,8 @@ func newSiad(logger *persist.Logger, config SiadConfig) (*exec.Cmd, error) {
 		"--no-bootstrap",
 		"--sia-directory=" + config.DataDir,
 		"--api-addr=" + config.APIAddr,
-		"--rpc-addr=" + config.RPCAddr,
-		"--host-addr=" + config.HostAddr,
+		"--rpc-addr=" + listenAddr(config.RPCAddr, config.InternalRPCAddr),
+		"--host-addr=" + listenAddr(config.HostAddr, config.InternalHostAddr),
 	}
 
 	// Set siamux only if it is supported by given siad version
@@ -82,7 +200,7 @@ func newSiad(logger *persist.Logger, config SiadConfig) (*exec.Cmd, error) {
 		return nil, errors.AddContext(err, "can't determine siamux support")
 	}
 	if siamuxSupported {
-		args = append(args, "--siamux-addr="+config.SiaMuxAddr)
+		args = append(args, "--siamux-addr="+listenAddr(config.SiaMuxAddr, config.InternalSiaMuxAddr))
 	}
 
 	// Set siamux WS only if it is supported by given siad version
@@ -123,14 +241,34 @@ func newSiad(logger *persist.Logger, config SiadConfig) (*exec.Cmd, error) {
 	if err := cmd.Start(); err != nil {
 		return nil, errors.AddContext(err, "unable to start process")
 	}
+	siad := &siadProcess{
+		staticCmd:       cmd,
+		staticLogOffset: logInfo.Size(),
+		staticExited:    make(chan struct{}),
+		staticStopped:   make(chan struct{}),
+	}
+	go siad.threadedWait()
 
 	// Wait until siad full setup is finished
-	err = waitForFullSetup(logger, config, cmd, &buf)
+	err = waitForFullSetup(ctx, logger, config, siad, &buf)
 	if err != nil {
 		return nil, errors.AddContext(err, "wait for siad full setup failed")
 	}
 
-	return cmd, nil
+	return siad, nil
+}
+
+// CheckSiad verifies that the siad binary at the given path, or in PATH if
+// the path is a file name, exists and runs the required dev constants. It
+// doesn't start siad.
+func CheckSiad(siadPath string) error {
+	if _, err := exec.LookPath(siadPath); err != nil {
+		return errors.AddContext(err, "can't find siad binary")
+	}
+	if err := checkSiadConstants(siadPath); err != nil {
+		return errors.AddContext(err, "error with siad constants")
+	}
+	return nil
 }
 
 // checkSiadConstants runs `siad version` and verifies that the supplied siad
@@ -166,8 +304,19 @@ func siadFlagSupported(siadPath, flag string) (bool, error) {
 }
 
 // stopSiad tries to stop the siad running at `apiAddr`, issuing a kill to its
-// `process` after a timeout.
-func stopSiad(logger *persist.Logger, dataDir string, apiAddr, apiPassword string, process *os.Process) {
+// `siad` process after a timeout. A nil `siad` means an external siad, which
+// is only asked to stop through its API.
+func stopSiad(logger *persist.Logger, dataDir string, apiAddr, apiPassword string, siad *siadProcess) {
+	siad.markStopped()
+	if siad != nil {
+		// A crashed siad has already exited
+		select {
+		case <-siad.staticExited:
+			return
+		default:
+		}
+	}
+
 	opts, err := client.DefaultOptions()
 	if err != nil {
 		panic(err)
@@ -176,52 +325,50 @@ func stopSiad(logger *persist.Logger, dataDir string, apiAddr, apiPassword strin
 	opts.Password = apiPassword
 	if err := client.New(opts).DaemonStopGet(); err != nil {
 		logger.Errorf("%v: can't stop siad daemon: %v", dataDir, err)
-		if er := process.Kill(); er != nil {
+		if siad == nil {
+			return
+		}
+		if er := siad.staticCmd.Process.Kill(); er != nil {
 			logger.Errorf("%v: can't kill siad process: %v", dataDir, er)
 		}
 	}
+	if siad == nil {
+		return
+	}
 
 	// wait for 120 seconds for siad to terminate, then issue a kill signal.
-	done := make(chan error)
-	go func() {
-		_, err := process.Wait()
-		done <- err
-	}()
 	select {
-	case <-done:
+	case <-siad.staticExited:
 	case <-time.After(stopSiadTimeout):
-		if err := process.Kill(); err != nil {
+		if err := siad.staticCmd.Process.Kill(); err != nil {
 			logger.Errorf("%v: can't kill siad process: %v", dataDir, err)
 		}
 	}
 }
 
 // waitForFullSetup blocks until the Sia daemon finishes full setup. If siad
-// terminates while waiting for full setup or a timeout occurs, returns an
-// error. siadOutput expects to receive combined siad stdin and stderr output.
-func waitForFullSetup(logger *persist.Logger, config SiadConfig, siad *exec.Cmd, siadOutput *bytes.Buffer) error {
-	// Prepare channel if siad process terminates
-	exitChan := make(chan error)
-	go func() {
-		_, err := siad.Process.Wait()
-		exitChan <- err
-	}()
-
+// terminates while waiting for full setup, a timeout occurs or the context is
+// cancelled, returns an error. siadOutput expects to receive combined siad
+// stdin and stderr output.
+func waitForFullSetup(ctx context.Context, logger *persist.Logger, config SiadConfig, siad *siadProcess, siadOutput *bytes.Buffer) error {
 	// Wait for siad full setup finished
 	start := time.Now()
 	var logContent string
 	for {
 		select {
-		case err := <-exitChan:
+		case <-siad.staticExited:
 			// Siad process terminated
 			errMsg := errors.New("siad exited unexpectedly while waiting for full setup")
-			return errors.Compose(errMsg, err)
+			return errors.Compose(errMsg, siad.exitErr)
+		case <-ctx.Done():
+			stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad)
+			return errors.AddContext(ctx.Err(), "siad full setup was cancelled")
 		case <-time.After(waitForFullSetupFrequency):
 		}
 
 		// Timeout
 		if time.Since(start) > waitForFullSetupTimeout {
-			stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad.Process)
+			stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad)
 			return fmt.Errorf("siad hasn't finished full setup within %v timeout", waitForFullSetupTimeout)
 		}
 
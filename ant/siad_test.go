@@ -1,11 +1,17 @@
 package ant
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"go.sia.tech/sia-antfarm/siadmock"
 	"go.sia.tech/sia-antfarm/test"
 	"go.sia.tech/siad/node/api/client"
 	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
 	"gitlab.com/NebulousLabs/errors"
 )
 
@@ -53,7 +59,7 @@ func TestNewSiad(t *testing.T) {
 	}()
 
 	// Create the siad process
-	siad, err := newSiad(logger, config)
+	siad, err := newSiad(context.Background(), logger, config)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -72,18 +78,157 @@ func TestNewSiad(t *testing.T) {
 	}
 
 	// Stop siad process
-	stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad.Process)
+	stopSiad(logger, config.DataDir, config.APIAddr, config.APIPassword, siad)
 
 	// Test Creating siad with a blank config
-	_, err = newSiad(logger, SiadConfig{})
+	_, err = newSiad(context.Background(), logger, SiadConfig{})
 	if err == nil {
 		t.Fatal("Shouldn't be able to create siad process with empty config")
 	}
 
+	// Creating siad is aborted when the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = newSiad(ctx, logger, config)
+	if !errors.Contains(err, context.Canceled) {
+		t.Fatalf("expected cancelled siad creation, got %v", err)
+	}
+
 	// verify that NewSiad returns an error given invalid args
 	config.APIAddr = "this_is_an_invalid_address:1000000"
-	_, err = newSiad(logger, config)
+	_, err = newSiad(context.Background(), logger, config)
 	if err == nil {
 		t.Fatal("expected newsiad to return an error with invalid args")
 	}
 }
+
+// newMockSiadConfig starts a mock siad and returns it with a SiadConfig of an
+// external siad served by the mock.
+func newMockSiadConfig(t *testing.T, datadir string) (*siadmock.Server, SiadConfig) {
+	mock, err := siadmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := SiadConfig{
+		APIAddr:      mock.Address(),
+		DataDir:      datadir,
+		ExternalSiad: true,
+	}
+	return mock, sc
+}
+
+// TestExternalSiad tests that an ant runs on an external siad without
+// spawning a siad process.
+func TestExternalSiad(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a mock siad
+	dataDir := test.TestDir(t.Name())
+	mock, config := newMockSiadConfig(t, dataDir)
+	defer func() {
+		if err := mock.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Creating the ant fails with an injected error
+	mock.SetErrorTimes("GET /wallet", errors.New("injected wallet error"), 1)
+	_, err := New(&sync.WaitGroup{}, logger, AntConfig{SiadConfig: config})
+	if err == nil || !strings.Contains(err.Error(), "injected wallet error") {
+		t.Fatalf("expected injected wallet error, got %v", err)
+	}
+
+	// Create the ant, its wallet is initialized and unlocked
+	ant, err := New(&sync.WaitGroup{}, logger, AntConfig{SiadConfig: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := mock.State()
+	if !state.Wallet.Unlocked {
+		t.Fatal("expected the ant to unlock the wallet")
+	}
+	if state.PrimarySeed != ant.Jr.StaticWalletSeed {
+		t.Fatal("expected the ant's wallet seed to equal the mock's primary seed")
+	}
+
+	// Closing the ant asks the external siad to stop
+	if err := ant.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := mock.Requests("GET /daemon/stop"); n != 2 {
+		t.Fatalf("expected 2 stop requests, got %v", n)
+	}
+}
+
+// testFaucet is a faucet recording the targets of the fund requests.
+type testFaucet struct {
+	targets chan types.Currency
+}
+
+// Fund implements Faucet.
+func (f testFaucet) Fund(_ *Ant, target types.Currency) error {
+	select {
+	case f.targets <- target:
+	default:
+	}
+	return nil
+}
+
+// TestNewWithFaucet verifies that the faucet of an ant is set before the
+// ant's jobs start, so that the first fund request doesn't fail.
+func TestNewWithFaucet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a mock siad
+	dataDir := test.TestDir(t.Name())
+	mock, config := newMockSiadConfig(t, dataDir)
+	defer func() {
+		if err := mock.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The balance maintainer of an ant using the faucet requests the desired
+	// currency from the faucet
+	faucet := testFaucet{targets: make(chan types.Currency, 1)}
+	antConfig := AntConfig{SiadConfig: config, DesiredCurrency: 100, UseFaucet: true}
+	ant, err := NewWithFaucet(context.Background(), &sync.WaitGroup{}, logger, antConfig, faucet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ant.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	select {
+	case target := <-faucet.targets:
+		if expected := types.SiacoinPrecision.Mul64(100); !target.Equals(expected) {
+			t.Fatalf("expected fund request of %v, got %v", expected, target)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("expected a fund request")
+	}
+}
@@ -0,0 +1,307 @@
+package ant
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// RestartPolicyType defines type for siad restart policy enum
+type RestartPolicyType string
+
+// RestartPolicyType constants define values for siad restart policy enum
+const (
+	// RestartNever defines a policy never restarting a crashed siad.
+	RestartNever RestartPolicyType = "never"
+
+	// RestartOnFailure defines a policy restarting siad after it exits with
+	// an error, e.g. a panic or a kill signal.
+	RestartOnFailure RestartPolicyType = "on-failure"
+)
+
+var (
+	// errSupervisorStopped is returned when a crashed siad isn't restarted
+	// because its supervisor was stopped.
+	errSupervisorStopped = errors.New("siad supervisor was stopped")
+)
+
+const (
+	// crashLogTailLines defines the number of siad log lines captured on a
+	// crash.
+	crashLogTailLines = 50
+
+	// crashLogMaxBytes defines the maximum number of bytes read from the end
+	// of siad's output on a crash.
+	crashLogMaxBytes = 1 << 20
+
+	// defaultRestartBackoff defines the wait before the first restart of a
+	// crashed siad if the restart policy doesn't set it.
+	defaultRestartBackoff = time.Second
+
+	// maxRestartBackoff defines the maximum wait before restarting a crashed
+	// siad.
+	maxRestartBackoff = time.Minute
+)
+
+type (
+	// RestartPolicy defines whether and how an ant's siad is restarted after
+	// it crashes.
+	RestartPolicy struct {
+		// Policy is "never" (default) or "on-failure".
+		Policy RestartPolicyType
+
+		// BackoffMilliseconds is the wait before the first restart, it is
+		// doubled for each following restart up to a minute. Defaults to a
+		// second.
+		BackoffMilliseconds uint64 `json:",omitempty"`
+
+		// MaxRestarts is the maximum number of restarts of the ant's siad, 0
+		// means unlimited restarts.
+		MaxRestarts uint64 `json:",omitempty"`
+	}
+
+	// Crash describes an exit of an ant's siad which wasn't stopped by the
+	// ant.
+	Crash struct {
+		Time time.Time
+
+		// ExitError is the error siad exited with, it is empty if siad exited
+		// with status 0.
+		ExitError string `json:",omitempty"`
+
+		// LogTail contains the last lines of siad's output in the ant's
+		// sia-output.log.
+		LogTail string
+
+		// PanicTrace contains the Go panic or fatal error trace siad printed
+		// before exiting, if any.
+		PanicTrace string `json:",omitempty"`
+	}
+)
+
+// Validate returns an error if the restart policy is invalid.
+func (rp *RestartPolicy) Validate() error {
+	switch rp.Policy {
+	case "", RestartNever, RestartOnFailure:
+		return nil
+	default:
+		return errors.New("unknown restart policy: " + string(rp.Policy))
+	}
+}
+
+// restartBackoff returns the wait before restarting a siad which exited with
+// the given error after the given number of restarts. It returns false if
+// siad shouldn't be restarted.
+func (rp *RestartPolicy) restartBackoff(exitErr error, restarts uint64) (time.Duration, bool) {
+	if rp == nil || rp.Policy != RestartOnFailure || exitErr == nil {
+		return 0, false
+	}
+	if rp.MaxRestarts > 0 && restarts >= rp.MaxRestarts {
+		return 0, false
+	}
+	backoff := defaultRestartBackoff
+	if rp.BackoffMilliseconds > 0 {
+		backoff = time.Duration(rp.BackoffMilliseconds) * time.Millisecond
+	}
+	for i := uint64(0); i < restarts && backoff < maxRestartBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRestartBackoff {
+		backoff = maxRestartBackoff
+	}
+	return backoff, true
+}
+
+// Crashes returns the siad crashes captured by the ant's supervisor.
+func (a *Ant) Crashes() []Crash {
+	a.crashMu.Lock()
+	defer a.crashMu.Unlock()
+	crashes := make([]Crash, len(a.crashes))
+	copy(crashes, a.crashes)
+	return crashes
+}
+
+// startSupervisor starts the supervisor of the ant's siad process. An
+// external siad isn't supervised. It must be called under siadMu or before the
+// ant is returned.
+func (a *Ant) startSupervisor() {
+	if a.siad == nil {
+		return
+	}
+	go a.threadedSuperviseSiad(a.siad, a.supervisorStop)
+}
+
+// stopSupervisor stops the supervisor of the ant's siad from restarting it,
+// e.g. because the ant is closed or started again. It must be called under
+// siadMu.
+func (a *Ant) stopSupervisor() {
+	close(a.supervisorStop)
+	a.supervisorStop = make(chan struct{})
+}
+
+// threadedSuperviseSiad waits for the ant's siad process to exit. If siad
+// wasn't stopped by the ant, the crash is captured and published and siad is
+// restarted according to the ant's restart policy. Failed restarts are
+// retried with the policy's backoff until a restart succeeds, the policy gives
+// up or the supervisor is stopped.
+func (a *Ant) threadedSuperviseSiad(siad *siadProcess, stop <-chan struct{}) {
+	select {
+	case <-siad.staticExited:
+	case <-siad.staticStopped:
+		return
+	case <-stop:
+		return
+	}
+	if siad.stopped() {
+		return
+	}
+
+	// Capture the crash
+	crash := a.captureCrash(siad)
+	a.staticLogger.Errorf("%v: siad exited unexpectedly: %v", a.Config.DataDir, siad.exitErr)
+	if crash.PanicTrace != "" {
+		a.staticLogger.Errorf("%v: siad panic trace:\n%v", a.Config.DataDir, crash.PanicTrace)
+	}
+	a.crashMu.Lock()
+	a.crashes = append(a.crashes, crash)
+	a.crashMu.Unlock()
+	a.publishSiadEvent(EventSiadCrashed, &crash)
+
+	// Restart siad according to the restart policy, every attempt counts as
+	// a restart
+	for {
+		a.crashMu.Lock()
+		restarts := a.siadRestarts
+		a.crashMu.Unlock()
+		backoff, restart := a.Config.RestartPolicy.restartBackoff(siad.exitErr, restarts)
+		if !restart {
+			a.staticLogger.Printf("%v: crashed siad isn't restarted by the ant's restart policy", a.Config.DataDir)
+			return
+		}
+		a.staticLogger.Printf("%v: restarting crashed siad in %v", a.Config.DataDir, backoff)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		err := a.managedRestartSiad(siad, stop)
+		if errors.Contains(err, errSupervisorStopped) {
+			return
+		}
+		if err == nil {
+			return
+		}
+		a.staticLogger.Errorf("%v: can't restart crashed siad: %v", a.Config.DataDir, err)
+	}
+}
+
+// managedRestartSiad stops the ant's jobs and proxies and starts the ant
+// again after its siad crashed. errSupervisorStopped is returned if the
+// supervisor was stopped in the meantime, e.g. because the ant was closed.
+func (a *Ant) managedRestartSiad(siad *siadProcess, stop <-chan struct{}) error {
+	a.siadMu.Lock()
+	defer a.siadMu.Unlock()
+	select {
+	case <-stop:
+		return errSupervisorStopped
+	default:
+	}
+	siad.markStopped()
+	if a.running() {
+		if err := a.Jr.Stop(); err != nil {
+			a.staticLogger.Errorf("%v: can't stop job runner of crashed siad: %v", a.Config.DataDir, err)
+		}
+	}
+	a.closeProxies()
+	a.crashMu.Lock()
+	a.siadRestarts++
+	a.crashMu.Unlock()
+	if err := a.start(a.Config.SiadPath); err != nil {
+		// Stop the job runner of the failed siad, so that the next attempt
+		// starts from a stopped ant
+		if a.running() {
+			if stopErr := a.Jr.Stop(); stopErr != nil {
+				a.staticLogger.Errorf("%v: can't stop job runner of failed siad: %v", a.Config.DataDir, stopErr)
+			}
+		}
+		return err
+	}
+	a.publishSiadEvent(EventSiadRestarted, nil)
+	return nil
+}
+
+// publishSiadEvent publishes an event about the ant's siad process.
+func (a *Ant) publishSiadEvent(t EventType, crash *Crash) {
+	a.staticEvents.Publish(Event{
+		Type:        t,
+		Time:        time.Now(),
+		Ant:         a.eventAntName(),
+		BlockHeight: a.managedWatchState().height,
+		Crash:       crash,
+	})
+}
+
+// captureCrash captures the crash of the given exited siad process from its
+// exit error and its output in the ant's siad log.
+func (a *Ant) captureCrash(siad *siadProcess) Crash {
+	crash := Crash{Time: time.Now()}
+	if siad.exitErr != nil {
+		crash.ExitError = siad.exitErr.Error()
+	}
+	output, err := readSiadOutput(filepath.Join(a.Config.DataDir, siadLogFilename), siad.staticLogOffset)
+	if err != nil {
+		a.staticLogger.Errorf("%v: can't read output of crashed siad: %v", a.Config.DataDir, err)
+		return crash
+	}
+	crash.LogTail, crash.PanicTrace = parseCrashOutput(output)
+	return crash
+}
+
+// readSiadOutput reads the output appended to the siad log file at the given
+// path after the given offset. At most crashLogMaxBytes at the end of the
+// output are read.
+func readSiadOutput(path string, offset int64) (_ string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.AddContext(err, "can't open siad log file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return "", errors.AddContext(err, "can't stat siad log file")
+	}
+	if info.Size()-offset > crashLogMaxBytes {
+		offset = info.Size() - crashLogMaxBytes
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", errors.AddContext(err, "can't seek siad log file")
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", errors.AddContext(err, "can't read siad log file")
+	}
+	return string(data), nil
+}
+
+// parseCrashOutput returns the last crashLogTailLines lines of the output of a
+// crashed siad and the Go panic or fatal error trace in the output, if any.
+func parseCrashOutput(output string) (tail, panicTrace string) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			panicTrace = strings.Join(lines[i:], "\n")
+			break
+		}
+	}
+	if len(lines) > crashLogTailLines {
+		lines = lines[len(lines)-crashLogTailLines:]
+	}
+	return strings.Join(lines, "\n"), panicTrace
+}
@@ -0,0 +1,252 @@
+package ant
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/test"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestRestartPolicy verifies the restart decisions and backoffs of restart
+// policies.
+func TestRestartPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	exitErr := errors.New("exit status 2")
+	onFailure := &RestartPolicy{Policy: RestartOnFailure, BackoffMilliseconds: 100, MaxRestarts: 3}
+	tests := []struct {
+		name     string
+		policy   *RestartPolicy
+		exitErr  error
+		restarts uint64
+		restart  bool
+		backoff  time.Duration
+	}{
+		{"no policy", nil, exitErr, 0, false, 0},
+		{"never", &RestartPolicy{Policy: RestartNever}, exitErr, 0, false, 0},
+		{"clean exit", onFailure, nil, 0, false, 0},
+		{"first restart", onFailure, exitErr, 0, true, 100 * time.Millisecond},
+		{"third restart", onFailure, exitErr, 2, true, 400 * time.Millisecond},
+		{"max restarts", onFailure, exitErr, 3, false, 0},
+		{"default backoff", &RestartPolicy{Policy: RestartOnFailure}, exitErr, 0, true, defaultRestartBackoff},
+		{"max backoff", &RestartPolicy{Policy: RestartOnFailure}, exitErr, 100, true, maxRestartBackoff},
+	}
+	for _, tt := range tests {
+		backoff, restart := tt.policy.restartBackoff(tt.exitErr, tt.restarts)
+		if restart != tt.restart || backoff != tt.backoff {
+			t.Errorf("%v: expected restart %v after %v, got %v after %v", tt.name, tt.restart, tt.backoff, restart, backoff)
+		}
+	}
+
+	if err := (&RestartPolicy{Policy: "always"}).Validate(); err == nil {
+		t.Error("expected unknown restart policy to be invalid")
+	}
+}
+
+// TestCrashOutput verifies capturing the log tail and the panic trace of a
+// crashed siad from its output.
+func TestCrashOutput(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output of a previous siad process precedes the offset
+	previous := "panic: previous crash\n"
+	var lines []string
+	for i := 0; i < crashLogTailLines; i++ {
+		lines = append(lines, fmt.Sprintf("log line %d", i))
+	}
+	trace := "panic: runtime error: index out of range\n\ngoroutine 1 [running]:\nmain.main()"
+	output := strings.Join(lines, "\n") + "\n" + trace + "\n"
+	path := filepath.Join(dataDir, siadLogFilename)
+	if err := ioutil.WriteFile(path, []byte(previous+output), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := readSiadOutput(path, int64(len(previous)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != output {
+		t.Fatalf("expected output %q, got %q", output, read)
+	}
+	tail, panicTrace := parseCrashOutput(read)
+	if panicTrace != trace {
+		t.Fatalf("expected panic trace %q, got %q", trace, panicTrace)
+	}
+	tailLines := strings.Split(tail, "\n")
+	if len(tailLines) != crashLogTailLines || tailLines[len(tailLines)-1] != "main.main()" {
+		t.Fatalf("unexpected log tail %q", tail)
+	}
+
+	// Output without a panic
+	if _, panicTrace := parseCrashOutput("log line\n"); panicTrace != "" {
+		t.Fatalf("expected no panic trace, got %q", panicTrace)
+	}
+}
+
+// TestSiadCrashRestart verifies that a killed siad is captured as a crash and
+// restarted by the ant's supervisor.
+func TestSiadCrashRestart(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create testing config
+	dataDir := test.TestDir(t.Name())
+	config, err := newTestingAntConfig(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.RestartPolicy = &RestartPolicy{Policy: RestartOnFailure, BackoffMilliseconds: 100, MaxRestarts: 1}
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create Ant
+	ant, err := New(&sync.WaitGroup{}, logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ant.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	events, unsubscribe := ant.Events().Subscribe(EventSiadCrashed, EventSiadRestarted)
+	defer unsubscribe()
+
+	// Kill siad, the supervisor captures the crash and restarts siad
+	ant.siadMu.Lock()
+	siad := ant.siad
+	ant.siadMu.Unlock()
+	if err := siad.staticCmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent := func(et EventType) Event {
+		timeout := time.After(updateSiadWarmUpTime + waitForFullSetupTimeout + time.Minute)
+		for {
+			select {
+			case e := <-events:
+				if e.Type == et {
+					return e
+				}
+			case <-timeout:
+				t.Fatalf("event %v wasn't published", et)
+			}
+		}
+	}
+	crashEvent := waitForEvent(EventSiadCrashed)
+	if crashEvent.Crash == nil || crashEvent.Crash.ExitError == "" || crashEvent.Crash.LogTail == "" {
+		t.Fatalf("expected crash with exit error and log tail, got %+v", crashEvent.Crash)
+	}
+	waitForEvent(EventSiadRestarted)
+	if _, err := ant.StaticClient.ConsensusGet(); err != nil {
+		t.Fatal(err)
+	}
+	if crashes := ant.Crashes(); len(crashes) != 1 {
+		t.Fatalf("expected 1 crash, got %v", len(crashes))
+	}
+}
+
+// TestSiadFailedRestart verifies that failed restarts of a crashed siad are
+// retried and counted until the restart policy gives up.
+func TestSiadFailedRestart(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create testing config using a copy of the siad binary, so that siad
+	// can't be restarted once the copy is removed
+	dataDir := test.TestDir(t.Name())
+	config, err := newTestingAntConfig(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siadPath, err := exec.LookPath(config.SiadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siadBinary, err := ioutil.ReadFile(siadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SiadPath = filepath.Join(dataDir, "siad-copy")
+	if err := ioutil.WriteFile(config.SiadPath, siadBinary, 0700); err != nil {
+		t.Fatal(err)
+	}
+	config.RestartPolicy = &RestartPolicy{Policy: RestartOnFailure, BackoffMilliseconds: 100, MaxRestarts: 2}
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create Ant
+	ant, err := New(&sync.WaitGroup{}, logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ant.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Remove the siad binary and kill siad, both restart attempts fail
+	if err := os.Remove(config.SiadPath); err != nil {
+		t.Fatal(err)
+	}
+	ant.siadMu.Lock()
+	siad := ant.siad
+	ant.siadMu.Unlock()
+	if err := siad.staticCmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	restarts := func() uint64 {
+		ant.crashMu.Lock()
+		defer ant.crashMu.Unlock()
+		return ant.siadRestarts
+	}
+	timeout := time.After(time.Minute)
+	for restarts() < 2 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected 2 restart attempts, got %v", restarts())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// The supervisor gives up after MaxRestarts attempts
+	time.Sleep(time.Second)
+	if n := restarts(); n != 2 {
+		t.Fatalf("expected the supervisor to give up after 2 restart attempts, got %v", n)
+	}
+}
@@ -1,19 +1,24 @@
 package antfarm
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"go.sia.tech/sia-antfarm/ant"
+	binariesbuilder "go.sia.tech/sia-antfarm/binaries-builder"
 	"go.sia.tech/sia-antfarm/persist"
 	"go.sia.tech/sia-antfarm/upnprouter"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node/api/client"
 	"go.sia.tech/siad/types"
@@ -24,9 +29,14 @@ const (
 	// httpClientTimeout defines timeout for http client
 	httpClientTimeout = time.Second * 10
 
-	// waitForAntsToSyncFrequency defines how frequently to check if ants are
-	// synced
+	// waitForAntsToSyncFrequency defines the minimum interval between checks
+	// if ants are synced
 	waitForAntsToSyncFrequency = time.Second
+
+	// proxyInternalHost defines the local host siad listens on when the ant
+	// runs behind a proxy. It differs from the public hosts, so that siad can
+	// listen on the public ports.
+	proxyInternalHost = "127.0.0.2"
 )
 
 // ConnectAnts connects two or more ants to the first ant in the slice,
@@ -62,8 +72,9 @@ func ConnectAnts(ants ...*ant.Ant) error {
 // ants.
 //
 // The outer slice is the list of gorups, and the inner slice is a list of ants
-// in each group.
-func antConsensusGroups(ants ...*ant.Ant) (groups [][]*ant.Ant, err error) {
+// in each group. Ants are in the same group if they share a block within the
+// last reorgDepth blocks.
+func antConsensusGroups(reorgDepth types.BlockHeight, ants ...*ant.Ant) (groups [][]*ant.Ant, err error) {
 	opts, err := client.DefaultOptions()
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to get default client options")
@@ -81,7 +92,7 @@ func antConsensusGroups(ants ...*ant.Ant) (groups [][]*ant.Ant, err error) {
 		// group, insert it. If not, add it to the next group.
 		found := false
 		for gi, group := range groups {
-			for i := types.BlockHeight(0); i < 8; i++ {
+			for i := types.BlockHeight(0); i < reorgDepth; i++ {
 				id1, exists1 := a.SeenBlocks[cg.Height-i]
 				id2, exists2 := group[0].SeenBlocks[cg.Height-i] // no group should have a length of zero
 				if exists1 && exists2 && id1 == id2 {
@@ -102,8 +113,9 @@ func antConsensusGroups(ants ...*ant.Ant) (groups [][]*ant.Ant, err error) {
 }
 
 // startAnts starts the ants defined by configs and blocks until every API
-// has loaded.
-func startAnts(antsSyncWG *sync.WaitGroup, logger *persist.Logger, configs ...ant.AntConfig) (ants []*ant.Ant, returnErr error) {
+// has loaded. The faucet is set before the ants' jobs start, it can be nil.
+// Starting the ants is aborted when the context is cancelled.
+func startAnts(ctx context.Context, antsSyncWG *sync.WaitGroup, logger *persist.Logger, faucet ant.Faucet, configs ...ant.AntConfig) (ants []*ant.Ant, returnErr error) {
 	// Ensure that, if an error occurs, all the ants that have been started are
 	// closed before returning.
 	defer func() {
@@ -122,6 +134,9 @@ func startAnts(antsSyncWG *sync.WaitGroup, logger *persist.Logger, configs ...an
 
 	// Start an ant for each config
 	for i, config := range configs {
+		if err := ctx.Err(); err != nil {
+			return ants, errors.AddContext(err, "starting ants was cancelled")
+		}
 		cfg, err := parseConfig(logger, config)
 		if err != nil {
 			return ants, errors.AddContext(err, "unable to parse config")
@@ -134,7 +149,7 @@ func startAnts(antsSyncWG *sync.WaitGroup, logger *persist.Logger, configs ...an
 		logger.Printf("starting ant %v with config:\n%v", i, antConfigStr)
 
 		// Create Ant
-		a, err := ant.New(antsSyncWG, logger, cfg)
+		a, err := ant.NewWithFaucet(ctx, antsSyncWG, logger, cfg, faucet)
 		if err != nil {
 			// Ant is nil, we can't close it in defer
 			er := errors.AddContext(err, "can't create an ant")
@@ -193,12 +208,25 @@ func getClient(APIAddr, APIPassword string) (*client.Client, error) {
 }
 
 // startJobs starts all the jobs for each ant.
-func startJobs(antsSyncWG *sync.WaitGroup, ants ...*ant.Ant) error {
+func startJobs(ants ...*ant.Ant) error {
+	return startAntfarmJobs(ants, ants)
+}
+
+// startAntfarmJobs starts the jobs started by the antfarm, which depend on
+// other ants: the bigspender jobs of the started ants and the littlesupplier
+// jobs of all ants which don't run a littlesupplier job yet, so that ants
+// with a littlesupplier job start supplying once a bigspender ant is added.
+// all contains all the antfarm's ants including the started ants, stopped
+// ants are skipped.
+func startAntfarmJobs(started, all []*ant.Ant) error {
 	// first, pull out any constants needed for the jobs
 	var spenderAddress *types.UnlockHash
-	for _, ant := range ants {
-		for _, job := range ant.Config.Jobs {
-			if job == "bigspender" {
+	for _, ant := range all {
+		if ant.JobRunner() == nil {
+			continue
+		}
+		for _, jc := range ant.Config.Jobs {
+			if jc.Name == "bigspender" {
 				addr, err := ant.WalletAddress()
 				if err != nil {
 					return err
@@ -208,26 +236,101 @@ func startJobs(antsSyncWG *sync.WaitGroup, ants ...*ant.Ant) error {
 		}
 	}
 	// start jobs requiring those constants
-	for _, ant := range ants {
-		for _, job := range ant.Config.Jobs {
-			if job == "bigspender" {
-				err := ant.StartJob(antsSyncWG, job)
-				if err != nil {
-					return err
-				}
-			}
-			if job == "littlesupplier" && spenderAddress != nil {
-				err := ant.StartJob(antsSyncWG, job, *spenderAddress)
+	for _, a := range started {
+		for _, jc := range a.Config.Jobs {
+			if jc.Name == "bigspender" {
+				job, err := ant.NewJobFromConfig(jc)
 				if err != nil {
 					return err
 				}
-				err = ant.StartJob(antsSyncWG, "miner")
+				err = a.RunJob(job)
 				if err != nil {
 					return err
 				}
 			}
 		}
 	}
+	if spenderAddress == nil {
+		return nil
+	}
+	for _, a := range all {
+		if a.JobRunner() == nil || a.HasActiveJob("littlesupplier") {
+			continue
+		}
+		for _, jc := range a.Config.Jobs {
+			if jc.Name != "littlesupplier" {
+				continue
+			}
+			err := a.RunJob(&ant.LittleSupplierJob{SendAddress: *spenderAddress})
+			if err != nil {
+				return err
+			}
+			err = a.StartJob("miner")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSiadVersions sets the SiadPath of the configs with a SiadVersion to
+// the path of the siad binary built by the binaries builder. Missing binaries
+// are built, all versions are requested at once, so that the builder builds
+// each version only once.
+func resolveSiadVersions(logger *persist.Logger, configs []ant.AntConfig) ([]ant.AntConfig, error) {
+	var versions []string
+	seen := make(map[string]struct{})
+	for _, c := range configs {
+		if _, ok := seen[c.SiadVersion]; c.SiadVersion == "" || ok {
+			continue
+		}
+		seen[c.SiadVersion] = struct{}{}
+		versions = append(versions, c.SiadVersion)
+	}
+	if len(versions) == 0 {
+		return configs, nil
+	}
+
+	logger.Printf("resolving siad versions %v", strings.Join(versions, ", "))
+	if err := binariesbuilder.StaticBuilder.BuildVersions(logger, false, versions...); err != nil {
+		return nil, errors.AddContext(err, "can't build siad versions")
+	}
+	resolved := append([]ant.AntConfig(nil), configs...)
+	for i, c := range resolved {
+		if c.SiadVersion != "" {
+			resolved[i].SiadPath = binariesbuilder.SiadBinaryPath(c.SiadVersion)
+		}
+	}
+	return resolved, nil
+}
+
+// seedAnts sets the Seed of the configs without a seed to a seed derived from
+// the antfarm seed and the ant's name, or the ant's index if the ant has no
+// name. The index of the first config is offset.
+func seedAnts(seed string, configs []ant.AntConfig, offset int) []ant.AntConfig {
+	seeded := append([]ant.AntConfig(nil), configs...)
+	for i, c := range seeded {
+		if c.Seed != "" {
+			continue
+		}
+		key := c.Name
+		if key == "" {
+			key = strconv.Itoa(offset + i)
+		}
+		seeded[i].Seed = crypto.HashAll(seed, key).String()
+	}
+	return seeded
+}
+
+// checkDesiredCurrency checks that the ant config doesn't have both
+// DesiredCurrency and `miner` job, they are mutually exclusive.
+func checkDesiredCurrency(config ant.AntConfig) error {
+	for _, jc := range config.Jobs {
+		if jc.Name == "miner" && config.DesiredCurrency != 0 {
+			return errors.New("cannot have desired currency with miner job")
+		}
+	}
 	return nil
 }
 
@@ -252,18 +355,11 @@ func parseConfig(logger *persist.Logger, config ant.AntConfig) (ant.AntConfig, e
 	}
 
 	if config.SiadPath == "" {
-		config.SiadPath = "siad-dev"
+		config.SiadPath = defaultSiadPath
 	}
 
-	// DesiredCurrency and `miner` are mutually exclusive.
-	hasMiner := false
-	for _, job := range config.Jobs {
-		if job == "miner" {
-			hasMiner = true
-		}
-	}
-	if hasMiner && config.DesiredCurrency != 0 {
-		return ant.AntConfig{}, errors.New("error parsing config: cannot have desired currency with miner job")
+	if err := checkDesiredCurrency(config); err != nil {
+		return ant.AntConfig{}, errors.AddContext(err, "error parsing config")
 	}
 
 	// Set IP address
@@ -300,9 +396,31 @@ func parseConfig(logger *persist.Logger, config ant.AntConfig) (ant.AntConfig, e
 		config.SiaMuxWsAddr = ipAddr + addrs[4]
 	}
 
+	// When the ant runs behind a proxy, the proxy listens on the public RPC,
+	// host and SiaMux addresses and siad listens on the same ports of an
+	// internal local host. Siad advertises its listening ports to gateway
+	// peers and renters, so they connect to the proxy at the public host.
+	if config.Proxy != nil {
+		if config.InternalRPCAddr == "" {
+			config.InternalRPCAddr = proxyInternalAddr(config.RPCAddr)
+		}
+		if config.InternalHostAddr == "" {
+			config.InternalHostAddr = proxyInternalAddr(config.HostAddr)
+		}
+		if config.InternalSiaMuxAddr == "" {
+			config.InternalSiaMuxAddr = proxyInternalAddr(config.SiaMuxAddr)
+		}
+	}
+
 	return config, nil
 }
 
+// proxyInternalAddr returns the internal address siad listens on behind a
+// proxy listening on the given public address.
+func proxyInternalAddr(publicAddr string) string {
+	return net.JoinHostPort(proxyInternalHost, modules.NetAddress(publicAddr).Port())
+}
+
 // myExternalIP discovers the gateway's external IP by querying a centralized
 // service, http://myexternalip.com.
 func myExternalIP(logger *persist.Logger) (string, error) {
@@ -1,6 +1,7 @@
 package antfarm
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
@@ -8,7 +9,9 @@ import (
 	"time"
 
 	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/proxy"
 	"go.sia.tech/sia-antfarm/test"
+	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node/api/client"
 )
 
@@ -59,7 +62,7 @@ func TestStartAnts(t *testing.T) {
 	}()
 
 	// Start ants
-	ants, err := startAnts(&sync.WaitGroup{}, logger, configs...)
+	ants, err := startAnts(context.Background(), &sync.WaitGroup{}, logger, nil, configs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -136,7 +139,7 @@ func TestStartAntWithSiadPath(t *testing.T) {
 			}()
 
 			// Start ants
-			ants, err := startAnts(&sync.WaitGroup{}, logger, configs...)
+			ants, err := startAnts(context.Background(), &sync.WaitGroup{}, logger, nil, configs...)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -198,7 +201,7 @@ func TestRenterDisableIPViolationCheck(t *testing.T) {
 						DataDir:                  antDirs[0],
 						SiadPath:                 test.TestSiadFilename,
 					},
-					Jobs: []string{"renter"},
+					Jobs: []ant.JobConfig{{Name: "renter"}},
 				},
 			}
 
@@ -216,7 +219,7 @@ func TestRenterDisableIPViolationCheck(t *testing.T) {
 			}()
 
 			// Start ants
-			ants, err := startAnts(&sync.WaitGroup{}, logger, configs...)
+			ants, err := startAnts(context.Background(), &sync.WaitGroup{}, logger, nil, configs...)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -317,7 +320,7 @@ func TestConnectAnts(t *testing.T) {
 	}()
 
 	// Start ants
-	ants, err := startAnts(&sync.WaitGroup{}, logger, configs...)
+	ants, err := startAnts(context.Background(), &sync.WaitGroup{}, logger, nil, configs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -408,7 +411,7 @@ func TestAntConsensusGroups(t *testing.T) {
 	}()
 
 	// Start ants
-	ants, err := startAnts(&sync.WaitGroup{}, logger, configs...)
+	ants, err := startAnts(context.Background(), &sync.WaitGroup{}, logger, nil, configs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -422,7 +425,7 @@ func TestAntConsensusGroups(t *testing.T) {
 	}()
 
 	// Get the consensus groups
-	groups, err := antConsensusGroups(ants...)
+	groups, err := antConsensusGroups(defaultReorgDepth, ants...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -435,7 +438,7 @@ func TestAntConsensusGroups(t *testing.T) {
 
 	// Start an ant that is desynced from the rest of the network
 	cfg, err := parseConfig(logger, ant.AntConfig{
-		Jobs: []string{"miner"},
+		Jobs: []ant.JobConfig{{Name: "miner"}},
 		SiadConfig: ant.SiadConfig{
 			AllowHostLocalNetAddress: true,
 			DataDir:                  antDirs[3],
@@ -456,7 +459,7 @@ func TestAntConsensusGroups(t *testing.T) {
 	time.Sleep(time.Second * 30)
 
 	// Verify the ants are synced
-	groups, err = antConsensusGroups(ants...)
+	groups, err = antConsensusGroups(defaultReorgDepth, ants...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -473,3 +476,141 @@ func TestAntConsensusGroups(t *testing.T) {
 		t.Fatal("expected the miner ant to be in the second consensus group")
 	}
 }
+
+// TestConnectAntsBehindProxy verifies that ants behind a traffic shaping
+// proxy can be connected through the proxy.
+func TestConnectAntsBehindProxy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create configs, the second ant runs behind a proxy
+	dataDir := test.TestDir(t.Name())
+	antDirs, err := test.AntDirs(dataDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := []ant.AntConfig{
+		{
+			SiadConfig: ant.SiadConfig{
+				AllowHostLocalNetAddress: true,
+				DataDir:                  antDirs[0],
+				SiadPath:                 test.TestSiadFilename,
+			},
+		},
+		{
+			SiadConfig: ant.SiadConfig{
+				AllowHostLocalNetAddress: true,
+				DataDir:                  antDirs[1],
+				SiadPath:                 test.TestSiadFilename,
+			},
+			Proxy: &proxy.Config{
+				LatencyMilliseconds:     50,
+				JitterMilliseconds:      10,
+				BandwidthBytesPerSecond: 1 << 20,
+			},
+		},
+	}
+
+	// Create logger
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Start ants
+	ants, err := startAnts(context.Background(), &sync.WaitGroup{}, logger, nil, configs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, ant := range ants {
+			err := ant.Close()
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	internalAddr := modules.NetAddress(ants[1].Config.InternalRPCAddr)
+	if internalAddr == modules.NetAddress(ants[1].RPCAddr) || internalAddr.Port() != modules.NetAddress(ants[1].RPCAddr).Port() {
+		t.Fatalf("expected proxied ant to have an internal RPC address on another host with the same port, got %v", internalAddr)
+	}
+
+	// Connect the ants through the proxy
+	err = ConnectAnts(ants...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gatewayInfo, err := ants[1].StaticClient.GatewayGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gatewayInfo.Peers) != 1 {
+		t.Fatalf("expected the proxied ant to have 1 peer, got %v", len(gatewayInfo.Peers))
+	}
+
+	// When the proxied ant connects to the other ant, it advertises the port
+	// of the proxy
+	err = ants[1].StaticClient.GatewayDisconnectPost(gatewayInfo.Peers[0].NetAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ConnectAnts(ants[1], ants[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gatewayInfo, err = ants[0].StaticClient.GatewayGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var advertised bool
+	for _, peer := range gatewayInfo.Peers {
+		if peer.Inbound && peer.NetAddress.Port() == modules.NetAddress(ants[1].RPCAddr).Port() {
+			advertised = true
+		}
+	}
+	if !advertised {
+		t.Fatalf("expected an inbound peer with the proxied ant's public RPC port %v, got %v", ants[1].RPCAddr, gatewayInfo.Peers)
+	}
+}
+
+// TestSeedAnts verifies that ant seeds are derived from the antfarm seed and
+// the ant's name or index.
+func TestSeedAnts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	configs := []ant.AntConfig{
+		{Name: ant.NameHost(0)},
+		{},
+		{Name: ant.NameHost(1), Seed: "custom"},
+	}
+	seeded := seedAnts("seed", configs, 0)
+	if configs[0].Seed != "" {
+		t.Fatal("expected the given configs not to be modified")
+	}
+	if seeded[0].Seed == "" || seeded[1].Seed == "" || seeded[0].Seed == seeded[1].Seed {
+		t.Fatalf("expected unique ant seeds, got %v and %v", seeded[0].Seed, seeded[1].Seed)
+	}
+	if seeded[2].Seed != "custom" {
+		t.Fatalf("expected ant seed to be kept, got %v", seeded[2].Seed)
+	}
+
+	// Named ants get the same seed regardless of their index, unnamed ants
+	// by their index
+	reseeded := seedAnts("seed", configs[:2], 5)
+	if reseeded[0].Seed != seeded[0].Seed {
+		t.Fatal("expected named ant to get the same seed")
+	}
+	if reseeded[1].Seed == seeded[1].Seed {
+		t.Fatal("expected unnamed ant with another index to get another seed")
+	}
+	if other := seedAnts("other seed", configs[:1], 0); other[0].Seed == seeded[0].Seed {
+		t.Fatal("expected another antfarm seed to derive another ant seed")
+	}
+}
@@ -1,6 +1,8 @@
 package antfarm
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -17,6 +19,8 @@ import (
 	"go.sia.tech/sia-antfarm/upnprouter"
 	"go.sia.tech/siad/types"
 	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/NebulousLabs/threadgroup"
 )
 
 const (
@@ -43,25 +47,95 @@ type (
 		AutoConnect   bool
 		WaitForSync   bool
 
+		// Groups define groups of ants of the same role in a compact form,
+		// they are expanded into AntConfigs when the antfarm is created.
+		Groups []AntGroup `json:",omitempty"`
+
+		// Resume defines whether the antfarm should be resumed from the state
+		// saved in DataDir by the previous run. If there is no saved state,
+		// a new antfarm is created.
+		Resume bool
+
+		// ReportJUnit defines whether the antfarm report should also be
+		// written in JUnit XML format. The JSON report is always written.
+		ReportJUnit bool
+
+		// SyncPolicy defines when the sync monitor raises an alert about
+		// ants split into multiple consensus groups.
+		SyncPolicy SyncPolicy
+
 		// ExternalFarms is a slice of net addresses representing the API
 		// addresses of other antFarms to connect to.
 		ExternalFarms []string
+
+		// Seed makes the ants' wallet seeds, renter file contents and jobs'
+		// random choices deterministic, so that a run can be replayed. If it
+		// is empty, a random seed is used. The seed is logged and reported.
+		Seed string `json:",omitempty"`
+
+		// FaucetAnt is the name of the miner ant funding the ants with
+		// UseFaucet set.
+		FaucetAnt string `json:",omitempty"`
 	}
 
 	// AntFarm defines the 'antfarm' type. antFarm orchestrates a collection of
 	// ants and provides an API server to interact with them.
 	AntFarm struct {
 		apiListener net.Listener
+		apiServer   *http.Server
 		dataDir     string
 
-		// Ants is a slice of Ants in this antfarm.
+		// staticTG stops the antfarm's API server and sync monitor when the
+		// antfarm is closed.
+		staticTG threadgroup.ThreadGroup
+
+		// staticAutoConnect defines whether ants added to a running antfarm
+		// should be connected to the other ants.
+		staticAutoConnect bool
+
+		// staticReportJUnit defines whether the report written on close
+		// should also be written in JUnit XML format.
+		staticReportJUnit bool
+
+		// staticStart is the time the antfarm was created.
+		staticStart time.Time
+
+		// staticSeed is the seed the ants' seeds are derived from.
+		staticSeed string
+
+		// staticFaucetAnt is the name of the ant funding other ants,
+		// faucetMu serializes the faucet's transfers.
+		staticFaucetAnt string
+		faucetMu        sync.Mutex
+
+		// staticSyncPolicy defines when the sync monitor raises an alert,
+		// raised alerts are sent to staticSyncAlerts.
+		staticSyncPolicy SyncPolicy
+		staticSyncAlerts chan SyncAlert
+
+		// Ants is a slice of Ants in this antfarm. Ants can be added and
+		// removed while the antfarm is running, so the slice should be
+		// accessed under mu.
 		Ants []*ant.Ant
 
+		// addingAnts contains the configs of the ants being started by
+		// AddAnt, so that their names and data directories stay reserved
+		// while mu isn't held. It is accessed under mu.
+		addingAnts []*ant.AntConfig
+
 		// externalAnts is a slice of externally connected ants, that is, ants
 		// that are connected to this antfarm but managed by another antfarm.
 		externalAnts []*ant.Ant
 		router       *httprouter.Router
 
+		// syncSplits records ants split into multiple consensus groups
+		// detected by the sync monitor, it is accessed under mu.
+		syncSplits []SyncSplit
+
+		// syncAlerts records alerts raised by the sync monitor, it is
+		// accessed under mu.
+		syncAlerts []SyncAlert
+
 		// antsSyncWG is a waitgroup to wait for ASIC hardfork height and for
 		// all ants to be in sync. Then all non-mining ant jobs start. Mining
 		// jobs do not wait for this sync.
@@ -70,29 +144,121 @@ type (
 		// logger is an antfarm logger. It is passed to ants to log to the same
 		// logger.
 		logger *persist.Logger
+
+		// consensusMu serializes consensus checks of the ants, because they
+		// update the ants' seen blocks.
+		consensusMu sync.Mutex
+
+		// partition is the current network partition of the ants, it is nil
+		// if the ants are not partitioned. partitionMu serializes partitioning
+		// and healing.
+		partition   *networkPartition
+		partitionMu sync.Mutex
+
+		// staticEvents publishes the events of the ants managed by the
+		// antfarm, eventForwards contains functions stopping forwarding of
+		// each ant's events, it is accessed under mu.
+		staticEvents  *ant.EventBroker
+		eventForwards map[*ant.Ant]func()
+
+		// config is the running antfarm config, it is updated when ants are
+		// added or removed and when the config is reloaded. It is accessed
+		// under mu, reloadMu serializes config reloads.
+		config   AntfarmConfig
+		reloadMu sync.Mutex
+
+		mu sync.Mutex
 	}
 )
 
 // New creates a new antFarm given the supplied AntfarmConfig
 func New(logger *persist.Logger, config AntfarmConfig) (*AntFarm, error) {
-	// clear old antfarm data before creating an antfarm
-	dataDir := "./antfarm-data"
-	if config.DataDir != "" {
-		dataDir = config.DataDir
+	return NewWithContext(context.Background(), logger, config)
+}
+
+// NewWithContext creates a new antFarm given the supplied AntfarmConfig.
+// Starting the ants and waiting for them to sync is aborted when the context
+// is cancelled.
+func NewWithContext(ctx context.Context, logger *persist.Logger, config AntfarmConfig) (*AntFarm, error) {
+	dataDir := antfarmDataDir(config)
+
+	// Load ant configs from the previous antfarm state when resuming
+	antConfigs := config.AntConfigs
+	var runningConfigs []ant.AntConfig
+	var resumed bool
+	if config.Resume {
+		state, exists, err := loadState(dataDir)
+		if err != nil {
+			return nil, errors.AddContext(err, "can't load antfarm state")
+		}
+		if exists {
+			logger.Printf("resuming antfarm with %v ants from %v", len(state.AntConfigs), dataDir)
+			antConfigs = state.AntConfigs
+			runningConfigs = state.RunningConfigs
+			resumed = true
+		}
+	}
+
+	// Validate the config before touching the data directory, so that all
+	// problems are reported at once
+	validated := config
+	if resumed {
+		validated.AntConfigs = antConfigs
+		validated.Groups = nil
+	}
+	if problems := ValidateConfig(validated, false); len(problems) > 0 {
+		return nil, errors.AddContext(configProblemsError(problems), "invalid antfarm config")
 	}
 
-	err := os.RemoveAll(dataDir)
+	// Expand ant groups into ant configs
+	config, err := config.Expand()
 	if err != nil {
-		return nil, errors.AddContext(err, "can't remove antfarm data directory")
+		return nil, errors.AddContext(err, "can't expand ant groups")
+	}
+	if !resumed {
+		antConfigs = config.AntConfigs
+	}
+
+	// clear old antfarm data before creating a new antfarm
+	if !resumed {
+		err := os.RemoveAll(dataDir)
+		if err != nil {
+			return nil, errors.AddContext(err, "can't remove antfarm data directory")
+		}
 	}
 	err = os.MkdirAll(dataDir, 0700)
 	if err != nil {
 		return nil, errors.AddContext(err, "can't create antfarm data directory")
 	}
 
+	// Derive the ants' seeds from the antfarm seed, the seed is logged so
+	// that the run can be replayed
+	seed := config.Seed
+	if seed == "" {
+		seed = hex.EncodeToString(fastrand.Bytes(16))
+	}
+	logger.Printf("antfarm seed: %v", seed)
+	antConfigs = seedAnts(seed, antConfigs, 0)
+
 	farm := &AntFarm{
-		dataDir: dataDir,
-		logger:  logger,
+		dataDir:           dataDir,
+		staticAutoConnect: config.AutoConnect,
+		staticReportJUnit: config.ReportJUnit,
+		staticFaucetAnt:   config.FaucetAnt,
+		staticSeed:        seed,
+		staticStart:       time.Now(),
+		staticSyncPolicy:  config.SyncPolicy,
+		staticSyncAlerts:  make(chan SyncAlert, 1),
+		staticEvents:      ant.NewEventBroker(),
+		eventForwards:     make(map[*ant.Ant]func()),
+		config:            config,
+		logger:            logger,
+	}
+	farm.config.AntConfigs = append([]ant.AntConfig(nil), config.AntConfigs...)
+	if resumed && runningConfigs != nil {
+		// The running ants are the ants of the resumed antfarm's config, the
+		// resolved configs of the resumed ants are kept in the state only
+		farm.config.AntConfigs = runningConfigs
 	}
 
 	// Set ants sync waitgroup
@@ -105,31 +271,29 @@ func New(logger *persist.Logger, config AntfarmConfig) (*AntFarm, error) {
 	upnpStatus := upnprouter.CheckUPnPEnabled()
 	farm.logger.Debugln(upnpStatus)
 
-	// Check ant names are unique
-	antNames := make(map[string]struct{})
-	for _, ant := range config.AntConfigs {
-		if ant.Name == "" {
-			continue
-		}
-		_, ok := antNames[ant.Name]
-		if ok {
-			return farm, fmt.Errorf("ant name %v is not unique", ant.Name)
-		}
-		antNames[ant.Name] = struct{}{}
+	// Resolve siad versions to built siad binaries
+	antConfigs, err = resolveSiadVersions(farm.logger, antConfigs)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to resolve siad versions")
 	}
 
 	// Start up each ant process with its jobs
-	ants, err := startAnts(&farm.antsSyncWG, farm.logger, config.AntConfigs...)
+	ants, err := startAnts(ctx, &farm.antsSyncWG, farm.logger, farm, antConfigs...)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to start ants")
 	}
 
-	err = startJobs(&farm.antsSyncWG, ants...)
+	err = startJobs(ants...)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to start jobs")
 	}
 
+	farm.mu.Lock()
 	farm.Ants = ants
+	for _, a := range ants {
+		farm.forwardEvents(a)
+	}
+	farm.mu.Unlock()
 	defer func() {
 		if err != nil {
 			closeErr := farm.Close()
@@ -139,6 +303,11 @@ func New(logger *persist.Logger, config AntfarmConfig) (*AntFarm, error) {
 		}
 	}()
 
+	// Save antfarm state, so that the antfarm can be resumed
+	if err = farm.saveState(); err != nil {
+		return nil, errors.AddContext(err, "unable to save antfarm state")
+	}
+
 	// if the AutoConnect flag is set, use connectAnts to bootstrap the network.
 	if config.AutoConnect {
 		if err = ConnectAnts(ants...); err != nil {
@@ -147,26 +316,32 @@ func New(logger *persist.Logger, config AntfarmConfig) (*AntFarm, error) {
 	}
 	// connect the external antFarms
 	for _, address := range config.ExternalFarms {
-		if err = farm.ConnectExternalAntfarm(address); err != nil {
+		if err = farm.ConnectExternalAntfarmWithContext(ctx, address); err != nil {
 			return nil, errors.AddContext(err, "unable to connect external ant farm")
 		}
 	}
 
 	// start up the api server listener
-	farm.apiListener, err = net.Listen("tcp", config.ListenAddress)
+	l, err := net.Listen("tcp", config.ListenAddress)
 	if err != nil {
 		return nil, errors.AddContext(err, fmt.Sprintf("unable to create TCP connection on %v", config.ListenAddress))
 	}
+	farm.apiListener = &onceCloseListener{Listener: l}
 
 	// construct the router and serve the API.
-	farm.router = httprouter.New()
-	farm.router.GET("/ants", farm.getAnts)
+	farm.initAPI()
+	farm.apiServer = &http.Server{Handler: farm.router}
+	farm.staticTG.OnStop(func() error {
+		return errors.Compose(farm.apiServer.Close(), farm.apiListener.Close())
+	})
 
 	// Wait for ASIC hardfork height and for all ants to sync
 	if config.WaitForSync {
 		// Wait for ASIC hardfork height
 		logger.Debugf("%v: waiting for ASIC hardfork height...", dataDir)
-		err = farm.Ants[0].WaitForBlockHeight(types.ASICHardforkHeight, asicHardforkTimeout, time.Second)
+		asicCtx, cancel := context.WithTimeout(ctx, asicHardforkTimeout)
+		err = farm.Ants[0].WaitForBlockHeightWithContext(asicCtx, types.ASICHardforkHeight)
+		cancel()
 		if err != nil {
 			er := fmt.Errorf("waiting for ASIC hardfork height reached %v timeout: %v", asicHardforkTimeout, err)
 			logger.Debugf("%v: %v", dataDir, er)
@@ -175,7 +350,9 @@ func New(logger *persist.Logger, config AntfarmConfig) (*AntFarm, error) {
 		logger.Debugf("%v: waiting for ASIC hardfork height finished", dataDir)
 
 		// Wait for all ants being synced
-		err = farm.waitForAntsToSync(antsSyncTimeout)
+		syncCtx, cancel := context.WithTimeout(ctx, antsSyncTimeout)
+		err = farm.waitForAntsToSync(syncCtx)
+		cancel()
 		if err != nil {
 			return nil, fmt.Errorf("waiting for ants to sync reached %v timeout: %v", antsSyncTimeout, err)
 		}
@@ -194,16 +371,185 @@ func NewAntfarmLogger(dataDir string) (*persist.Logger, error) {
 	return logger, nil
 }
 
+// managedAllAnts returns all ants, external and internal, associated with
+// this antFarm.
+func (af *AntFarm) managedAllAnts() []*ant.Ant {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	return af.allAnts()
+}
+
+// managedAnts returns a copy of the slice of ants managed by this antFarm.
+func (af *AntFarm) managedAnts() []*ant.Ant {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	return append([]*ant.Ant{}, af.Ants...)
+}
+
 // allAnts returns all ants, external and internal, associated with this
 // antFarm.
 func (af *AntFarm) allAnts() []*ant.Ant {
-	return append(af.Ants, af.externalAnts...)
+	ants := append([]*ant.Ant{}, af.Ants...)
+	return append(ants, af.externalAnts...)
+}
+
+// AddAnt starts a new ant defined by the given config and adds it to the
+// running antfarm. If the antfarm was configured with AutoConnect, the new ant
+// is connected to all the other ants. The ant's name and data directory are
+// reserved while the ant is started, so that other ants can be added, removed
+// or inspected meanwhile.
+func (af *AntFarm) AddAnt(config ant.AntConfig) (*ant.Ant, error) {
+	if err := af.staticTG.Add(); err != nil {
+		return nil, err
+	}
+	defer af.staticTG.Done()
+
+	// Reserve the ant's name and data directory
+	af.mu.Lock()
+	index, err := af.reserveAnt(&config)
+	af.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		af.mu.Lock()
+		af.releaseAnt(&config)
+		af.mu.Unlock()
+	}()
+
+	// Start the ant with its jobs
+	seeded := seedAnts(af.staticSeed, []ant.AntConfig{config}, index)
+	resolved, err := resolveSiadVersions(af.logger, seeded)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to resolve siad version")
+	}
+	ants, err := startAnts(context.Background(), &af.antsSyncWG, af.logger, af, resolved...)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to start ant")
+	}
+	newAnt := ants[0]
+
+	// Connect the new ant to the other ants
+	otherAnts := af.managedAllAnts()
+	if af.staticAutoConnect && len(otherAnts) > 0 {
+		err = ConnectAnts(append([]*ant.Ant{newAnt}, otherAnts...)...)
+		if err != nil {
+			if closeErr := newAnt.Close(); closeErr != nil {
+				af.logger.Errorf("%v: can't close ant: %v", newAnt.Config.DataDir, closeErr)
+			}
+			return nil, errors.AddContext(err, "unable to connect ant")
+		}
+	}
+
+	// Start the jobs depending on the other ants
+	if err := startAntfarmJobs([]*ant.Ant{newAnt}, append(af.managedAnts(), newAnt)); err != nil {
+		if closeErr := newAnt.Close(); closeErr != nil {
+			af.logger.Errorf("%v: can't close ant: %v", newAnt.Config.DataDir, closeErr)
+		}
+		return nil, errors.AddContext(err, "unable to start jobs")
+	}
+
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	af.Ants = append(af.Ants, newAnt)
+	// Unnamed ants can't be reproduced by a reloaded config, so they aren't
+	// part of the running config
+	if config.Name != "" {
+		af.config.AntConfigs = append(af.config.AntConfigs, config)
+	}
+	af.forwardEvents(newAnt)
+	af.logger.Printf("ant %v was added to antfarm", newAnt.Config.DataDir)
+	if err := af.saveState(); err != nil {
+		af.logger.Errorf("can't save antfarm state: %v", err)
+	}
+	return newAnt, nil
+}
+
+// reserveAnt reserves the name and the data directory of the given ant config
+// for an ant being added. It returns an error if another ant uses them. The
+// returned index of the ant is used to derive the seed of an unnamed ant. It
+// must be called under mu.
+func (af *AntFarm) reserveAnt(config *ant.AntConfig) (int, error) {
+	used := make([]ant.AntConfig, 0, len(af.Ants)+len(af.addingAnts))
+	for _, a := range af.Ants {
+		used = append(used, a.Config)
+	}
+	for _, c := range af.addingAnts {
+		used = append(used, *c)
+	}
+	for _, c := range used {
+		if config.Name != "" && c.Name == config.Name {
+			return 0, fmt.Errorf("ant name %v is not unique", config.Name)
+		}
+		if config.DataDir != "" && c.DataDir == config.DataDir {
+			return 0, fmt.Errorf("ant data directory %v is not unique", config.DataDir)
+		}
+	}
+	af.addingAnts = append(af.addingAnts, config)
+	return len(used), nil
+}
+
+// releaseAnt releases the name and the data directory reserved by reserveAnt.
+// It must be called under mu.
+func (af *AntFarm) releaseAnt(config *ant.AntConfig) {
+	for i, c := range af.addingAnts {
+		if c == config {
+			af.addingAnts = append(af.addingAnts[:i:i], af.addingAnts[i+1:]...)
+			return
+		}
+	}
+}
+
+// RemoveAnt stops the ant with the given name and removes it from the running
+// antfarm.
+func (af *AntFarm) RemoveAnt(name string) error {
+	af.mu.Lock()
+	var removedAnt *ant.Ant
+	for i, a := range af.Ants {
+		if a.Config.Name == name {
+			removedAnt = a
+			af.Ants = append(af.Ants[:i:i], af.Ants[i+1:]...)
+			af.stopForwardingEvents(a)
+			break
+		}
+	}
+	for i, c := range af.config.AntConfigs {
+		if removedAnt != nil && c.Name == name {
+			af.config.AntConfigs = append(af.config.AntConfigs[:i:i], af.config.AntConfigs[i+1:]...)
+			break
+		}
+	}
+	af.mu.Unlock()
+	if removedAnt == nil {
+		return fmt.Errorf("ant with name %v doesn't exist", name)
+	}
+	if err := af.managedSaveState(); err != nil {
+		af.logger.Errorf("can't save antfarm state: %v", err)
+	}
+
+	err := removedAnt.Close()
+	if err != nil {
+		return errors.AddContext(err, "can't close removed ant")
+	}
+	af.logger.Printf("ant %v was removed from antfarm", removedAnt.Config.DataDir)
+	return nil
 }
 
 // ConnectExternalAntfarm connects the current antfarm to an external antfarm,
 // using the antfarm api at externalAddress.
 func (af *AntFarm) ConnectExternalAntfarm(externalAddress string) error {
-	res, err := http.DefaultClient.Get("http://" + externalAddress + "/ants")
+	return af.ConnectExternalAntfarmWithContext(context.Background(), externalAddress)
+}
+
+// ConnectExternalAntfarmWithContext connects the current antfarm to an
+// external antfarm, using the antfarm api at externalAddress. The request to
+// the external antfarm is aborted when the context is cancelled.
+func (af *AntFarm) ConnectExternalAntfarmWithContext(ctx context.Context, externalAddress string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+externalAddress+"/ants", nil)
+	if err != nil {
+		return errors.AddContext(err, "can't create request")
+	}
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -218,18 +564,31 @@ func (af *AntFarm) ConnectExternalAntfarm(externalAddress string) error {
 	if err != nil {
 		return err
 	}
+	af.mu.Lock()
 	af.externalAnts = append(af.externalAnts, externalAnts...)
-	return ConnectAnts(af.allAnts()...)
+	af.mu.Unlock()
+	return ConnectAnts(af.managedAllAnts()...)
 }
 
-// ServeAPI serves the antFarm's http API.
+// ServeAPI serves the antFarm's http API until the antfarm is closed.
 func (af *AntFarm) ServeAPI() error {
-	return http.Serve(af.apiListener, af.router)
+	if err := af.staticTG.Add(); err != nil {
+		return err
+	}
+	defer af.staticTG.Done()
+
+	err := af.apiServer.Serve(af.apiListener)
+	if errors.Contains(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 // GetAntByName return the ant with the given name. If there is no ant with the
 // given name error is reported.
 func (af *AntFarm) GetAntByName(name string) (foundAnt *ant.Ant, err error) {
+	af.mu.Lock()
+	defer af.mu.Unlock()
 	for _, a := range af.Ants {
 		if a.Config.Name == name {
 			return a, nil
@@ -238,29 +597,67 @@ func (af *AntFarm) GetAntByName(name string) (foundAnt *ant.Ant, err error) {
 	return &ant.Ant{}, fmt.Errorf("ant with name %v doesn't exist", name)
 }
 
+// GetAnts returns the ants running on the antfarm.
+func (af *AntFarm) GetAnts() []*ant.Ant {
+	return af.managedAnts()
+}
+
+// ConsensusGroups returns consensus groups the ants of the antfarm are split
+// into.
+func (af *AntFarm) ConsensusGroups() ([][]*ant.Ant, error) {
+	return af.managedConsensusGroups(af.managedAnts()...)
+}
+
+// WaitForAntsToSync waits for all ants to be synced with the given timeout.
+func (af *AntFarm) WaitForAntsToSync(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return af.waitForAntsToSync(ctx)
+}
+
+// WaitForAntsToSyncWithContext waits until all ants of the antfarm are in a
+// single consensus group or the context is done.
+func (af *AntFarm) WaitForAntsToSyncWithContext(ctx context.Context) error {
+	return af.waitForAntsToSync(ctx)
+}
+
 // PermanentSyncMonitor checks that all ants in the antFarm are on the same
-// blockchain.
+// blockchain until the antfarm is closed.
 func (af *AntFarm) PermanentSyncMonitor() {
+	if err := af.staticTG.Add(); err != nil {
+		return
+	}
+	defer af.staticTG.Done()
+
 	// Every 20 seconds, list all consensus groups and display the block height.
+	var state syncMonitorState
 	for {
-		// TODO: antfarm struct should have a threadgroup to be able to pick up
-		// stopchan signals
-		time.Sleep(monitorFrequency)
+		select {
+		case <-af.staticTG.StopChan():
+			return
+		case <-time.After(monitorFrequency):
+		}
 
 		// Grab consensus groups
-		groups, err := antConsensusGroups(af.allAnts()...)
+		groups, err := af.managedConsensusGroups(af.managedAllAnts()...)
 		if err != nil {
 			af.logger.Errorf("can't check sync status of antfarm: %v", err)
 			continue
 		}
 
+		// Check the sync policy
+		if alert := state.update(af.staticSyncPolicy, groups, time.Now()); alert != nil {
+			af.managedRaiseSyncAlert(*alert)
+		}
+
 		// Check if ants are synced
 		if len(groups) == 1 {
-			af.logger.Printf("ants are synchronized. Block Height: %v", af.Ants[0].BlockHeight())
+			af.logger.Printf("ants are synchronized. Block Height: %v", groups[0][0].BlockHeight())
 			continue
 		}
 
-		// Log out information about the unsync ants
+		// Record and log out information about the unsync ants
+		af.managedRecordSyncSplit(groups)
 		msg := "Ants split into multiple groups.\n"
 		for i, group := range groups {
 			msg += fmt.Sprintf("\tGroup %d:\n", i+1)
@@ -272,27 +669,27 @@ func (af *AntFarm) PermanentSyncMonitor() {
 	}
 }
 
-// getAnts is a http handler that returns the ants currently running on the
-// antfarm.
-func (af *AntFarm) getAnts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	err := json.NewEncoder(w).Encode(af.Ants)
-	if err != nil {
-		http.Error(w, "error encoding ants", 500)
-	}
-}
-
 // Close signals all the ants to stop and waits for them to return.
 func (af *AntFarm) Close() error {
 	af.logger.Println("starting to close antfarm")
-	if af.apiListener != nil {
-		if err := af.apiListener.Close(); err != nil {
-			af.logger.Errorf("can't close antfarm http API listener: %v", err)
-		}
+
+	// Stop the http API server and the sync monitor
+	if err := af.staticTG.Stop(); err != nil {
+		af.logger.Errorf("can't stop antfarm threadgroup: %v", err)
+	}
+
+	// Stop enforcing network partition
+	af.managedStopPartition()
+
+	// Write the report while the ants are still running, so that their final
+	// balances can be queried
+	if err := af.managedWriteReport(); err != nil {
+		af.logger.Errorf("can't write antfarm report: %v", err)
 	}
 
 	// Speed up closing ants by calling concurrent goroutines
 	var antCloseWG sync.WaitGroup
-	for _, a := range af.Ants {
+	for _, a := range af.managedAnts() {
 		antCloseWG.Add(1)
 		go func(a *ant.Ant) {
 			err := a.Close()
@@ -304,9 +701,39 @@ func (af *AntFarm) Close() error {
 	}
 	antCloseWG.Wait()
 
+	// Stop publishing events, this also ends the event streams of the API
+	af.mu.Lock()
+	for a := range af.eventForwards {
+		af.stopForwardingEvents(a)
+	}
+	af.mu.Unlock()
+	af.staticEvents.Close()
+
 	return nil
 }
 
+// Drain stops all ants from starting new uploads and downloads and waits for
+// their in-flight transfers to finish or for the context to be done.
+func (af *AntFarm) Drain(ctx context.Context) error {
+	af.logger.Println("draining antfarm")
+	var wg sync.WaitGroup
+	var errsMu sync.Mutex
+	var errs []error
+	for _, a := range af.managedAnts() {
+		wg.Add(1)
+		go func(a *ant.Ant) {
+			defer wg.Done()
+			if err := a.Drain(ctx); err != nil {
+				errsMu.Lock()
+				errs = append(errs, errors.AddContext(err, fmt.Sprintf("can't drain ant %v", a.Config.SiadConfig.DataDir)))
+				errsMu.Unlock()
+			}
+		}(a)
+	}
+	wg.Wait()
+	return errors.Compose(errs...)
+}
+
 // GetAntConfigIndexByName returns index of ant config in antfarm's AntConfigs
 // by given ant name
 func (afc *AntfarmConfig) GetAntConfigIndexByName(name string) (antConfigIndex int, err error) {
@@ -323,7 +750,7 @@ func (afc *AntfarmConfig) GetAntConfigIndexByName(name string) (antConfigIndex i
 func (afc *AntfarmConfig) GetHostAntConfigIndices() (antConfigIndices []int) {
 	for i, ac := range afc.AntConfigs {
 		for _, j := range ac.Jobs {
-			if j == "host" {
+			if j.Name == "host" {
 				antConfigIndices = append(antConfigIndices, i)
 				break
 			}
@@ -332,13 +759,25 @@ func (afc *AntfarmConfig) GetHostAntConfigIndices() (antConfigIndices []int) {
 	return antConfigIndices
 }
 
-// waitForAntsToSync waits for all ants to be synced with a given tmeout
-func (af *AntFarm) waitForAntsToSync(timeout time.Duration) error {
+// managedConsensusGroups returns consensus groups of the given ants. Consensus
+// checks are serialized, so that the monitor and the API don't race updating
+// the ants' seen blocks.
+func (af *AntFarm) managedConsensusGroups(ants ...*ant.Ant) ([][]*ant.Ant, error) {
+	af.consensusMu.Lock()
+	defer af.consensusMu.Unlock()
+	return antConsensusGroups(af.staticSyncPolicy.reorgDepth(), ants...)
+}
+
+// waitForAntsToSync waits for all ants to be synced until the context is
+// done. The ants' consensus is checked again when an ant observes a new block.
+func (af *AntFarm) waitForAntsToSync(ctx context.Context) error {
 	af.logger.Debugf("%v: waiting for all ants to sync...", af.dataDir)
-	start := time.Now()
+	events, unsubscribe := af.staticEvents.Subscribe(ant.EventNewBlock)
+	defer unsubscribe()
 	for {
 		// Check sync status
-		groups, err := antConsensusGroups(af.Ants...)
+		ants := af.managedAnts()
+		groups, err := af.managedConsensusGroups(ants...)
 		if err != nil {
 			return errors.AddContext(err, "unable to get consensus groups")
 		}
@@ -348,20 +787,45 @@ func (af *AntFarm) waitForAntsToSync(timeout time.Duration) error {
 			break
 		}
 
-		// We have reached timeout
-		if time.Since(start) > timeout {
-			return fmt.Errorf("ants didn't synced within %v timeout", timeout)
+		// Wait for jobs stop, context or a new block
+		if err := waitForNewBlock(ctx, events, ants[0].Jr.StaticTG.StopChan()); err != nil {
+			return errors.AddContext(err, "ants didn't sync")
 		}
+	}
+	af.logger.Debugf("%v: waiting for all ants to sync finished", af.dataDir)
+	return nil
+}
 
-		// Wait for jobs stop, timout or sleep
+// waitForNewBlock blocks until a new block event is received. Other new block
+// events received meanwhile are dropped, so that the ants' consensus is not
+// checked for each ant observing the same block.
+func waitForNewBlock(ctx context.Context, events <-chan ant.Event, stop <-chan struct{}) error {
+	for {
 		select {
-		case <-af.Ants[0].Jr.StaticTG.StopChan():
+		case <-stop:
 			// Jobs were stopped, do not wait anymore
 			return errors.New("jobs were stopped")
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return errors.New("antfarm was closed")
+			}
+			if e.Type != ant.EventNewBlock {
+				continue
+			}
+		}
+		// Throttle the checks and drop the buffered events
+		select {
+		case <-stop:
+			return errors.New("jobs were stopped")
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-time.After(waitForAntsToSyncFrequency):
-			// Continue waiting for sync after sleep
 		}
+		for len(events) > 0 {
+			<-events
+		}
+		return nil
 	}
-	af.logger.Debugf("%v: waiting for all ants to sync finished", af.dataDir)
-	return nil
 }
@@ -1,6 +1,7 @@
 package antfarm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"go.sia.tech/sia-antfarm/test"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node/api/client"
+	"gitlab.com/NebulousLabs/errors"
 )
 
 // verify that createAntfarm() creates a new antfarm correctly.
@@ -56,8 +58,8 @@ func TestNewAntfarm(t *testing.T) {
 					RPCAddr:                  antAddr,
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs: []string{
-					"gateway",
+				Jobs: []ant.JobConfig{
+					{Name: "gateway"},
 				},
 			},
 		},
@@ -131,8 +133,8 @@ func TestConnectExternalAntfarm(t *testing.T) {
 			AllowHostLocalNetAddress: true,
 			SiadPath:                 test.TestSiadFilename,
 		},
-		Jobs: []string{
-			"gateway",
+		Jobs: []ant.JobConfig{
+			{Name: "gateway"},
 		},
 	}
 
@@ -377,3 +379,341 @@ func TestUpdateRenter(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestAddRemoveAnt verifies that ants can be added to and removed from a
+// running antfarm.
+func TestAddRemoveAnt(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Start Antfarm
+	dataDir := test.TestDir(t.Name())
+	logger, err := NewAntfarmLogger(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	config, err := NewAntfarmConfig(dataDir, true, 0, 0, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farm, err := New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := farm.Close(); err != nil {
+			logger.Errorf("can't close antfarm: %v", err)
+		}
+	}()
+
+	// Adding an ant with a duplicate name fails
+	antDirs, err := test.AntDirs(filepath.Join(dataDir, "added"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	antConfig := ant.AntConfig{
+		SiadConfig: ant.SiadConfig{
+			AllowHostLocalNetAddress: true,
+			DataDir:                  antDirs[0],
+			SiadPath:                 test.TestSiadFilename,
+		},
+		Jobs: []ant.JobConfig{{Name: "generic"}},
+		Name: ant.NameGeneric(0),
+	}
+	if _, err := farm.AddAnt(antConfig); err == nil {
+		t.Fatal("expected adding an ant with a duplicate name to fail")
+	}
+
+	// Add an ant
+	antConfig.Name = ant.NameGeneric(2)
+	newAnt, err := farm.AddAnt(antConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(farm.managedAnts()) != 3 {
+		t.Fatalf("expected 3 ants, got %v", len(farm.managedAnts()))
+	}
+
+	// The new ant is connected to the other ants
+	gatewayInfo, err := newAnt.StaticClient.GatewayGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gatewayInfo.Peers) < 2 {
+		t.Fatalf("expected the new ant to have at least 2 peers, got %v", len(gatewayInfo.Peers))
+	}
+
+	// Remove the ant
+	err = farm.RemoveAnt(antConfig.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := farm.GetAntByName(antConfig.Name); err == nil {
+		t.Fatal("expected removed ant not to be found")
+	}
+	if err := farm.RemoveAnt(antConfig.Name); err == nil {
+		t.Fatal("expected removing a removed ant to fail")
+	}
+
+	// A littlesupplier ant added before a bigspender ant starts supplying
+	// once the bigspender ant is added
+	supplierConfig := antConfig
+	supplierConfig.Name = "Supplier"
+	supplierConfig.DataDir = antDirs[2]
+	supplierConfig.Jobs = []ant.JobConfig{{Name: "littlesupplier"}}
+	supplier, err := farm.AddAnt(supplierConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if supplier.HasActiveJob("littlesupplier") {
+		t.Fatal("expected no littlesupplier job without a bigspender ant")
+	}
+	spenderConfig := antConfig
+	spenderConfig.Name = "Spender"
+	spenderConfig.DataDir = antDirs[3]
+	spenderConfig.Jobs = []ant.JobConfig{{Name: "bigspender"}}
+	spender, err := farm.AddAnt(spenderConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !supplier.HasActiveJob("littlesupplier") {
+		t.Fatal("expected the littlesupplier job to start after adding a bigspender ant")
+	}
+	if !spender.HasActiveJob("bigspender") {
+		t.Fatal("expected the added ant to run the bigspender job")
+	}
+
+	// An unnamed ant isn't part of the running config, so the running config
+	// can be reloaded
+	antConfig.Name = ""
+	antConfig.DataDir = antDirs[1]
+	if _, err := farm.AddAnt(antConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := farm.Reload(farm.Config()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestResumeAntfarm verifies that an antfarm can be resumed from the state
+// saved by the previous antfarm run.
+func TestResumeAntfarm(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+	logger, err := NewAntfarmLogger(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	config, err := NewAntfarmConfig(dataDir, true, 0, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Resume = true
+
+	// Start and close the first antfarm
+	farm, err := New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiAddr := farm.Ants[0].APIAddr
+	seed := farm.Ants[0].Jr.StaticWalletSeed
+	if err := farm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resume the antfarm
+	farm, err = New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := farm.Close(); err != nil {
+			logger.Errorf("can't close antfarm: %v", err)
+		}
+	}()
+	if farm.Ants[0].APIAddr != apiAddr {
+		t.Fatalf("expected resumed ant API address %v, got %v", apiAddr, farm.Ants[0].APIAddr)
+	}
+	if farm.Ants[0].Jr.StaticWalletSeed != seed {
+		t.Fatal("expected resumed ant to use the same wallet seed")
+	}
+
+	// The running config is the config given by the user, so the resumed
+	// antfarm can reload the same config
+	expanded, err := config.Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	running := farm.Config()
+	if len(running.AntConfigs) != len(expanded.AntConfigs) || running.AntConfigs[0].APIAddr != "" || running.AntConfigs[0].Name != expanded.AntConfigs[0].Name {
+		t.Fatalf("expected running ant configs %+v, got %+v", expanded.AntConfigs, running.AntConfigs)
+	}
+	if err := farm.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPartitionHeal verifies that partitioned miners fork the blockchain and
+// get synced again after the partition is healed.
+func TestPartitionHeal(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Start Antfarm
+	dataDir := test.TestDir(t.Name())
+	logger, err := NewAntfarmLogger(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	config, err := NewAntfarmConfig(dataDir, true, 2, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farm, err := New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := farm.Close(); err != nil {
+			logger.Errorf("can't close antfarm: %v", err)
+		}
+	}()
+
+	// Partition the miners
+	err = farm.Partition([]string{ant.NameMiner(0)}, []string{ant.NameMiner(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups := farm.PartitionGroups(); len(groups) != 2 {
+		t.Fatalf("expected 2 partition groups, got %v", groups)
+	}
+
+	// Wait for the miners to fork
+	time.Sleep(time.Second * 30)
+	for _, a := range farm.managedAnts() {
+		gg, err := a.StaticClient.GatewayGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, peer := range gg.Peers {
+			for _, other := range farm.managedAnts() {
+				if other != a && peer.NetAddress.Port() == modules.NetAddress(other.RPCAddr).Port() {
+					t.Fatalf("partitioned ant %v is connected to %v", a.Config.Name, other.Config.Name)
+				}
+			}
+		}
+	}
+	groups, err := farm.managedConsensusGroups(farm.managedAnts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 consensus groups, got %v", len(groups))
+	}
+
+	// Heal the partition and wait for the miners to sync
+	if err := farm.Heal(); err != nil {
+		t.Fatal(err)
+	}
+	if groups := farm.PartitionGroups(); groups != nil {
+		t.Fatalf("expected no partition groups, got %v", groups)
+	}
+	if err := farm.WaitForAntsToSync(antsSyncTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWaitForNewBlock verifies waiting for new block events.
+func TestWaitForNewBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	events := make(chan ant.Event, 10)
+	stop := make(chan struct{})
+
+	// Other events are ignored, buffered new block events are dropped
+	events <- ant.Event{Type: ant.EventBalanceChanged}
+	events <- ant.Event{Type: ant.EventNewBlock}
+	events <- ant.Event{Type: ant.EventNewBlock}
+	if err := waitForNewBlock(context.Background(), events, stop); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected buffered events to be dropped, %v left", len(events))
+	}
+
+	// Waiting is aborted by the context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	events <- ant.Event{Type: ant.EventPeerConnected}
+	if err := waitForNewBlock(ctx, events, stop); !errors.Contains(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// Waiting is aborted when the jobs are stopped
+	close(stop)
+	if err := waitForNewBlock(context.Background(), events, stop); err == nil {
+		t.Fatal("expected error when the jobs are stopped")
+	}
+}
+
+// TestReserveAnt verifies that names and data directories of ants being added
+// are reserved until they are released.
+func TestReserveAnt(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	af := &AntFarm{}
+	first := ant.AntConfig{Name: ant.NameHost(0), SiadConfig: ant.SiadConfig{DataDir: "host-0"}}
+	if index, err := af.reserveAnt(&first); err != nil || index != 0 {
+		t.Fatalf("expected ant to be reserved with index 0, got %v, %v", index, err)
+	}
+	sameName := ant.AntConfig{Name: ant.NameHost(0)}
+	if _, err := af.reserveAnt(&sameName); err == nil {
+		t.Fatal("expected reserved name to be rejected")
+	}
+	sameDir := ant.AntConfig{Name: ant.NameHost(1), SiadConfig: ant.SiadConfig{DataDir: "host-0"}}
+	if _, err := af.reserveAnt(&sameDir); err == nil {
+		t.Fatal("expected reserved data directory to be rejected")
+	}
+	unnamed := ant.AntConfig{}
+	if index, err := af.reserveAnt(&unnamed); err != nil || index != 1 {
+		t.Fatalf("expected ant to be reserved with index 1, got %v, %v", index, err)
+	}
+
+	// Released names can be reserved again
+	af.releaseAnt(&first)
+	if _, err := af.reserveAnt(&sameName); err != nil {
+		t.Fatal(err)
+	}
+}
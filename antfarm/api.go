@@ -0,0 +1,473 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+type (
+	// AntJobRequest contains the fields to start a job on an ant through the
+	// antfarm API.
+	AntJobRequest struct {
+		// Job is the name of the job to start, e.g. "miner" or "host".
+		Job string
+
+		// Address is a wallet address the littlesupplier job sends Siacoins
+		// to. It is ignored by other jobs.
+		Address string `json:",omitempty"`
+	}
+
+	// AntSiadRequest contains the fields to (re)start an ant's siad through
+	// the antfarm API.
+	AntSiadRequest struct {
+		// SiadPath is the path to the siad binary. When starting an ant, an
+		// empty SiadPath means the ant's currently configured binary.
+		SiadPath string
+	}
+
+	// FaucetRequest contains the fields to fund an ant from the antfarm's
+	// faucet through the antfarm API.
+	FaucetRequest struct {
+		// Ant is the name of the funded ant.
+		Ant string
+
+		// Siacoins is the ant's target balance, the faucet sends the
+		// Siacoins the ant misses to reach it.
+		Siacoins uint64
+	}
+
+	// FaucetResponse contains the amount sent by the antfarm's faucet.
+	FaucetResponse struct {
+		Sent types.Currency
+	}
+
+	// PartitionRequest contains the fields to partition ants through the
+	// antfarm API.
+	PartitionRequest struct {
+		// Groups contains names of the ants in each partition group.
+		Groups [][]string
+	}
+
+	// onceCloseListener wraps a net.Listener so that it can be closed by both
+	// the http server and the antfarm.
+	onceCloseListener struct {
+		net.Listener
+		closeErr error
+		once     sync.Once
+	}
+)
+
+// Close closes the listener once, subsequent calls return the first call's
+// error.
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() {
+		l.closeErr = l.Listener.Close()
+	})
+	return l.closeErr
+}
+
+// initAPI constructs the antfarm API router.
+func (af *AntFarm) initAPI() {
+	af.router = httprouter.New()
+	af.router.GET("/ants", af.getAnts)
+	af.router.POST("/ants", af.postAnts)
+	af.router.GET("/ants/:name", af.getAnt)
+	af.router.DELETE("/ants/:name", af.deleteAnt)
+	af.router.GET("/ants/:name/metrics", af.getAntMetrics)
+	af.router.GET("/ants/:name/crashes", af.getAntCrashes)
+	af.router.POST("/ants/:name/stop", af.postAntStop)
+	af.router.POST("/ants/:name/start", af.postAntStart)
+	af.router.GET("/ants/:name/jobs", af.getAntJobs)
+	af.router.POST("/ants/:name/jobs", af.postAntJobs)
+	af.router.GET("/ants/:name/jobs/:id", af.getAntJob)
+	af.router.POST("/ants/:name/jobs/:id/stop", af.postAntJobStop)
+	af.router.POST("/ants/:name/jobs/:id/restart", af.postAntJobRestart)
+	af.router.POST("/ants/:name/update-siad", af.postAntUpdateSiad)
+	af.router.GET("/config", af.getConfig)
+	af.router.POST("/config", af.postConfig)
+	af.router.GET("/events", af.getEvents)
+	af.router.POST("/faucet", af.postFaucet)
+	af.router.GET("/metrics", af.getMetrics)
+	af.router.GET("/partition", af.getPartition)
+	af.router.POST("/partition", af.postPartition)
+	af.router.POST("/heal", af.postHeal)
+}
+
+// getAnts is a http handler that returns the ants currently running on the
+// antfarm.
+func (af *AntFarm) getAnts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	err := json.NewEncoder(w).Encode(af.managedAnts())
+	if err != nil {
+		http.Error(w, "error encoding ants", http.StatusInternalServerError)
+	}
+}
+
+// postAnts is a http handler that starts a new ant defined by the AntConfig in
+// the request body and adds it to the antfarm.
+func (af *AntFarm) postAnts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var config ant.AntConfig
+	if !decodeRequest(w, r, &config, false) {
+		return
+	}
+	a, err := af.AddAnt(config)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("can't add ant: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(a)
+	if err != nil {
+		af.logger.Errorf("can't encode added ant: %v", err)
+	}
+}
+
+// deleteAnt is a http handler that stops the ant with the given name and
+// removes it from the antfarm.
+func (af *AntFarm) deleteAnt(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := ps.ByName("name")
+	if _, err := af.GetAntByName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := af.RemoveAnt(name); err != nil {
+		http.Error(w, fmt.Sprintf("can't remove ant: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAnt is a http handler that returns the ant with the given name.
+func (af *AntFarm) getAnt(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	err := json.NewEncoder(w).Encode(a)
+	if err != nil {
+		http.Error(w, "error encoding ant", http.StatusInternalServerError)
+	}
+}
+
+// getAntMetrics is a http handler that returns the metrics collected by the
+// jobs of the ant with the given name.
+func (af *AntFarm) getAntMetrics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	err := json.NewEncoder(w).Encode(a.Metrics())
+	if err != nil {
+		http.Error(w, "error encoding ant metrics", http.StatusInternalServerError)
+	}
+}
+
+// getAntCrashes is a http handler that returns the siad crashes captured by
+// the supervisor of the ant with the given name.
+func (af *AntFarm) getAntCrashes(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	err := json.NewEncoder(w).Encode(a.Crashes())
+	if err != nil {
+		http.Error(w, "error encoding ant crashes", http.StatusInternalServerError)
+	}
+}
+
+// postAntStop is a http handler that stops the ant's jobs and its siad
+// process. The ant stays in the antfarm and can be started again.
+func (af *AntFarm) postAntStop(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	if err := a.Close(); err != nil {
+		http.Error(w, fmt.Sprintf("can't stop ant: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postAntStart is a http handler that starts a previously stopped ant.
+func (af *AntFarm) postAntStart(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	var req AntSiadRequest
+	if !decodeRequest(w, r, &req, true) {
+		return
+	}
+	siadPath := req.SiadPath
+	if siadPath == "" {
+		siadPath = a.Config.SiadPath
+	}
+	if err := a.StartSiad(siadPath); errors.Contains(err, ant.ErrAntRunning) {
+		http.Error(w, fmt.Sprintf("can't start ant: %v", err), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("can't start ant: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := af.managedSaveState(); err != nil {
+		af.logger.Errorf("can't save antfarm state: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postAntJobs is a http handler that starts a new job on the ant.
+func (af *AntFarm) postAntJobs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	var req AntJobRequest
+	if !decodeRequest(w, r, &req, false) {
+		return
+	}
+
+	job, err := ant.NewJob(req.Job)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("can't start job %v: %v", req.Job, err), http.StatusBadRequest)
+		return
+	}
+	if lj, ok := job.(*ant.LittleSupplierJob); ok {
+		if err := lj.SendAddress.LoadString(req.Address); err != nil {
+			http.Error(w, fmt.Sprintf("littlesupplier job requires a valid address: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := a.RunJob(job); err != nil {
+		http.Error(w, fmt.Sprintf("can't start job %v: %v", req.Job, err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAntJobs is a http handler that returns the statuses of the jobs started
+// on the ant.
+func (af *AntFarm) getAntJobs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	err := json.NewEncoder(w).Encode(a.JobStatuses())
+	if err != nil {
+		http.Error(w, "error encoding ant jobs", http.StatusInternalServerError)
+	}
+}
+
+// getAntJob is a http handler that returns the status of the ant's job.
+func (af *AntFarm) getAntJob(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, id, ok := af.antJobFromParams(w, ps)
+	if !ok {
+		return
+	}
+	status, err := a.JobStatus(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		http.Error(w, "error encoding ant job", http.StatusInternalServerError)
+	}
+}
+
+// postAntJobStop is a http handler that stops the ant's job, other jobs of the
+// ant keep running.
+func (af *AntFarm) postAntJobStop(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, id, ok := af.antJobFromParams(w, ps)
+	if !ok {
+		return
+	}
+	if _, err := a.JobStatus(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := a.StopJob(id); err != nil {
+		http.Error(w, fmt.Sprintf("can't stop job: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postAntJobRestart is a http handler that restarts the ant's job.
+func (af *AntFarm) postAntJobRestart(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, id, ok := af.antJobFromParams(w, ps)
+	if !ok {
+		return
+	}
+	if _, err := a.JobStatus(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := a.RestartJob(id); err != nil {
+		http.Error(w, fmt.Sprintf("can't restart job: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postAntUpdateSiad is a http handler that restarts the ant using the given
+// siad binary.
+func (af *AntFarm) postAntUpdateSiad(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return
+	}
+	var req AntSiadRequest
+	if !decodeRequest(w, r, &req, false) {
+		return
+	}
+	if req.SiadPath == "" {
+		http.Error(w, "siad path must be set", http.StatusBadRequest)
+		return
+	}
+	if err := a.UpdateSiad(req.SiadPath); err != nil {
+		http.Error(w, fmt.Sprintf("can't update ant's siad: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := af.managedSaveState(); err != nil {
+		af.logger.Errorf("can't save antfarm state: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postFaucet is a http handler that funds an ant from the antfarm's faucet up
+// to the target balance and returns the sent amount.
+func (af *AntFarm) postFaucet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req FaucetRequest
+	if !decodeRequest(w, r, &req, false) {
+		return
+	}
+	if _, err := af.GetAntByName(req.Ant); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sent, err := af.FundAnt(req.Ant, req.Siacoins)
+	if errors.Contains(err, errNoFaucetAnt) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("can't fund ant: %v", err), http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(FaucetResponse{Sent: sent})
+	if err != nil {
+		af.logger.Errorf("can't encode faucet response: %v", err)
+	}
+}
+
+// getPartition is a http handler that returns the current partition groups.
+func (af *AntFarm) getPartition(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	err := json.NewEncoder(w).Encode(PartitionRequest{Groups: af.PartitionGroups()})
+	if err != nil {
+		http.Error(w, "error encoding partition", http.StatusInternalServerError)
+	}
+}
+
+// postPartition is a http handler that partitions the ants into the given
+// groups.
+func (af *AntFarm) postPartition(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req PartitionRequest
+	if !decodeRequest(w, r, &req, false) {
+		return
+	}
+	if _, err := af.newNetworkPartition(req.Groups); err != nil {
+		http.Error(w, fmt.Sprintf("invalid partition: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := af.Partition(req.Groups...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postHeal is a http handler that heals the current partition.
+func (af *AntFarm) postHeal(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := af.Heal(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getConfig is a http handler that returns the running antfarm config.
+func (af *AntFarm) getConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	err := json.NewEncoder(w).Encode(af.Config())
+	if err != nil {
+		http.Error(w, "error encoding antfarm config", http.StatusInternalServerError)
+	}
+}
+
+// postConfig is a http handler that reloads the antfarm config, applying the
+// changes to the running antfarm.
+func (af *AntFarm) postConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var config AntfarmConfig
+	if !decodeRequest(w, r, &config, false) {
+		return
+	}
+	if err := af.Reload(config); errors.Contains(err, errConfigRejected) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// antFromParams returns the ant named in the request parameters. If there is
+// no such ant, it writes a not found error to the response and returns false.
+func (af *AntFarm) antFromParams(w http.ResponseWriter, ps httprouter.Params) (*ant.Ant, bool) {
+	a, err := af.GetAntByName(ps.ByName("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return a, true
+}
+
+// antJobFromParams returns the ant identified by the name parameter and the
+// job ID from the id parameter. If the ant doesn't exist or the ID is invalid,
+// it writes an error to the response and returns false.
+func (af *AntFarm) antJobFromParams(w http.ResponseWriter, ps httprouter.Params) (*ant.Ant, uint64, bool) {
+	a, ok := af.antFromParams(w, ps)
+	if !ok {
+		return nil, 0, false
+	}
+	id, err := strconv.ParseUint(ps.ByName("id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid job id: %v", err), http.StatusBadRequest)
+		return nil, 0, false
+	}
+	return a, id, true
+}
+
+// decodeRequest decodes the JSON request body into v. If allowEmpty is set,
+// an empty body is accepted and v is left unchanged. On failure it writes a
+// bad request error to the response and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}, allowEmpty bool) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if allowEmpty && errors.Contains(err, io.EOF) {
+		return true
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("can't decode request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package antfarm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/test"
+)
+
+// TestAntfarmAPI verifies that ants can be inspected, stopped, started and
+// given new jobs through the antfarm API.
+func TestAntfarmAPI(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	addr, err := ant.GetAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	antFarmAddr := "127.0.0.1" + addr
+	dataDir := test.TestDir(t.Name())
+	antFarmDir := filepath.Join(dataDir, "antfarm-data")
+	antDirs, err := test.AntDirs(dataDir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := NewAntfarmLogger(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	antName := "api-ant"
+	config := AntfarmConfig{
+		ListenAddress: antFarmAddr,
+		DataDir:       antFarmDir,
+		AntConfigs: []ant.AntConfig{
+			{
+				SiadConfig: ant.SiadConfig{
+					AllowHostLocalNetAddress: true,
+					DataDir:                  antDirs[0],
+					SiadPath:                 test.TestSiadFilename,
+				},
+				Jobs: []ant.JobConfig{{Name: "gateway"}},
+				Name: antName,
+			},
+		},
+	}
+
+	farm, err := New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := farm.Close(); err != nil {
+			logger.Errorf("can't close antfarm: %v", err)
+		}
+	}()
+	go func() {
+		if err := farm.ServeAPI(); err != nil {
+			logger.Errorf("can't serve antfarm http API: %v", err)
+		}
+	}()
+	baseURL := "http://" + antFarmAddr
+
+	// Get the ant by name
+	res, err := http.Get(baseURL + "/ants/" + antName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a ant.Ant
+	err = json.NewDecoder(res.Body).Decode(&a)
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Config.Name != antName {
+		t.Fatalf("expected ant name %v, got %v", antName, a.Config.Name)
+	}
+
+	// Unknown ant is reported as not found
+	res, err = http.Get(baseURL + "/ants/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %v, got %v", http.StatusNotFound, res.StatusCode)
+	}
+
+	// post is a helper to post a JSON request and check the response status.
+	post := func(path string, req interface{}, expectedStatus int) {
+		var body bytes.Buffer
+		if req != nil {
+			if err := json.NewEncoder(&body).Encode(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := http.Post(baseURL+path, "application/json", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := res.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != expectedStatus {
+			t.Fatalf("%v: expected status %v, got %v", path, expectedStatus, res.StatusCode)
+		}
+	}
+
+	// Start jobs
+	post("/ants/"+antName+"/jobs", AntJobRequest{Job: "generic"}, http.StatusNoContent)
+	post("/ants/"+antName+"/jobs", AntJobRequest{Job: "thisjobdoesnotexist"}, http.StatusBadRequest)
+
+	// Get job statuses, the gateway job from the config and the generic job
+	// are tracked
+	res, err = http.Get(baseURL + "/ants/" + antName + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []ant.JobStatus
+	err = json.NewDecoder(res.Body).Decode(&statuses)
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "gateway" || statuses[1].Name != "generic" {
+		t.Fatalf("unexpected job statuses %+v", statuses)
+	}
+
+	// Stop and restart the gateway job
+	gatewayJobPath := fmt.Sprintf("/ants/%v/jobs/%v", antName, statuses[0].ID)
+	post(gatewayJobPath+"/stop", nil, http.StatusNoContent)
+	post(gatewayJobPath+"/stop", nil, http.StatusBadRequest)
+	post(gatewayJobPath+"/restart", nil, http.StatusNoContent)
+	post("/ants/"+antName+"/jobs/1000/stop", nil, http.StatusNotFound)
+	post("/ants/"+antName+"/jobs/invalid/stop", nil, http.StatusBadRequest)
+
+	// Stream events filtered by type
+	res, err = http.Get(baseURL + "/events?type=" + string(ant.EventBalanceChanged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	farm.Events().Publish(ant.Event{Type: ant.EventNewBlock, Ant: antName})
+	farm.Events().Publish(ant.Event{Type: ant.EventBalanceChanged, Ant: antName})
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "event: " + string(ant.EventBalanceChanged) + "\n"; line != expected {
+		t.Fatalf("expected %q, got %q", expected, line)
+	}
+
+	// Stop and start the ant
+	post("/ants/"+antName+"/stop", nil, http.StatusNoContent)
+	post("/ants/"+antName+"/start", nil, http.StatusNoContent)
+	post("/ants/"+antName+"/start", nil, http.StatusConflict)
+
+	// The ant is running again
+	if _, err := farm.Ants[0].StaticClient.ConsensusGet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the config with a generic job added to the ant
+	reloaded := farm.Config()
+	reloaded.AntConfigs[0].Jobs = append(reloaded.AntConfigs[0].Jobs, ant.JobConfig{Name: "generic"})
+	post("/config", reloaded, http.StatusNoContent)
+	if jobs := farm.Ants[0].JobConfigs(); len(jobs) != 2 || jobs[1].Name != "generic" {
+		t.Fatalf("unexpected job configs after reload %+v", jobs)
+	}
+
+	// Changes which can't be applied live are rejected
+	reloaded.ListenAddress = "127.0.0.1:0"
+	post("/config", reloaded, http.StatusBadRequest)
+
+	// If an ant can't be started, the ants added before are removed again
+	failing := farm.Config()
+	failing.AntConfigs = append(failing.AntConfigs,
+		ant.AntConfig{
+			SiadConfig: ant.SiadConfig{
+				AllowHostLocalNetAddress: true,
+				DataDir:                  antDirs[1],
+				SiadPath:                 test.TestSiadFilename,
+			},
+			Name: "added-ant",
+		},
+		ant.AntConfig{
+			SiadConfig: ant.SiadConfig{
+				AllowHostLocalNetAddress: true,
+				DataDir:                  antDirs[2],
+				SiadPath:                 filepath.Join(dataDir, "missing-siad"),
+			},
+			Name: "failing-ant",
+		},
+	)
+	post("/config", failing, http.StatusInternalServerError)
+	if _, err := farm.GetAntByName("added-ant"); err == nil {
+		t.Fatal("expected the added ant to be removed after the failed reload")
+	}
+	if configs := farm.Config().AntConfigs; len(configs) != 1 {
+		t.Fatalf("expected the running config to be rolled back, got %+v", configs)
+	}
+}
@@ -0,0 +1,89 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"go.sia.tech/sia-antfarm/ant"
+)
+
+// forwardEvents forwards the ant's events to the antfarm's event broker until
+// stopForwardingEvents is called. It must be called under mu.
+func (af *AntFarm) forwardEvents(a *ant.Ant) {
+	if a.Events() == nil {
+		return
+	}
+	af.eventForwards[a] = a.Events().Forward(af.staticEvents)
+}
+
+// stopForwardingEvents stops forwarding the ant's events to the antfarm's
+// event broker. It must be called under mu.
+func (af *AntFarm) stopForwardingEvents(a *ant.Ant) {
+	if unsubscribe, ok := af.eventForwards[a]; ok {
+		unsubscribe()
+		delete(af.eventForwards, a)
+	}
+}
+
+// Events returns the broker publishing the events of all ants managed by the
+// antfarm.
+func (af *AntFarm) Events() *ant.EventBroker {
+	return af.staticEvents
+}
+
+// getEvents is a http handler that streams the ants' events as server-sent
+// events until the client disconnects. The events can be filtered by the ant
+// and type query parameters, each of them can be repeated.
+func (af *AntFarm) getEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	filter := func(values []string) map[string]struct{} {
+		m := make(map[string]struct{})
+		for _, v := range values {
+			m[v] = struct{}{}
+		}
+		return m
+	}
+	ants := filter(r.URL.Query()["ant"])
+	var types []ant.EventType
+	for _, t := range r.URL.Query()["type"] {
+		types = append(types, ant.EventType(t))
+	}
+
+	// Subscribe only to the requested event types, so that the ants don't
+	// poll the state behind the other types
+	events, unsubscribe := af.staticEvents.Subscribe(types...)
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, ok := ants[e.Ant]; len(ants) > 0 && !ok {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				af.logger.Errorf("can't encode event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
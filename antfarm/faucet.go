@@ -0,0 +1,80 @@
+package antfarm
+
+import (
+	"fmt"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+var (
+	// errNoFaucetAnt is returned when funds are requested from an antfarm
+	// without a faucet ant.
+	errNoFaucetAnt = errors.New("antfarm has no faucet ant")
+)
+
+// Fund implements ant.Faucet. It sends Siacoins from the wallet of the
+// antfarm's faucet ant to the ant's wallet, so that the ant's balance,
+// including unconfirmed incoming Siacoins, reaches the target balance.
+func (af *AntFarm) Fund(a *ant.Ant, target types.Currency) error {
+	_, err := af.managedFund(a, target)
+	return err
+}
+
+// FundAnt funds the ant with the given name up to the target balance of
+// Siacoins from the antfarm's faucet ant and returns the sent amount.
+func (af *AntFarm) FundAnt(name string, targetSiacoins uint64) (types.Currency, error) {
+	a, err := af.GetAntByName(name)
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+	return af.managedFund(a, types.SiacoinPrecision.Mul64(targetSiacoins))
+}
+
+// managedFund sends the Siacoins the ant misses to reach the target balance
+// from the faucet ant's wallet and returns the sent amount.
+func (af *AntFarm) managedFund(a *ant.Ant, target types.Currency) (types.Currency, error) {
+	if af.staticFaucetAnt == "" {
+		return types.ZeroCurrency, errNoFaucetAnt
+	}
+	faucet, err := af.GetAntByName(af.staticFaucetAnt)
+	if err != nil {
+		return types.ZeroCurrency, errors.AddContext(err, "can't get faucet ant")
+	}
+	if faucet == a {
+		return types.ZeroCurrency, errors.New("faucet ant can't fund itself")
+	}
+
+	// Transfers are serialized, so that a transfer sees the unconfirmed
+	// transfers sent before it and the faucet ant's wallet doesn't spend the
+	// same outputs twice
+	af.faucetMu.Lock()
+	defer af.faucetMu.Unlock()
+
+	wg, err := a.StaticClient.WalletGet()
+	if err != nil {
+		return types.ZeroCurrency, errors.AddContext(err, "can't get ant's wallet info")
+	}
+	balance := wg.ConfirmedSiacoinBalance.Add(wg.UnconfirmedIncomingSiacoins)
+	if balance.Cmp(wg.UnconfirmedOutgoingSiacoins) > 0 {
+		balance = balance.Sub(wg.UnconfirmedOutgoingSiacoins)
+	} else {
+		balance = types.ZeroCurrency
+	}
+	if balance.Cmp(target) >= 0 {
+		return types.ZeroCurrency, nil
+	}
+	amount := target.Sub(balance)
+
+	addr, err := a.WalletAddress()
+	if err != nil {
+		return types.ZeroCurrency, errors.AddContext(err, "can't get ant's wallet address")
+	}
+	_, err = faucet.StaticClient.WalletSiacoinsPost(amount, *addr, false)
+	if err != nil {
+		return types.ZeroCurrency, errors.AddContext(err, fmt.Sprintf("faucet ant %v can't send %v", af.staticFaucetAnt, amount.HumanString()))
+	}
+	af.logger.Printf("faucet ant %v sent %v to ant %v", af.staticFaucetAnt, amount.HumanString(), a.Config.DataDir)
+	return amount, nil
+}
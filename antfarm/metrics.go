@@ -0,0 +1,253 @@
+package antfarm
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// metricsNamespace defines prefix of all exported Prometheus metrics
+	metricsNamespace = "antfarm"
+
+	// metricsContentType defines content type of Prometheus text exposition
+	// format
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type (
+	// metricFamily contains samples of one Prometheus metric.
+	metricFamily struct {
+		help    string
+		typ     string
+		samples []metricSample
+	}
+
+	// metricSample contains one sample of a Prometheus metric. The suffix is
+	// appended to the metric name, e.g. "_bucket" for histogram buckets.
+	metricSample struct {
+		suffix string
+		labels [][2]string
+		value  float64
+	}
+
+	// metricsWriter collects metric families and writes them in Prometheus
+	// text exposition format.
+	metricsWriter struct {
+		families map[string]*metricFamily
+		names    []string
+	}
+)
+
+// newMetricsWriter returns a new empty metrics writer.
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{families: make(map[string]*metricFamily)}
+}
+
+// add adds a sample to the metric family with the given name. The family is
+// created on the first sample.
+func (mw *metricsWriter) add(name, typ, help string, s metricSample) {
+	f, ok := mw.families[name]
+	if !ok {
+		f = &metricFamily{help: help, typ: typ}
+		mw.families[name] = f
+		mw.names = append(mw.names, name)
+	}
+	f.samples = append(f.samples, s)
+}
+
+// gauge adds a gauge sample for the given ant.
+func (mw *metricsWriter) gauge(name, help, antLabel string, value float64) {
+	mw.add(metricsNamespace+"_"+name, "gauge", help, metricSample{
+		labels: [][2]string{{"ant", antLabel}},
+		value:  value,
+	})
+}
+
+// WriteTo writes all metric families in Prometheus text exposition format.
+func (mw *metricsWriter) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	for _, name := range mw.names {
+		f := mw.families[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", name, f.typ)
+		for _, s := range f.samples {
+			sb.WriteString(name + s.suffix)
+			if len(s.labels) > 0 {
+				var labels []string
+				for _, l := range s.labels {
+					labels = append(labels, fmt.Sprintf("%s=\"%s\"", l[0], escapeLabelValue(l[1])))
+				}
+				sb.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+			sb.WriteString(" " + formatMetricValue(s.value) + "\n")
+		}
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// escapeLabelValue escapes backslash, double-quote and line feed characters
+// in a Prometheus label value.
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatMetricValue formats a float value for Prometheus text exposition
+// format.
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// siacoins converts the given currency to a float number of Siacoins.
+func siacoins(c types.Currency) float64 {
+	sc, _ := new(big.Rat).SetFrac(c.Big(), types.SiacoinPrecision.Big()).Float64()
+	return sc
+}
+
+// antLabel returns a label value identifying the given ant, i.e. ant's name
+// or ant's data directory if the ant has no name.
+func antLabel(a *ant.Ant) string {
+	if a.Config.Name != "" {
+		return a.Config.Name
+	}
+	return a.Config.DataDir
+}
+
+// writeJobMetrics adds the given metrics collected by jobs of the ant with the
+// given label.
+func (mw *metricsWriter) writeJobMetrics(label string, s ant.MetricsSnapshot) {
+	for _, name := range s.CounterNames() {
+		mw.add(metricsNamespace+"_job_"+string(name)+"_total", "counter", "Job metric "+string(name)+".", metricSample{
+			labels: [][2]string{{"ant", label}},
+			value:  float64(s.Counters[name]),
+		})
+	}
+	for _, name := range s.HistogramNames() {
+		h := s.Histograms[name]
+		metricName := metricsNamespace + "_job_" + string(name)
+		help := "Job metric " + string(name) + "."
+		for i, upperBound := range h.Buckets {
+			mw.add(metricName, "histogram", help, metricSample{
+				suffix: "_bucket",
+				labels: [][2]string{{"ant", label}, {"le", formatMetricValue(upperBound)}},
+				value:  float64(h.BucketCounts[i]),
+			})
+		}
+		mw.add(metricName, "histogram", help, metricSample{
+			suffix: "_bucket",
+			labels: [][2]string{{"ant", label}, {"le", "+Inf"}},
+			value:  float64(h.Count),
+		})
+		mw.add(metricName, "histogram", help, metricSample{
+			suffix: "_sum",
+			labels: [][2]string{{"ant", label}},
+			value:  h.Sum,
+		})
+		mw.add(metricName, "histogram", help, metricSample{
+			suffix: "_count",
+			labels: [][2]string{{"ant", label}},
+			value:  float64(h.Count),
+		})
+	}
+}
+
+// writeAntMetrics adds gauges queried from the ant's siad. Metrics which
+// can't be queried, e.g. because the ant is stopped, are skipped.
+func (af *AntFarm) writeAntMetrics(mw *metricsWriter, a *ant.Ant) {
+	label := antLabel(a)
+	c := a.StaticClient
+	mw.gauge("ant_block_height", "Highest block height seen by the ant.", label, float64(a.BlockHeight()))
+
+	wg, err := c.WalletGet()
+	if err != nil {
+		af.logger.Debugf("%v: metrics: can't get wallet info: %v", a.Config.DataDir, err)
+	} else {
+		mw.gauge("ant_wallet_confirmed_siacoins", "Confirmed Siacoin balance of the ant's wallet.", label, siacoins(wg.ConfirmedSiacoinBalance))
+	}
+
+	gg, err := c.GatewayGet()
+	if err != nil {
+		af.logger.Debugf("%v: metrics: can't get gateway info: %v", a.Config.DataDir, err)
+	} else {
+		mw.gauge("ant_gateway_peers", "Number of the ant's gateway peers.", label, float64(len(gg.Peers)))
+	}
+
+	if a.HasRenterTypeJob() {
+		rc, err := c.RenterContractsGet()
+		if err != nil {
+			af.logger.Debugf("%v: metrics: can't get renter contracts: %v", a.Config.DataDir, err)
+		} else {
+			mw.gauge("ant_renter_active_contracts", "Number of the renter's active contracts.", label, float64(len(rc.ActiveContracts)))
+		}
+
+		rf, err := c.RenterFilesGet(true)
+		if err != nil {
+			af.logger.Debugf("%v: metrics: can't get renter files: %v", a.Config.DataDir, err)
+		} else {
+			var progress float64
+			for _, f := range rf.Files {
+				progress += f.UploadProgress
+			}
+			if len(rf.Files) > 0 {
+				progress /= float64(len(rf.Files))
+			}
+			mw.gauge("ant_renter_files", "Number of the renter's files.", label, float64(len(rf.Files)))
+			mw.gauge("ant_renter_upload_progress_percent", "Average upload progress of the renter's files.", label, progress)
+		}
+	}
+
+	if a.HasJob("host") {
+		hg, err := c.HostGet()
+		if err != nil {
+			af.logger.Debugf("%v: metrics: can't get host info: %v", a.Config.DataDir, err)
+		} else {
+			mw.gauge("ant_host_storage_revenue_siacoins", "Storage revenue of the host.", label, siacoins(hg.FinancialMetrics.StorageRevenue))
+		}
+	}
+
+	mw.writeJobMetrics(label, a.Metrics())
+}
+
+// getMetrics is a http handler that returns antfarm and ant metrics in
+// Prometheus text exposition format.
+func (af *AntFarm) getMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	mw := newMetricsWriter()
+
+	// Update seen blocks of the ants and count consensus groups
+	ants := af.managedAnts()
+	groups, err := af.managedConsensusGroups(af.managedAllAnts()...)
+	if err != nil {
+		af.logger.Debugf("metrics: can't get consensus groups: %v", err)
+	} else {
+		mw.add(metricsNamespace+"_consensus_groups", "gauge", "Number of consensus groups the ants are split into.", metricSample{
+			value: float64(len(groups)),
+		})
+	}
+	mw.add(metricsNamespace+"_ants", "gauge", "Number of ants in the antfarm.", metricSample{
+		value: float64(len(ants)),
+	})
+
+	af.consensusMu.Lock()
+	for _, a := range ants {
+		af.writeAntMetrics(mw, a)
+	}
+	af.consensusMu.Unlock()
+
+	w.Header().Set("Content-Type", metricsContentType)
+	if _, err := mw.WriteTo(w); err != nil {
+		af.logger.Errorf("can't write metrics: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package antfarm
+
+import (
+	"bytes"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+)
+
+// TestMetricsWriter verifies that metrics are written in Prometheus text
+// exposition format.
+func TestMetricsWriter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	mw := newMetricsWriter()
+	mw.add(metricsNamespace+"_consensus_groups", "gauge", "Number of consensus groups.", metricSample{value: 1})
+	mw.gauge("ant_block_height", "Block height.", "ant\"1", 42)
+	mw.gauge("ant_block_height", "Block height.", "ant2", 43.5)
+
+	// Add job metrics of an ant
+	m := ant.NewJobMetrics()
+	m.IncCounter(ant.MetricUploadsCompleted)
+	m.IncCounter(ant.MetricUploadsCompleted)
+	m.Observe(ant.MetricUploadDuration, 3)
+	mw.writeJobMetrics("dir", m.Snapshot())
+
+	var buf bytes.Buffer
+	_, err := mw.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP antfarm_consensus_groups Number of consensus groups.
+# TYPE antfarm_consensus_groups gauge
+antfarm_consensus_groups 1
+# HELP antfarm_ant_block_height Block height.
+# TYPE antfarm_ant_block_height gauge
+antfarm_ant_block_height{ant="ant\"1"} 42
+antfarm_ant_block_height{ant="ant2"} 43.5
+# HELP antfarm_job_renter_uploads_completed_total Job metric renter_uploads_completed.
+# TYPE antfarm_job_renter_uploads_completed_total counter
+antfarm_job_renter_uploads_completed_total{ant="dir"} 2
+# HELP antfarm_job_renter_upload_duration_seconds Job metric renter_upload_duration_seconds.
+# TYPE antfarm_job_renter_upload_duration_seconds histogram
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="1"} 0
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="5"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="10"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="30"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="60"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="120"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="300"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="600"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="1800"} 1
+antfarm_job_renter_upload_duration_seconds_bucket{ant="dir",le="+Inf"} 1
+antfarm_job_renter_upload_duration_seconds_sum{ant="dir"} 3
+antfarm_job_renter_upload_duration_seconds_count{ant="dir"} 1
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected metrics output\ngot:\n%v\nwant:\n%v", buf.String(), expected)
+	}
+}
+
+// TestSiacoins verifies conversion of currency to Siacoins.
+func TestSiacoins(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	if sc := siacoins(types.SiacoinPrecision.Mul64(3).Div64(2)); sc != 1.5 {
+		t.Fatalf("expected 1.5 SC, got %v", sc)
+	}
+}
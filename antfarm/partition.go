@@ -0,0 +1,199 @@
+package antfarm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/modules"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// partitionEnforceFrequency defines how frequently partitioned ants are
+	// checked for gateway peers from other partition groups.
+	partitionEnforceFrequency = time.Second
+)
+
+// networkPartition describes ants split into partition groups. It is
+// immutable once created.
+type networkPartition struct {
+	// groups contains names of the ants in each partition group.
+	groups [][]string
+
+	// ants contains the partitioned ants.
+	ants []*ant.Ant
+
+	// groupByPort maps ants' RPC ports to the ants' partition group index.
+	groupByPort map[string]int
+
+	// stopChan is closed to stop enforcing the partition, doneChan is closed
+	// when the enforcing has stopped.
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// rpcPort returns the port of the given RPC address.
+func rpcPort(addr string) string {
+	return modules.NetAddress(addr).Port()
+}
+
+// newNetworkPartition creates a network partition of the given ants grouped
+// by names.
+func (af *AntFarm) newNetworkPartition(groups [][]string) (*networkPartition, error) {
+	if len(groups) < 2 {
+		return nil, errors.New("at least 2 partition groups are required")
+	}
+	p := &networkPartition{
+		groups:      groups,
+		groupByPort: make(map[string]int),
+		stopChan:    make(chan struct{}),
+		doneChan:    make(chan struct{}),
+	}
+	seen := make(map[string]struct{})
+	for gi, group := range groups {
+		if len(group) == 0 {
+			return nil, fmt.Errorf("partition group %v is empty", gi)
+		}
+		for _, name := range group {
+			if _, ok := seen[name]; ok {
+				return nil, fmt.Errorf("ant %v is in multiple partition groups", name)
+			}
+			seen[name] = struct{}{}
+			a, err := af.GetAntByName(name)
+			if err != nil {
+				return nil, err
+			}
+			p.ants = append(p.ants, a)
+			p.groupByPort[rpcPort(a.RPCAddr)] = gi
+		}
+	}
+	return p, nil
+}
+
+// enforce disconnects all gateway peers of the partitioned ants which belong
+// to other partition groups.
+func (p *networkPartition) enforce() error {
+	var errs error
+	for _, a := range p.ants {
+		gi := p.groupByPort[rpcPort(a.RPCAddr)]
+		gg, err := a.StaticClient.GatewayGet()
+		if err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, fmt.Sprintf("can't get gateway peers of ant %v", a.Config.Name)))
+			continue
+		}
+		for _, peer := range gg.Peers {
+			peerGI, ok := p.groupByPort[peer.NetAddress.Port()]
+			if !ok || peerGI == gi {
+				continue
+			}
+			err := a.StaticClient.GatewayDisconnectPost(peer.NetAddress)
+			if err != nil && !strings.Contains(err.Error(), "not connected") {
+				errs = errors.Compose(errs, errors.AddContext(err, fmt.Sprintf("can't disconnect ant %v from %v", a.Config.Name, peer.NetAddress)))
+			}
+		}
+	}
+	return errs
+}
+
+// threadedEnforce keeps disconnecting the partitioned ants from peers of
+// other partition groups, so that the gateways can't reconnect, until the
+// partition is stopped.
+func (af *AntFarm) threadedEnforce(p *networkPartition) {
+	defer close(p.doneChan)
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-time.After(partitionEnforceFrequency):
+		}
+		if err := p.enforce(); err != nil {
+			af.logger.Debugf("can't enforce network partition: %v", err)
+		}
+	}
+}
+
+// stop stops enforcing the partition and waits until the enforcing thread
+// returns.
+func (p *networkPartition) stop() {
+	close(p.stopChan)
+	<-p.doneChan
+}
+
+// Partition splits the given ants, identified by names, into partition
+// groups. Ants in different groups are disconnected from each other and their
+// gateways are prevented from reconnecting until Heal is called. Ants not
+// listed in any group keep their connections. An existing partition is
+// replaced.
+func (af *AntFarm) Partition(groups ...[]string) error {
+	p, err := af.newNetworkPartition(groups)
+	if err != nil {
+		return errors.AddContext(err, "invalid partition")
+	}
+
+	af.partitionMu.Lock()
+	defer af.partitionMu.Unlock()
+	if af.partition != nil {
+		af.partition.stop()
+		af.partition = nil
+	}
+	if err := p.enforce(); err != nil {
+		return errors.AddContext(err, "can't partition ants")
+	}
+	af.partition = p
+	go af.threadedEnforce(p)
+	af.logger.Printf("ants partitioned into groups %v", groups)
+	return nil
+}
+
+// Heal stops enforcing the current partition and reconnects the partition
+// groups. It is a no-op if the ants are not partitioned.
+func (af *AntFarm) Heal() error {
+	af.partitionMu.Lock()
+	defer af.partitionMu.Unlock()
+	p := af.partition
+	if p == nil {
+		return nil
+	}
+	p.stop()
+	af.partition = nil
+
+	// Connect the first ant of the first group to all ants of the other
+	// groups.
+	target := p.ants[0]
+	var errs error
+	for _, a := range p.ants[len(p.groups[0]):] {
+		err := ConnectAnts(target, a)
+		if err != nil && !strings.Contains(err.Error(), "already connected") {
+			errs = errors.Compose(errs, errors.AddContext(err, fmt.Sprintf("can't reconnect ant %v", a.Config.Name)))
+		}
+	}
+	if errs != nil {
+		return errors.AddContext(errs, "can't heal partition")
+	}
+	af.logger.Printf("network partition %v healed", p.groups)
+	return nil
+}
+
+// PartitionGroups returns names of the ants in each partition group of the
+// current partition, or nil if the ants are not partitioned.
+func (af *AntFarm) PartitionGroups() [][]string {
+	af.partitionMu.Lock()
+	defer af.partitionMu.Unlock()
+	if af.partition == nil {
+		return nil
+	}
+	return af.partition.groups
+}
+
+// managedStopPartition stops enforcing the current partition without
+// reconnecting the ants.
+func (af *AntFarm) managedStopPartition() {
+	af.partitionMu.Lock()
+	defer af.partitionMu.Unlock()
+	if af.partition != nil {
+		af.partition.stop()
+		af.partition = nil
+	}
+}
@@ -0,0 +1,47 @@
+package antfarm
+
+import (
+	"fmt"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+)
+
+// TestNewNetworkPartition verifies validation of partition groups.
+func TestNewNetworkPartition(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	af := &AntFarm{}
+	for i, name := range []string{"a", "b", "c"} {
+		af.Ants = append(af.Ants, &ant.Ant{
+			Config:  ant.AntConfig{Name: name},
+			RPCAddr: fmt.Sprintf("127.0.0.1:%d", i+1),
+		})
+	}
+
+	invalid := [][][]string{
+		{{"a", "b", "c"}},
+		{{"a"}, {}},
+		{{"a"}, {"a", "b"}},
+		{{"a"}, {"unknown"}},
+	}
+	for _, groups := range invalid {
+		if _, err := af.newNetworkPartition(groups); err == nil {
+			t.Errorf("expected partition %v to be invalid", groups)
+		}
+	}
+
+	p, err := af.newNetworkPartition([][]string{{"a", "b"}, {"c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ants) != 3 {
+		t.Fatalf("expected 3 partitioned ants, got %v", len(p.ants))
+	}
+	if p.groupByPort["1"] != 0 || p.groupByPort["2"] != 0 || p.groupByPort["3"] != 1 {
+		t.Fatalf("unexpected partition groups by port: %v", p.groupByPort)
+	}
+}
@@ -0,0 +1,330 @@
+package antfarm
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+var (
+	// errConfigRejected is returned when a reloaded config is rejected
+	// because it is invalid or it can't be applied live.
+	errConfigRejected = errors.New("antfarm config was rejected")
+)
+
+type (
+	// configDiff contains the changes between the running antfarm config and
+	// a reloaded antfarm config which can be applied live.
+	configDiff struct {
+		added   []ant.AntConfig
+		removed []string
+		updated []antConfigUpdate
+	}
+
+	// antConfigUpdate contains the running and the reloaded config of an ant
+	// whose jobs or desired currency changed.
+	antConfigUpdate struct {
+		old ant.AntConfig
+		new ant.AntConfig
+	}
+)
+
+// Config returns the running antfarm config. Ants added to or removed from
+// the running antfarm are included, the jobs and the desired currency of named
+// ants are the live settings of the running ants.
+func (af *AntFarm) Config() AntfarmConfig {
+	af.mu.Lock()
+	config := af.config
+	config.AntConfigs = append([]ant.AntConfig(nil), af.config.AntConfigs...)
+	ants := append([]*ant.Ant(nil), af.Ants...)
+	af.mu.Unlock()
+
+	// The live settings are read without holding mu, because a balance
+	// maintainer being replaced may wait for the faucet which locks mu
+	for i, c := range config.AntConfigs {
+		if c.Name == "" {
+			continue
+		}
+		for _, a := range ants {
+			if a.Config.Name == c.Name {
+				config.AntConfigs[i].Jobs = a.JobConfigs()
+				config.AntConfigs[i].DesiredCurrency = a.DesiredCurrency()
+				break
+			}
+		}
+	}
+	return config
+}
+
+// Reload applies the changes of the given antfarm config to the running
+// antfarm. Named ants added to the config are started, the jobs and the
+// desired currency of the other named ants are updated and named ants removed
+// from the config are stopped. If the config is invalid or changes anything
+// else, it is rejected without applying any change. If starting or updating
+// an ant fails, the changes applied before are rolled back. Ant groups are
+// expanded into ants before the configs are compared.
+func (af *AntFarm) Reload(config AntfarmConfig) error {
+	af.reloadMu.Lock()
+	defer af.reloadMu.Unlock()
+
+	// Ant groups are compared as the ants they expand into
+	config, err := config.Expand()
+	if err != nil {
+		return errors.AddContext(errors.Compose(errConfigRejected, err), "can't reload antfarm config")
+	}
+
+	// Validate the whole config before applying any change
+	if problems := ValidateConfig(config, false); len(problems) > 0 {
+		return errors.AddContext(errors.Compose(errConfigRejected, configProblemsError(problems)), "can't reload antfarm config")
+	}
+	diff, err := diffConfigs(af.Config(), config)
+	if err != nil {
+		return errors.AddContext(err, "can't reload antfarm config")
+	}
+	if len(diff.added) == 0 && len(diff.removed) == 0 && len(diff.updated) == 0 {
+		af.logger.Println("reloaded antfarm config has no changes")
+		return nil
+	}
+	af.logger.Printf("reloading antfarm config: %v ants added, %v ants removed, %v ants updated", len(diff.added), len(diff.removed), len(diff.updated))
+
+	err = af.applyConfigDiff(diff)
+	if saveErr := af.managedSaveState(); saveErr != nil {
+		af.logger.Errorf("can't save antfarm state: %v", saveErr)
+	}
+	if err != nil {
+		return errors.AddContext(err, "can't reload antfarm config")
+	}
+	af.logger.Println("antfarm config was reloaded")
+	return nil
+}
+
+// applyConfigDiff applies the changes of the reloaded antfarm config. Ants
+// are added and updated first and the applied changes are rolled back if one
+// of them fails. Removed ants can't be restarted, so they are stopped last.
+func (af *AntFarm) applyConfigDiff(diff configDiff) error {
+	var undo []func() error
+	rollback := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				af.logger.Errorf("can't roll back antfarm config change: %v", undoErr)
+			}
+		}
+		af.logger.Printf("antfarm config changes were rolled back: %v", err)
+		return err
+	}
+
+	for _, c := range diff.added {
+		if _, err := af.AddAnt(c); err != nil {
+			return rollback(errors.AddContext(err, fmt.Sprintf("can't add ant %v", c.Name)))
+		}
+		name := c.Name
+		undo = append(undo, func() error { return af.RemoveAnt(name) })
+	}
+	for _, u := range diff.updated {
+		u := u
+		a, err := af.GetAntByName(u.new.Name)
+		if err != nil {
+			return rollback(err)
+		}
+		if !reflect.DeepEqual(u.old.Jobs, u.new.Jobs) {
+			if err := a.UpdateJobs(u.new.Jobs); err != nil {
+				return rollback(errors.AddContext(err, fmt.Sprintf("can't update jobs of ant %v", u.new.Name)))
+			}
+			undo = append(undo, func() error { return a.UpdateJobs(u.old.Jobs) })
+		}
+		if u.old.DesiredCurrency != u.new.DesiredCurrency {
+			if err := a.SetDesiredCurrency(u.new.DesiredCurrency); err != nil {
+				return rollback(errors.AddContext(err, fmt.Sprintf("can't update desired currency of ant %v", u.new.Name)))
+			}
+			undo = append(undo, func() error { return a.SetDesiredCurrency(u.old.DesiredCurrency) })
+		}
+		af.managedSetAntConfig(u.new)
+		undo = append(undo, func() error {
+			af.managedSetAntConfig(u.old)
+			return nil
+		})
+	}
+
+	var errs error
+	for _, name := range diff.removed {
+		if err := af.RemoveAnt(name); err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, fmt.Sprintf("can't remove ant %v", name)))
+		}
+	}
+	return errs
+}
+
+// managedSetAntConfig replaces the running config of the ant with the config's
+// name.
+func (af *AntFarm) managedSetAntConfig(config ant.AntConfig) {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	for i, c := range af.config.AntConfigs {
+		if c.Name == config.Name {
+			af.config.AntConfigs[i] = config
+			return
+		}
+	}
+}
+
+// diffConfigs returns the changes between the running and the reloaded
+// antfarm config. An error is returned if the reloaded config is invalid or
+// changes anything which can't be applied live.
+func diffConfigs(old, new AntfarmConfig) (diff configDiff, err error) {
+	var errs []error
+
+	// Only ants can be changed live
+	for _, field := range changedFields(old, new, "AntConfigs") {
+		errs = append(errs, fmt.Errorf("%v can't be changed without restarting the antfarm", field))
+	}
+
+	// Ants without a name can't be identified, so they must stay the same
+	var oldUnnamed, newUnnamed []ant.AntConfig
+	oldNamed := make(map[string]ant.AntConfig)
+	for _, c := range old.AntConfigs {
+		if c.Name == "" {
+			oldUnnamed = append(oldUnnamed, c)
+			continue
+		}
+		oldNamed[c.Name] = c
+	}
+	newNames := make(map[string]struct{})
+	for _, c := range new.AntConfigs {
+		if c.Name == "" {
+			newUnnamed = append(newUnnamed, c)
+			continue
+		}
+		if _, ok := newNames[c.Name]; ok {
+			errs = append(errs, fmt.Errorf("ant name %v is not unique", c.Name))
+			continue
+		}
+		newNames[c.Name] = struct{}{}
+		if err := checkAntJobs(c); err != nil {
+			errs = append(errs, errors.AddContext(err, fmt.Sprintf("ant %v", c.Name)))
+			continue
+		}
+
+		// Start new ants
+		oldConfig, ok := oldNamed[c.Name]
+		if !ok {
+			diff.added = append(diff.added, c)
+			continue
+		}
+
+		// Only jobs and desired currency of running ants can be changed
+		for _, field := range changedFields(oldConfig, c, "Jobs", "DesiredCurrency") {
+			errs = append(errs, fmt.Errorf("ant %v: %v can't be changed without restarting the ant", c.Name, field))
+		}
+		for _, name := range []string{"bigspender", "littlesupplier"} {
+			if countJobs(oldConfig.Jobs, name) != countJobs(c.Jobs, name) {
+				errs = append(errs, fmt.Errorf("ant %v: %v job can't be started or stopped by reloading the config", c.Name, name))
+			}
+		}
+		if !reflect.DeepEqual(oldConfig.Jobs, c.Jobs) || oldConfig.DesiredCurrency != c.DesiredCurrency {
+			diff.updated = append(diff.updated, antConfigUpdate{old: oldConfig, new: c})
+		}
+	}
+	if !reflect.DeepEqual(oldUnnamed, newUnnamed) {
+		errs = append(errs, errors.New("ants without a name can't be added, removed or changed live"))
+	}
+
+	// Stop removed ants
+	for _, c := range old.AntConfigs {
+		if _, ok := newNames[c.Name]; c.Name != "" && !ok {
+			diff.removed = append(diff.removed, c.Name)
+		}
+	}
+	if len(errs) > 0 {
+		return configDiff{}, errors.Compose(append([]error{errConfigRejected}, errs...)...)
+	}
+	return diff, nil
+}
+
+// checkAntJobs checks that the jobs of the ant config can be created and that
+// they don't conflict with the desired currency.
+func checkAntJobs(config ant.AntConfig) error {
+	for _, jc := range config.Jobs {
+		if _, err := ant.NewJobFromConfig(jc); err != nil {
+			return err
+		}
+	}
+	return checkDesiredCurrency(config)
+}
+
+// countJobs returns the number of job configs with the given job name.
+func countJobs(jobs []ant.JobConfig, name string) (n int) {
+	for _, jc := range jobs {
+		if jc.Name == name {
+			n++
+		}
+	}
+	return n
+}
+
+// changedFields returns the names of the fields which differ between the two
+// structs of the same type. Fields of embedded structs are compared
+// separately, the ignored fields are skipped. Func values, e.g. the sync
+// policy's AlertHook, can't be compared and are ignored.
+func changedFields(old, new interface{}, ignored ...string) (fields []string) {
+	skip := make(map[string]struct{})
+	for _, name := range ignored {
+		skip[name] = struct{}{}
+	}
+	var compare func(o, n reflect.Value)
+	compare = func(o, n reflect.Value) {
+		for i := 0; i < o.NumField(); i++ {
+			f := o.Type().Field(i)
+			if _, ok := skip[f.Name]; ok {
+				continue
+			}
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				compare(o.Field(i), n.Field(i))
+				continue
+			}
+			if !equalIgnoringFuncs(o.Field(i), n.Field(i)) {
+				fields = append(fields, f.Name)
+			}
+		}
+	}
+	compare(reflect.ValueOf(old), reflect.ValueOf(new))
+	return fields
+}
+
+// equalIgnoringFuncs returns true if the two values of the same type are
+// deeply equal, func values are considered equal. Only structs containing
+// funcs are compared field by field.
+func equalIgnoringFuncs(o, n reflect.Value) bool {
+	if !containsFunc(o.Type()) {
+		return reflect.DeepEqual(o.Interface(), n.Interface())
+	}
+	switch o.Kind() {
+	case reflect.Func:
+		return true
+	case reflect.Struct:
+		for i := 0; i < o.NumField(); i++ {
+			if !equalIgnoringFuncs(o.Field(i), n.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(o.Interface(), n.Interface())
+}
+
+// containsFunc returns true if the type is a func or a struct containing a
+// func field.
+func containsFunc(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Func:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if containsFunc(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
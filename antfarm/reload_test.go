@@ -0,0 +1,103 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestDiffConfigs verifies diffing the running and a reloaded antfarm config.
+func TestDiffConfigs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	antConfig := func(name string, desiredCurrency uint64, jobs ...string) ant.AntConfig {
+		c := ant.AntConfig{Name: name, DesiredCurrency: desiredCurrency}
+		for _, j := range jobs {
+			c.Jobs = append(c.Jobs, ant.JobConfig{Name: j})
+		}
+		return c
+	}
+	running := AntfarmConfig{
+		ListenAddress: "127.0.0.1:9900",
+		AntConfigs: []ant.AntConfig{
+			antConfig("", 0, "miner"),
+			antConfig("host", 0, "host"),
+			antConfig("renter", 1000, "renter"),
+			antConfig("spender", 0, "bigspender"),
+		},
+	}
+
+	// The same config has no changes
+	diff, err := diffConfigs(running, running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff, configDiff{}) {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+
+	// The sync policy's alert hook set by a library user isn't a change
+	hooked := running
+	hooked.SyncPolicy.AlertHook = func(SyncAlert) {}
+	for _, reloaded := range []AntfarmConfig{hooked, running} {
+		diff, err = diffConfigs(hooked, reloaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(diff, configDiff{}) {
+			t.Fatalf("expected no changes, got %+v", diff)
+		}
+	}
+
+	// Ants are added, removed and updated
+	reloaded := running
+	reloaded.AntConfigs = []ant.AntConfig{
+		antConfig("", 0, "miner"),
+		antConfig("renter", 2000, "renter", "gateway"),
+		antConfig("spender", 0, "bigspender"),
+		antConfig("host2", 0, "host"),
+	}
+	diff, err = diffConfigs(running, reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := configDiff{
+		added:   []ant.AntConfig{reloaded.AntConfigs[3]},
+		removed: []string{"host"},
+		updated: []antConfigUpdate{{old: running.AntConfigs[2], new: reloaded.AntConfigs[1]}},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, diff)
+	}
+
+	// Changes which can't be applied live are rejected
+	params := ant.JobConfig{Name: "generic", Params: json.RawMessage(`{"Unknown": 1}`)}
+	tests := []struct {
+		name   string
+		modify func(c *AntfarmConfig)
+	}{
+		{"listen address", func(c *AntfarmConfig) { c.ListenAddress = "127.0.0.1:9901" }},
+		{"sync policy", func(c *AntfarmConfig) { c.SyncPolicy.MaxGroups = 2 }},
+		{"ant siad config", func(c *AntfarmConfig) { c.AntConfigs[1].SiadPath = "siad" }},
+		{"unnamed ant", func(c *AntfarmConfig) { c.AntConfigs[0].Jobs = nil }},
+		{"duplicate name", func(c *AntfarmConfig) { c.AntConfigs[2].Name = "host" }},
+		{"unknown job", func(c *AntfarmConfig) { c.AntConfigs[1].Jobs = []ant.JobConfig{{Name: "unknown"}} }},
+		{"invalid job params", func(c *AntfarmConfig) { c.AntConfigs[1].Jobs = []ant.JobConfig{params} }},
+		{"miner with currency", func(c *AntfarmConfig) { c.AntConfigs[2].Jobs = []ant.JobConfig{{Name: "miner"}} }},
+		{"bigspender job", func(c *AntfarmConfig) { c.AntConfigs[3].Jobs = nil }},
+	}
+	for _, tt := range tests {
+		c := running
+		c.AntConfigs = append([]ant.AntConfig(nil), running.AntConfigs...)
+		tt.modify(&c)
+		if _, err := diffConfigs(running, c); !errors.Contains(err, errConfigRejected) {
+			t.Errorf("%v: expected rejected config, got %v", tt.name, err)
+		}
+	}
+}
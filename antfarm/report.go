@@ -0,0 +1,278 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// reportFilename defines antfarm JSON report filename. The report is
+	// stored in the antfarm data directory.
+	reportFilename = "antfarm-report.json"
+
+	// reportJUnitFilename defines antfarm JUnit XML report filename. The
+	// report is stored in the antfarm data directory.
+	reportJUnitFilename = "antfarm-report.xml"
+
+	// maxRecordedSyncSplits defines the maximum number of sync splits kept
+	// for the report, older splits are dropped.
+	maxRecordedSyncSplits = 1000
+)
+
+type (
+	// Report summarizes an antfarm run. It is written to the antfarm data
+	// directory when the antfarm is closed.
+	Report struct {
+		Start time.Time
+		End   time.Time
+
+		// Passed is true if no ant recorded a job error, the ants never
+		// split into multiple consensus groups and no sync alert was raised.
+		Passed bool
+
+		// Seed is the antfarm seed, a run can be replayed by setting it in
+		// the antfarm config.
+		Seed string
+
+		Ants       []AntReport
+		SyncSplits []SyncSplit
+		SyncAlerts []SyncAlert
+	}
+
+	// AntReport summarizes the run of an ant.
+	AntReport struct {
+		Name    string
+		DataDir string
+
+		// Passed is true if the ant didn't record any job error.
+		Passed bool
+
+		// Jobs contains how many times each job was started.
+		Jobs map[string]uint64
+
+		Counters   map[ant.MetricName]uint64
+		Histograms map[ant.MetricName]ant.HistogramSnapshot
+		Errors     []ant.JobError
+		Transfers  []ant.TransferRecord
+
+		// ConfirmedSiacoinBalance contains the ant's final balance. If the
+		// balance couldn't be queried, BalanceError is set.
+		ConfirmedSiacoinBalance types.Currency
+		BalanceError            string `json:",omitempty"`
+
+		SiadVersions []string
+	}
+
+	// SyncSplit records ants split into multiple consensus groups detected
+	// by the sync monitor.
+	SyncSplit struct {
+		Time time.Time
+
+		// Groups contains API addresses of the ants in each consensus group.
+		Groups [][]string
+
+		// BlockHeights contains the highest block height of each group.
+		BlockHeights []types.BlockHeight
+	}
+
+	// junitTestSuites is the root element of a JUnit XML report.
+	junitTestSuites struct {
+		XMLName xml.Name         `xml:"testsuites"`
+		Suites  []junitTestSuite `xml:"testsuite"`
+	}
+
+	// junitTestSuite is a JUnit XML test suite.
+	junitTestSuite struct {
+		Name     string          `xml:"name,attr"`
+		Tests    int             `xml:"tests,attr"`
+		Failures int             `xml:"failures,attr"`
+		Time     float64         `xml:"time,attr"`
+		Cases    []junitTestCase `xml:"testcase"`
+	}
+
+	// junitTestCase is a JUnit XML test case.
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      float64       `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure,omitempty"`
+	}
+
+	// junitFailure is a JUnit XML test case failure.
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// newSyncSplit creates a sync split record from the given consensus groups.
+func newSyncSplit(groups [][]*ant.Ant) SyncSplit {
+	split := SyncSplit{Time: time.Now()}
+	for _, group := range groups {
+		var addrs []string
+		for _, a := range group {
+			addrs = append(addrs, a.APIAddr)
+		}
+		split.Groups = append(split.Groups, addrs)
+		split.BlockHeights = append(split.BlockHeights, group[0].BlockHeight())
+	}
+	return split
+}
+
+// newAntReport creates a report of the given ant. The final balance is
+// queried from the ant's siad, so the ant should still be running.
+func newAntReport(a *ant.Ant) AntReport {
+	s := a.Metrics()
+	r := AntReport{
+		Name:         a.Config.Name,
+		DataDir:      a.Config.DataDir,
+		Passed:       len(s.Errors) == 0,
+		Jobs:         s.Jobs,
+		Counters:     s.Counters,
+		Histograms:   s.Histograms,
+		Errors:       s.Errors,
+		Transfers:    s.Transfers,
+		SiadVersions: s.SiadVersions,
+	}
+	wg, err := a.StaticClient.WalletGet()
+	if err != nil {
+		r.BalanceError = err.Error()
+	} else {
+		r.ConfirmedSiacoinBalance = wg.ConfirmedSiacoinBalance
+	}
+	return r
+}
+
+// managedRecordSyncSplit records ants split into the given consensus groups
+// for the report.
+func (af *AntFarm) managedRecordSyncSplit(groups [][]*ant.Ant) {
+	split := newSyncSplit(groups)
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	af.syncSplits = append(af.syncSplits, split)
+	if len(af.syncSplits) > maxRecordedSyncSplits {
+		af.syncSplits = af.syncSplits[len(af.syncSplits)-maxRecordedSyncSplits:]
+	}
+}
+
+// managedReport creates a report of the antfarm run.
+func (af *AntFarm) managedReport() Report {
+	af.mu.Lock()
+	r := Report{
+		Start:      af.staticStart,
+		End:        time.Now(),
+		Seed:       af.staticSeed,
+		SyncSplits: append([]SyncSplit{}, af.syncSplits...),
+		SyncAlerts: append([]SyncAlert{}, af.syncAlerts...),
+	}
+	ants := append([]*ant.Ant{}, af.Ants...)
+	af.mu.Unlock()
+
+	r.Passed = len(r.SyncSplits) == 0 && len(r.SyncAlerts) == 0
+	for _, a := range ants {
+		antReport := newAntReport(a)
+		r.Passed = r.Passed && antReport.Passed
+		r.Ants = append(r.Ants, antReport)
+	}
+	return r
+}
+
+// managedWriteReport writes the antfarm report to the antfarm data
+// directory.
+func (af *AntFarm) managedWriteReport() error {
+	r := af.managedReport()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.AddContext(err, "can't encode antfarm report")
+	}
+	err = ioutil.WriteFile(filepath.Join(af.dataDir, reportFilename), data, 0600)
+	if err != nil {
+		return errors.AddContext(err, "can't write antfarm report")
+	}
+	if !af.staticReportJUnit {
+		return nil
+	}
+	data, err = r.junitXML()
+	if err != nil {
+		return errors.AddContext(err, "can't encode antfarm JUnit report")
+	}
+	err = ioutil.WriteFile(filepath.Join(af.dataDir, reportJUnitFilename), data, 0600)
+	if err != nil {
+		return errors.AddContext(err, "can't write antfarm JUnit report")
+	}
+	return nil
+}
+
+// junitXML returns the report in JUnit XML format. Each ant is reported as a
+// test case, ants' consensus sync is reported as an additional test case.
+func (r Report) junitXML() ([]byte, error) {
+	duration := r.End.Sub(r.Start).Seconds()
+	suite := junitTestSuite{
+		Name: "antfarm",
+		Time: duration,
+	}
+	for _, a := range r.Ants {
+		name := a.Name
+		if name == "" {
+			name = a.DataDir
+		}
+		tc := junitTestCase{
+			Name:      name,
+			ClassName: "antfarm.ants",
+			Time:      duration,
+		}
+		if !a.Passed {
+			var lines []string
+			for _, e := range a.Errors {
+				lines = append(lines, fmt.Sprintf("%v %v: %v", e.Time.Format(time.RFC3339), e.Metric, e.Error))
+			}
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("ant recorded %v job errors", len(a.Errors)),
+				Text:    strings.Join(lines, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	syncCase := junitTestCase{
+		Name:      "consensus-sync",
+		ClassName: "antfarm",
+		Time:      duration,
+	}
+	if len(r.SyncSplits) > 0 || len(r.SyncAlerts) > 0 {
+		var lines []string
+		for _, alert := range r.SyncAlerts {
+			lines = append(lines, fmt.Sprintf("%v: alert: %v", alert.Time.Format(time.RFC3339), alert.Reason))
+		}
+		for _, split := range r.SyncSplits {
+			lines = append(lines, fmt.Sprintf("%v: groups %v at heights %v", split.Time.Format(time.RFC3339), split.Groups, split.BlockHeights))
+		}
+		syncCase.Failure = &junitFailure{
+			Message: fmt.Sprintf("ants split into multiple consensus groups %v times", len(r.SyncSplits)),
+			Text:    strings.Join(lines, "\n"),
+		}
+	}
+	suite.Cases = append(suite.Cases, syncCase)
+
+	suite.Tests = len(suite.Cases)
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,54 @@
+package antfarm
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+)
+
+// TestReportJUnitXML verifies that the antfarm report is converted to JUnit
+// XML with a test case per ant and a consensus sync test case.
+func TestReportJUnitXML(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	start := time.Now()
+	r := Report{
+		Start: start,
+		End:   start.Add(time.Minute),
+		Ants: []AntReport{
+			{Name: "renter", Passed: false, Errors: []ant.JobError{{Time: start, Metric: ant.MetricUploadsFailed, Error: "upload failed"}}},
+			{DataDir: "antfarm-data/host", Passed: true},
+		},
+		SyncSplits: []SyncSplit{{Time: start, Groups: [][]string{{"a"}, {"b"}}}},
+	}
+	data, err := r.junitXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("expected 1 test suite, got %v", len(suites.Suites))
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 2 || suite.Time != 60 {
+		t.Fatalf("unexpected test suite: %+v", suite)
+	}
+	if suite.Cases[0].Name != "renter" || suite.Cases[0].Failure == nil {
+		t.Fatalf("expected failed renter test case, got %+v", suite.Cases[0])
+	}
+	if suite.Cases[1].Name != "antfarm-data/host" || suite.Cases[1].Failure != nil {
+		t.Fatalf("expected passed host test case, got %+v", suite.Cases[1])
+	}
+	if suite.Cases[2].Name != "consensus-sync" || suite.Cases[2].Failure == nil {
+		t.Fatalf("expected failed consensus sync test case, got %+v", suite.Cases[2])
+	}
+}
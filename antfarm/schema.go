@@ -0,0 +1,111 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"go.sia.tech/sia-antfarm/ant"
+)
+
+const (
+	// schemaDraft defines the JSON Schema draft of the config schema.
+	schemaDraft = "http://json-schema.org/draft-07/schema#"
+)
+
+var (
+	// jobConfigType and rawMessageType are types with a custom JSON encoding
+	// which can't be derived from their fields.
+	jobConfigType  = reflect.TypeOf(ant.JobConfig{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// ConfigSchema returns a JSON Schema of the antfarm config generated from
+// AntfarmConfig and ant.AntConfig. Job names are limited to the registered
+// jobs. Properties use the canonical field names, although field names in
+// configs are matched case-insensitively.
+func ConfigSchema() ([]byte, error) {
+	schema := schemaOf(reflect.TypeOf(AntfarmConfig{}))
+	schema["$schema"] = schemaDraft
+	schema["title"] = "Sia Antfarm config"
+	return json.MarshalIndent(schema, "", "\t")
+}
+
+// schemaOf returns the JSON Schema of values of the given type.
+func schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case jobConfigType:
+		return jobConfigSchema()
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		addStructProperties(t, properties)
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		// Values of other types can't be configured
+		return map[string]interface{}{}
+	}
+}
+
+// addStructProperties adds the JSON encoded fields of the given struct type
+// to the properties. Fields of embedded structs are promoted like in JSON.
+func addStructProperties(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addStructProperties(f.Type, properties)
+			continue
+		}
+		if f.PkgPath != "" || f.Type.Kind() == reflect.Func || f.Type.Kind() == reflect.Chan {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = schemaOf(f.Type)
+	}
+}
+
+// jobConfigSchema returns the JSON Schema of ant.JobConfig, which is either a
+// job name or an object with the job name and parameters.
+func jobConfigSchema() map[string]interface{} {
+	names := map[string]interface{}{"type": "string", "enum": ant.RegisteredJobs()}
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			names,
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"Name":   names,
+					"Params": map[string]interface{}{"type": "object"},
+				},
+				"required": []string{"Name"},
+			},
+		},
+	}
+}
@@ -0,0 +1,28 @@
+package antfarm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// TestConfigSchema verifies that the published config schema is up to date.
+// Run `sia-antfarm schema > antfarm-config.schema.json` to update it.
+func TestConfigSchema(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	schema, err := ConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := ioutil.ReadFile("../antfarm-config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(published), schema) {
+		t.Fatal("antfarm-config.schema.json is out of date, regenerate it with `sia-antfarm schema`")
+	}
+}
@@ -0,0 +1,272 @@
+package antfarm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/persist"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// snapshotStateFilename defines the name of the antfarm state in a
+	// snapshot archive.
+	snapshotStateFilename = "snapshot.json"
+
+	// snapshotAntsDir defines the directory of the ants' data directories in
+	// a snapshot archive and in the data directory of a restored antfarm.
+	snapshotAntsDir = "ants"
+)
+
+// Snapshot archives the ants' data directories, configs and wallet seeds to a
+// gzipped tar archive at the given path, so that new antfarms can be started
+// from the archived chain state by NewFromSnapshot. The ants are stopped while
+// their data directories are archived and started again afterwards.
+func (af *AntFarm) Snapshot(path string) (err error) {
+	af.mu.Lock()
+	state := af.currentState()
+	ants := append([]*ant.Ant{}, af.Ants...)
+	af.mu.Unlock()
+
+	// Stop the ants, so that siad flushes its data to the data directories
+	af.logger.Printf("stopping %v ants to snapshot antfarm to %v", len(ants), path)
+	for _, a := range ants {
+		if err := a.Close(); err != nil {
+			af.logger.Errorf("%v: can't close ant: %v", a.Config.DataDir, err)
+		}
+	}
+	defer func() {
+		for _, a := range ants {
+			if startErr := a.StartSiad(a.Config.SiadPath); startErr != nil {
+				err = errors.Compose(err, errors.AddContext(startErr, fmt.Sprintf("can't restart ant %v", a.Config.DataDir)))
+			}
+		}
+	}()
+
+	// Archive the data directories under relative paths and without
+	// addresses, so that the ants can be restored anywhere on fresh ports
+	var dataDirs []string
+	for i, c := range state.AntConfigs {
+		dataDirs = append(dataDirs, c.DataDir)
+		c.DataDir = filepath.Join(snapshotAntsDir, strconv.Itoa(i))
+		state.AntConfigs[i] = clearAddrs(c)
+	}
+	tmpPath := path + "_temp"
+	if err := writeSnapshot(tmpPath, state, dataDirs); err != nil {
+		return errors.AddContext(err, "can't write snapshot")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.AddContext(err, "can't replace snapshot")
+	}
+	af.logger.Printf("antfarm was snapshotted to %v", path)
+	return nil
+}
+
+// NewFromSnapshot creates a new antfarm from the ants archived by Snapshot.
+// The ants' data directories are extracted into the antfarm data directory
+// and the ants are started with their blockchain, wallets and contracts on
+// fresh ports. Host net addresses are set to the fresh host addresses when the
+// ants are started and hosts are re-announced by their host jobs. The ants
+// are defined by the snapshot, so the config can't contain AntConfigs or
+// Groups.
+func NewFromSnapshot(logger *persist.Logger, config AntfarmConfig, path string) (*AntFarm, error) {
+	if len(config.AntConfigs) > 0 || len(config.Groups) > 0 {
+		return nil, errors.New("ants of an antfarm created from a snapshot are defined by the snapshot")
+	}
+
+	// Extract the snapshot into a clean antfarm data directory
+	dataDir := antfarmDataDir(config)
+	if err := os.RemoveAll(dataDir); err != nil {
+		return nil, errors.AddContext(err, "can't remove antfarm data directory")
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, errors.AddContext(err, "can't create antfarm data directory")
+	}
+	state, err := extractSnapshot(path, dataDir)
+	if err != nil {
+		return nil, errors.AddContext(err, "can't extract snapshot")
+	}
+	for i, c := range state.AntConfigs {
+		state.AntConfigs[i].DataDir = filepath.Join(dataDir, c.DataDir)
+	}
+	logger.Printf("restoring antfarm with %v ants from snapshot %v", len(state.AntConfigs), path)
+
+	// The extracted ants are resumed from the antfarm state
+	if err := saveState(dataDir, state); err != nil {
+		return nil, errors.AddContext(err, "can't save antfarm state")
+	}
+	config.Resume = true
+	return New(logger, config)
+}
+
+// antfarmDataDir returns the data directory of the antfarm with the given
+// config.
+func antfarmDataDir(config AntfarmConfig) string {
+	if config.DataDir != "" {
+		return config.DataDir
+	}
+	return "./antfarm-data"
+}
+
+// clearAddrs returns the ant config without addresses, so that fresh ports
+// are assigned when the ant is started.
+func clearAddrs(c ant.AntConfig) ant.AntConfig {
+	c.APIAddr = ""
+	c.RPCAddr = ""
+	c.HostAddr = ""
+	c.SiaMuxAddr = ""
+	c.SiaMuxWsAddr = ""
+	c.InternalRPCAddr = ""
+	c.InternalHostAddr = ""
+	c.InternalSiaMuxAddr = ""
+	return c
+}
+
+// writeSnapshot writes a gzipped tar archive with the antfarm state and the
+// data directories of its ants. The data directory of the i-th ant config is
+// dataDirs[i], it is archived under the ant config's DataDir.
+func writeSnapshot(path string, state farmState, dataDirs []string) (err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.AddContext(err, "can't create snapshot file")
+	}
+	// Host storage folders consist mostly of zeros, favor speed over size
+	gw, err := gzip.NewWriterLevel(f, gzip.BestSpeed)
+	if err != nil {
+		return errors.Compose(err, f.Close())
+	}
+	tw := tar.NewWriter(gw)
+	defer func() {
+		err = errors.Compose(err, tw.Close(), gw.Close(), f.Close())
+	}()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.AddContext(err, "can't encode antfarm state")
+	}
+	err = tw.WriteHeader(&tar.Header{Name: snapshotStateFilename, Mode: 0600, Size: int64(len(data)), Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	for i, c := range state.AntConfigs {
+		if err := archiveDir(tw, dataDirs[i], c.DataDir); err != nil {
+			return errors.AddContext(err, fmt.Sprintf("can't archive data directory %v", dataDirs[i]))
+		}
+	}
+	return nil
+}
+
+// archiveDir writes the directories and regular files of dir to the tar
+// archive under the given archive directory name.
+func archiveDir(tw *tar.Writer, dir, name string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		return errors.Compose(err, f.Close())
+	})
+}
+
+// extractSnapshot extracts the snapshot archive at the given path into dir and
+// returns the archived antfarm state.
+func extractSnapshot(path, dir string) (state farmState, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return farmState{}, errors.AddContext(err, "can't open snapshot file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return farmState{}, errors.AddContext(err, "can't decompress snapshot")
+	}
+	tr := tar.NewReader(gr)
+
+	var stateFound bool
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return farmState{}, errors.AddContext(err, "can't read snapshot")
+		}
+		if header.Name == snapshotStateFilename {
+			if err := json.NewDecoder(tr).Decode(&state); err != nil {
+				return farmState{}, errors.AddContext(err, "can't decode antfarm state")
+			}
+			stateFound = true
+			continue
+		}
+
+		// Only the ants' data directories are extracted
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(name, snapshotAntsDir+string(filepath.Separator)) {
+			return farmState{}, fmt.Errorf("unexpected snapshot file %v", header.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0700)
+		case tar.TypeReg:
+			err = extractFile(tr, target, os.FileMode(header.Mode).Perm())
+		}
+		if err != nil {
+			return farmState{}, errors.AddContext(err, fmt.Sprintf("can't extract %v", header.Name))
+		}
+	}
+	if !stateFound {
+		return farmState{}, errors.New("snapshot doesn't contain antfarm state")
+	}
+	return state, nil
+}
+
+// extractFile writes the content of the reader to a new file at the given
+// path.
+func extractFile(r io.Reader, path string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return errors.Compose(err, f.Close())
+}
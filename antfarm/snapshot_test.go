@@ -0,0 +1,130 @@
+package antfarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/test"
+)
+
+// TestSnapshotArchive verifies that the antfarm state and the ants' data
+// directories written to a snapshot archive can be extracted.
+func TestSnapshotArchive(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+	antDir := filepath.Join(dataDir, "ant")
+	if err := os.MkdirAll(filepath.Join(antDir, "wallet"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("wallet data")
+	if err := ioutil.WriteFile(filepath.Join(antDir, "wallet", "wallet.db"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := farmState{
+		AntConfigs: []ant.AntConfig{
+			{
+				SiadConfig:        ant.SiadConfig{DataDir: filepath.Join(snapshotAntsDir, "0")},
+				Name:              ant.NameHost(0),
+				Jobs:              []ant.JobConfig{{Name: "host"}},
+				InitialWalletSeed: test.WalletSeed1,
+			},
+		},
+	}
+	path := filepath.Join(dataDir, "snapshot.tar.gz")
+	if err := writeSnapshot(path, state, []string{antDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	restoreDir := filepath.Join(dataDir, "restored")
+	extracted, err := extractSnapshot(path, restoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state, extracted) {
+		t.Fatalf("extracted state doesn't equal archived state\narchived: %+v\nextracted: %+v", state, extracted)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(restoreDir, snapshotAntsDir, "0", "wallet", "wallet.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(content) {
+		t.Fatalf("expected extracted file content %q, got %q", content, data)
+	}
+}
+
+// TestSnapshotRestore verifies that an antfarm restored from a snapshot runs
+// the snapshotted ants with their wallets on fresh ports.
+func TestSnapshotRestore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+	logger, err := NewAntfarmLogger(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	config, err := NewAntfarmConfig(dataDir, true, 0, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshot and close the first antfarm
+	farm, err := New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiAddr := farm.Ants[0].APIAddr
+	seed := farm.Ants[0].Jr.StaticWalletSeed
+	path := filepath.Join(dataDir, "snapshot.tar.gz")
+	if err := farm.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := farm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the antfarm from the snapshot
+	addr, err := ant.GetAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreConfig := AntfarmConfig{
+		ListenAddress: "127.0.0.1" + addr,
+		DataDir:       filepath.Join(dataDir, "restored-antfarm-data"),
+	}
+	farm, err = NewFromSnapshot(logger, restoreConfig, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := farm.Close(); err != nil {
+			logger.Errorf("can't close antfarm: %v", err)
+		}
+	}()
+	if len(farm.Ants) != 1 {
+		t.Fatalf("expected 1 restored ant, got %v", len(farm.Ants))
+	}
+	if farm.Ants[0].APIAddr == apiAddr {
+		t.Fatal("expected restored ant to use a fresh API address")
+	}
+	if farm.Ants[0].Jr.StaticWalletSeed != seed {
+		t.Fatal("expected restored ant to use the same wallet seed")
+	}
+}
@@ -0,0 +1,96 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// stateFilename defines antfarm state filename. The state file is stored
+	// in the antfarm data directory.
+	stateFilename = "antfarm-state.json"
+)
+
+// farmState contains the antfarm state which is needed to resume the antfarm
+// after a restart.
+type farmState struct {
+	// AntConfigs contains resolved configs of all ants, i.e. configs with
+	// assigned ports, data directories, siad paths and wallet seeds.
+	AntConfigs []ant.AntConfig
+
+	// RunningConfigs contains the ant configs of the running antfarm config
+	// as given by the user, i.e. unresolved configs. They are the baseline
+	// of config reloads after the antfarm is resumed.
+	RunningConfigs []ant.AntConfig `json:",omitempty"`
+}
+
+// loadState loads the antfarm state from the given antfarm data directory. If
+// there is no state file, it returns false.
+func loadState(dataDir string) (state farmState, exists bool, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, stateFilename))
+	if os.IsNotExist(err) {
+		return farmState{}, false, nil
+	}
+	if err != nil {
+		return farmState{}, false, errors.AddContext(err, "can't read antfarm state file")
+	}
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return farmState{}, false, errors.AddContext(err, "can't decode antfarm state file")
+	}
+	return state, true, nil
+}
+
+// saveState saves the given antfarm state to the given antfarm data
+// directory. The state file is replaced atomically, so that an interrupted
+// save doesn't corrupt the previous state.
+func saveState(dataDir string, state farmState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.AddContext(err, "can't encode antfarm state")
+	}
+	path := filepath.Join(dataDir, stateFilename)
+	tmpPath := path + "_temp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return errors.AddContext(err, "can't write antfarm state file")
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return errors.AddContext(err, "can't replace antfarm state file")
+	}
+	return nil
+}
+
+// currentState returns the current state of the antfarm.
+func (af *AntFarm) currentState() farmState {
+	var state farmState
+	for _, a := range af.Ants {
+		config := a.Config
+		config.Jobs = a.JobConfigs()
+		if seed := a.WalletSeed(); seed != "" {
+			config.InitialWalletSeed = seed
+		}
+		state.AntConfigs = append(state.AntConfigs, config)
+	}
+	state.RunningConfigs = append([]ant.AntConfig(nil), af.config.AntConfigs...)
+	return state
+}
+
+// managedSaveState saves the current antfarm state to the antfarm data
+// directory.
+func (af *AntFarm) managedSaveState() error {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	return af.saveState()
+}
+
+// saveState saves the current antfarm state to the antfarm data directory.
+func (af *AntFarm) saveState() error {
+	return saveState(af.dataDir, af.currentState())
+}
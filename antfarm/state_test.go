@@ -0,0 +1,58 @@
+package antfarm
+
+import (
+	"reflect"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/test"
+)
+
+// TestSaveLoadState verifies that the saved antfarm state can be loaded back.
+func TestSaveLoadState(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+
+	// There is no state yet
+	_, exists, err := loadState(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected no antfarm state")
+	}
+
+	// Save and load state
+	state := farmState{
+		AntConfigs: []ant.AntConfig{
+			{
+				SiadConfig: ant.SiadConfig{
+					APIAddr:  "127.0.0.1:9980",
+					DataDir:  "ant_0",
+					SiadPath: test.TestSiadFilename,
+				},
+				Jobs:              []ant.JobConfig{{Name: "host"}},
+				Name:              ant.NameHost(0),
+				InitialWalletSeed: test.WalletSeed1,
+			},
+		},
+	}
+	err = saveState(dataDir, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, exists, err := loadState(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("expected antfarm state to exist")
+	}
+	if !reflect.DeepEqual(state, loaded) {
+		t.Fatalf("loaded state doesn't equal saved state\nsaved: %+v\nloaded: %+v", state, loaded)
+	}
+}
@@ -0,0 +1,183 @@
+package antfarm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// defaultReorgDepth defines the default number of recent blocks compared
+	// when grouping ants into consensus groups.
+	defaultReorgDepth = 8
+
+	// syncAlertWebhookTimeout defines a timeout of the sync alert webhook
+	// request.
+	syncAlertWebhookTimeout = time.Second * 10
+)
+
+type (
+	// SyncPolicy defines when the sync monitor raises an alert about ants
+	// split into multiple consensus groups. Zero values disable the
+	// corresponding check.
+	SyncPolicy struct {
+		// MaxSplitDurationSeconds defines how long the ants can be split into
+		// multiple consensus groups before an alert is raised.
+		MaxSplitDurationSeconds uint64
+
+		// MaxGroups defines the maximum tolerated number of consensus
+		// groups.
+		MaxGroups int
+
+		// ReorgDepth defines how many recent blocks are compared when
+		// grouping ants into consensus groups, defaults to 8.
+		ReorgDepth uint64
+
+		// AlertWebhookURL defines an URL the alert is POSTed to as JSON.
+		AlertWebhookURL string
+
+		// ExitOnAlert defines whether sia-antfarm should exit with a
+		// non-zero exit code on an alert.
+		ExitOnAlert bool
+
+		// AlertHook is called on an alert when the antfarm is used as a
+		// library.
+		AlertHook func(SyncAlert) `json:"-"`
+	}
+
+	// SyncAlert describes a breach of the sync policy.
+	SyncAlert struct {
+		Time   time.Time
+		Reason string
+
+		// SplitStart is the time the ants were first seen split.
+		SplitStart time.Time
+
+		// Groups contains API addresses of the ants in each consensus group.
+		Groups [][]string
+
+		// BlockHeights contains the highest block height of each group.
+		BlockHeights []types.BlockHeight
+	}
+
+	// syncMonitorState tracks the current split of the ants between the
+	// sync monitor checks.
+	syncMonitorState struct {
+		splitStart time.Time
+		alerted    bool
+	}
+)
+
+// reorgDepth returns the number of recent blocks compared when grouping ants
+// into consensus groups.
+func (sp SyncPolicy) reorgDepth() types.BlockHeight {
+	if sp.ReorgDepth == 0 {
+		return defaultReorgDepth
+	}
+	return types.BlockHeight(sp.ReorgDepth)
+}
+
+// breach returns a reason of the sync policy breach or an empty string if the
+// policy is not breached by the given number of groups split since
+// splitStart.
+func (sp SyncPolicy) breach(groups int, splitStart, now time.Time) string {
+	if groups <= 1 {
+		return ""
+	}
+	if sp.MaxGroups > 0 && groups > sp.MaxGroups {
+		return fmt.Sprintf("ants split into %v consensus groups, max %v groups are tolerated", groups, sp.MaxGroups)
+	}
+	maxDuration := time.Duration(sp.MaxSplitDurationSeconds) * time.Second
+	if maxDuration > 0 && now.Sub(splitStart) > maxDuration {
+		return fmt.Sprintf("ants split into multiple consensus groups for %v, max %v is tolerated", now.Sub(splitStart).Round(time.Second), maxDuration)
+	}
+	return ""
+}
+
+// update updates the monitor state by the given consensus groups and returns
+// a sync alert if the sync policy is breached. The alert is raised once per
+// split, the monitor state is reset when the ants are synced again.
+func (s *syncMonitorState) update(sp SyncPolicy, groups [][]*ant.Ant, now time.Time) *SyncAlert {
+	if len(groups) <= 1 {
+		*s = syncMonitorState{}
+		return nil
+	}
+	if s.splitStart.IsZero() {
+		s.splitStart = now
+	}
+	if s.alerted {
+		return nil
+	}
+	reason := sp.breach(len(groups), s.splitStart, now)
+	if reason == "" {
+		return nil
+	}
+	s.alerted = true
+	split := newSyncSplit(groups)
+	return &SyncAlert{
+		Time:         now,
+		Reason:       reason,
+		SplitStart:   s.splitStart,
+		Groups:       split.Groups,
+		BlockHeights: split.BlockHeights,
+	}
+}
+
+// SyncAlerts returns a channel which receives sync alerts raised by the sync
+// monitor. Alerts are dropped if the channel is not read.
+func (af *AntFarm) SyncAlerts() <-chan SyncAlert {
+	return af.staticSyncAlerts
+}
+
+// managedRaiseSyncAlert records the sync alert for the report, calls the
+// alert hook, posts the alert to the webhook and sends it to the sync alerts
+// channel.
+func (af *AntFarm) managedRaiseSyncAlert(alert SyncAlert) {
+	af.logger.Errorf("sync alert: %v", alert.Reason)
+
+	af.mu.Lock()
+	af.syncAlerts = append(af.syncAlerts, alert)
+	af.mu.Unlock()
+
+	if af.staticSyncPolicy.AlertHook != nil {
+		af.staticSyncPolicy.AlertHook(alert)
+	}
+	if af.staticSyncPolicy.AlertWebhookURL != "" {
+		if err := postSyncAlert(af.staticSyncPolicy.AlertWebhookURL, alert); err != nil {
+			af.logger.Errorf("can't post sync alert to webhook: %v", err)
+		}
+	}
+
+	select {
+	case af.staticSyncAlerts <- alert:
+	default:
+	}
+}
+
+// postSyncAlert posts the sync alert as JSON to the given webhook URL.
+func postSyncAlert(url string, alert SyncAlert) (err error) {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return errors.AddContext(err, "can't encode sync alert")
+	}
+	c := http.Client{Timeout: syncAlertWebhookTimeout}
+	resp, err := c.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.AddContext(err, "can't post sync alert")
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Compose(err, errors.AddContext(closeErr, "can't close response body"))
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %v", resp.Status)
+	}
+	return nil
+}
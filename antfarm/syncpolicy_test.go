@@ -0,0 +1,127 @@
+package antfarm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/siad/types"
+)
+
+// TestSyncPolicyBreach verifies the sync policy thresholds.
+func TestSyncPolicyBreach(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name       string
+		policy     SyncPolicy
+		groups     int
+		splitStart time.Time
+		breach     bool
+	}{
+		{"synced", SyncPolicy{MaxGroups: 1, MaxSplitDurationSeconds: 1}, 1, now.Add(-time.Hour), false},
+		{"no thresholds", SyncPolicy{}, 5, now.Add(-time.Hour), false},
+		{"groups tolerated", SyncPolicy{MaxGroups: 2}, 2, now, false},
+		{"too many groups", SyncPolicy{MaxGroups: 2}, 3, now, true},
+		{"split tolerated", SyncPolicy{MaxSplitDurationSeconds: 60}, 2, now.Add(-time.Second * 30), false},
+		{"split too long", SyncPolicy{MaxSplitDurationSeconds: 60}, 2, now.Add(-time.Second * 90), true},
+	}
+	for _, tt := range tests {
+		reason := tt.policy.breach(tt.groups, tt.splitStart, now)
+		if (reason != "") != tt.breach {
+			t.Errorf("%v: expected breach %v, got reason %q", tt.name, tt.breach, reason)
+		}
+	}
+
+	// Check default reorg depth
+	if d := (SyncPolicy{}).reorgDepth(); d != defaultReorgDepth {
+		t.Fatalf("expected default reorg depth %v, got %v", defaultReorgDepth, d)
+	}
+	if d := (SyncPolicy{ReorgDepth: 20}).reorgDepth(); d != 20 {
+		t.Fatalf("expected reorg depth 20, got %v", d)
+	}
+}
+
+// TestSyncMonitorState verifies that an alert is raised once per split.
+func TestSyncMonitorState(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	newAnt := func(addr string) *ant.Ant {
+		return &ant.Ant{APIAddr: addr, SeenBlocks: map[types.BlockHeight]types.BlockID{10: {}}}
+	}
+	synced := [][]*ant.Ant{{newAnt("a"), newAnt("b")}}
+	split := [][]*ant.Ant{{newAnt("a")}, {newAnt("b")}}
+	policy := SyncPolicy{MaxSplitDurationSeconds: 60}
+
+	var s syncMonitorState
+	start := time.Now()
+	if alert := s.update(policy, synced, start); alert != nil {
+		t.Fatal("unexpected alert for synced ants")
+	}
+	if alert := s.update(policy, split, start); alert != nil {
+		t.Fatal("unexpected alert at split start")
+	}
+	alert := s.update(policy, split, start.Add(time.Minute*2))
+	if alert == nil {
+		t.Fatal("expected alert after max split duration")
+	}
+	if !alert.SplitStart.Equal(start) || len(alert.Groups) != 2 || alert.BlockHeights[0] != 10 {
+		t.Fatalf("unexpected alert: %+v", alert)
+	}
+	if alert := s.update(policy, split, start.Add(time.Minute*3)); alert != nil {
+		t.Fatal("expected only one alert per split")
+	}
+
+	// A new split after sync raises a new alert
+	s.update(policy, synced, start.Add(time.Minute*4))
+	s.update(policy, split, start.Add(time.Minute*5))
+	if alert := s.update(policy, split, start.Add(time.Minute*7)); alert == nil {
+		t.Fatal("expected alert for a new split")
+	}
+}
+
+// TestPostSyncAlert verifies that the sync alert is posted to the webhook.
+func TestPostSyncAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	received := make(chan SyncAlert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert SyncAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- alert
+	}))
+	defer srv.Close()
+
+	err := postSyncAlert(srv.URL, SyncAlert{Reason: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert := <-received; alert.Reason != "test" {
+		t.Fatalf("unexpected alert reason: %v", alert.Reason)
+	}
+
+	// Error status is reported
+	errSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer errSrv.Close()
+	if err := postSyncAlert(errSrv.URL, SyncAlert{}); err == nil {
+		t.Fatal("expected error for webhook error status")
+	}
+}
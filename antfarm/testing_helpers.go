@@ -36,7 +36,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                  antDirs[0],
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs: []string{"gateway", "miner"},
+				Jobs: []ant.JobConfig{{Name: "gateway"}, {Name: "miner"}},
 			},
 			{
 				SiadConfig: ant.SiadConfig{
@@ -44,7 +44,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                  antDirs[1],
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs:            []string{"host"},
+				Jobs:            []ant.JobConfig{{Name: "host"}},
 				DesiredCurrency: 100000,
 			},
 			{
@@ -53,7 +53,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                  antDirs[2],
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs:            []string{"host"},
+				Jobs:            []ant.JobConfig{{Name: "host"}},
 				DesiredCurrency: 100000,
 			},
 			{
@@ -62,7 +62,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                  antDirs[3],
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs:            []string{"host"},
+				Jobs:            []ant.JobConfig{{Name: "host"}},
 				DesiredCurrency: 100000,
 			},
 			{
@@ -71,7 +71,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                  antDirs[4],
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs:            []string{"host"},
+				Jobs:            []ant.JobConfig{{Name: "host"}},
 				DesiredCurrency: 100000,
 			},
 			{
@@ -80,7 +80,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                  antDirs[5],
 					SiadPath:                 test.TestSiadFilename,
 				},
-				Jobs:            []string{"host"},
+				Jobs:            []ant.JobConfig{{Name: "host"}},
 				DesiredCurrency: 100000,
 			},
 			{
@@ -90,7 +90,7 @@ func NewDefaultRenterAntfarmTestingConfig(dataDir string, allowLocalIPs bool) (A
 					DataDir:                       antDirs[6],
 					SiadPath:                      test.TestSiadFilename,
 				},
-				Jobs:            []string{"renter"},
+				Jobs:            []ant.JobConfig{{Name: "renter"}},
 				DesiredCurrency: 100000,
 				Name:            test.RenterAntName,
 			},
@@ -116,71 +116,33 @@ func NewAntfarmConfig(dataDir string, allowLocalIPs bool, miners int, hosts int,
 		return AntfarmConfig{}, errors.AddContext(err, "can't create ant data directories")
 	}
 
-	// Prepare ant configs
-	antConfigs := []ant.AntConfig{}
-	var doneAntConfigs int
-
-	// Add miners
-	for i := 0; i < miners; i++ {
-		ac := ant.AntConfig{
-			SiadConfig: ant.SiadConfig{
-				AllowHostLocalNetAddress: allowLocalIPs,
-				DataDir:                  antDirs[doneAntConfigs],
-				SiadPath:                 test.TestSiadFilename,
-			},
-			Jobs: []string{"gateway", "miner"},
-			Name: ant.NameMiner(i),
-		}
-		antConfigs = append(antConfigs, ac)
-		doneAntConfigs++
+	// Prepare ant configs from ant groups
+	template := ant.AntConfig{
+		SiadConfig: ant.SiadConfig{
+			AllowHostLocalNetAddress: allowLocalIPs,
+			SiadPath:                 test.TestSiadFilename,
+		},
 	}
-
-	// Add hosts
-	for i := 0; i < hosts; i++ {
-		ac := ant.AntConfig{
-			SiadConfig: ant.SiadConfig{
-				AllowHostLocalNetAddress: allowLocalIPs,
-				DataDir:                  antDirs[doneAntConfigs],
-				SiadPath:                 test.TestSiadFilename,
-			},
-			Jobs:            []string{"host"},
-			Name:            ant.NameHost(i),
-			DesiredCurrency: 100000,
+	renterTemplate := template
+	renterTemplate.RenterDisableIPViolationCheck = true
+	var groups []AntGroup
+	for _, g := range []AntGroup{
+		{Role: ant.TypeMiner, Count: miners, AntConfig: template},
+		{Role: ant.TypeHost, Count: hosts, AntConfig: template},
+		{Role: ant.TypeRenter, Count: renters, AntConfig: renterTemplate},
+		{Role: ant.TypeGeneric, Count: generic, AntConfig: template},
+	} {
+		if g.Count > 0 {
+			groups = append(groups, g)
 		}
-		antConfigs = append(antConfigs, ac)
-		doneAntConfigs++
 	}
-
-	// Add renters
-	for i := 0; i < renters; i++ {
-		ac := ant.AntConfig{
-			SiadConfig: ant.SiadConfig{
-				AllowHostLocalNetAddress:      allowLocalIPs,
-				RenterDisableIPViolationCheck: true,
-				DataDir:                       antDirs[doneAntConfigs],
-				SiadPath:                      test.TestSiadFilename,
-			},
-			Jobs:            []string{"renter"},
-			Name:            ant.NameRenter(i),
-			DesiredCurrency: 100000,
-		}
-		antConfigs = append(antConfigs, ac)
-		doneAntConfigs++
+	expanded, err := AntfarmConfig{Groups: groups}.Expand()
+	if err != nil {
+		return AntfarmConfig{}, errors.AddContext(err, "can't expand ant groups")
 	}
-
-	// Add generic ants
-	for i := 0; i < generic; i++ {
-		ac := ant.AntConfig{
-			SiadConfig: ant.SiadConfig{
-				AllowHostLocalNetAddress: allowLocalIPs,
-				DataDir:                  antDirs[doneAntConfigs],
-				SiadPath:                 test.TestSiadFilename,
-			},
-			Jobs: []string{"generic"},
-			Name: ant.NameGeneric(i),
-		}
-		antConfigs = append(antConfigs, ac)
-		doneAntConfigs++
+	antConfigs := expanded.AntConfigs
+	for i := range antConfigs {
+		antConfigs[i].DataDir = antDirs[i]
 	}
 
 	config := AntfarmConfig{
@@ -0,0 +1,121 @@
+package antfarm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.sia.tech/sia-antfarm/ant"
+)
+
+// AntGroup defines Count ants of the same Role in a compact antfarm config.
+// The embedded AntConfig is the template of the group's ants, e.g.
+// {"Role": "host", "Count": 20, "SiadPath": "siad-dev"}. The ants are named
+// by their role and index, e.g. Host-0 to Host-19, indices continue across
+// groups with the same role. If the template has no Jobs, the role's default
+// jobs and desired currency are used. If the template has a DataDir, each ant
+// uses a subdirectory named by the ant.
+type AntGroup struct {
+	Role  ant.Type
+	Count int
+
+	ant.AntConfig
+}
+
+// antRole defines how ants of a role are named and their default config.
+type antRole struct {
+	name     func(i int) string
+	defaults ant.AntConfig
+}
+
+// antRoles contains the roles of ant groups.
+var antRoles = map[ant.Type]antRole{
+	ant.TypeMiner: {
+		name:     ant.NameMiner,
+		defaults: ant.AntConfig{Jobs: []ant.JobConfig{{Name: "gateway"}, {Name: "miner"}}},
+	},
+	ant.TypeHost: {
+		name:     ant.NameHost,
+		defaults: ant.AntConfig{Jobs: []ant.JobConfig{{Name: "host"}}, DesiredCurrency: 100000},
+	},
+	ant.TypeRenter: {
+		name:     ant.NameRenter,
+		defaults: ant.AntConfig{Jobs: []ant.JobConfig{{Name: "renter"}}, DesiredCurrency: 100000},
+	},
+	ant.TypeGeneric: {
+		name:     ant.NameGeneric,
+		defaults: ant.AntConfig{Jobs: []ant.JobConfig{{Name: "generic"}}},
+	},
+}
+
+// Expand returns the config with the ants of its Groups appended to
+// AntConfigs and without Groups.
+func (afc AntfarmConfig) Expand() (AntfarmConfig, error) {
+	if len(afc.Groups) == 0 {
+		return afc, nil
+	}
+	config := afc
+	config.AntConfigs = append([]ant.AntConfig(nil), afc.AntConfigs...)
+	config.Groups = nil
+	next := make(map[ant.Type]int)
+	for i, g := range afc.Groups {
+		ants, err := expandGroup(g, next)
+		if err != nil {
+			return AntfarmConfig{}, fmt.Errorf("ant group %v: %v", i, err)
+		}
+		config.AntConfigs = append(config.AntConfigs, ants...)
+	}
+	return config, nil
+}
+
+// groupRole returns the ant type of the given role, roles are matched
+// case-insensitively.
+func groupRole(role ant.Type) (ant.Type, bool) {
+	for t := range antRoles {
+		if strings.EqualFold(string(t), string(role)) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// expandGroup returns the configs of the group's ants. next contains the next
+// ant index of each role and is updated.
+func expandGroup(g AntGroup, next map[ant.Type]int) ([]ant.AntConfig, error) {
+	t, ok := groupRole(g.Role)
+	if !ok {
+		return nil, fmt.Errorf("unknown role %v", g.Role)
+	}
+	if g.Count < 1 {
+		return nil, fmt.Errorf("count must be positive, got %v", g.Count)
+	}
+	role := antRoles[t]
+	template := g.AntConfig
+	if len(template.Jobs) == 0 {
+		template.Jobs = role.defaults.Jobs
+		if template.DesiredCurrency == 0 {
+			template.DesiredCurrency = role.defaults.DesiredCurrency
+		}
+	}
+
+	var configs []ant.AntConfig
+	for i := 0; i < g.Count; i++ {
+		c := template
+		c.Name = role.name(next[t])
+		next[t]++
+		c.Jobs = append([]ant.JobConfig(nil), template.Jobs...)
+		if template.Proxy != nil {
+			p := *template.Proxy
+			c.Proxy = &p
+		}
+		if template.RestartPolicy != nil {
+			rp := *template.RestartPolicy
+			c.RestartPolicy = &rp
+		}
+		if template.DataDir != "" {
+			c.DataDir = filepath.Join(template.DataDir, c.Name)
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
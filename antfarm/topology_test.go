@@ -0,0 +1,82 @@
+package antfarm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/proxy"
+)
+
+// TestExpandGroups verifies that ant groups are expanded into ant configs with
+// standardized names.
+func TestExpandGroups(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	config := AntfarmConfig{
+		AntConfigs: []ant.AntConfig{{Name: "custom", Jobs: []ant.JobConfig{{Name: "gateway"}}}},
+		Groups: []AntGroup{
+			{Role: "miner", Count: 1},
+			{
+				Role:  "host",
+				Count: 2,
+				AntConfig: ant.AntConfig{
+					SiadConfig: ant.SiadConfig{DataDir: "hosts", SiadPath: "siad-v1.5.4"},
+					Proxy:      &proxy.Config{LatencyMilliseconds: 50},
+				},
+			},
+			{Role: "Host", Count: 1, AntConfig: ant.AntConfig{Jobs: []ant.JobConfig{{Name: "host"}, {Name: "gateway"}}}},
+		},
+	}
+	expanded, err := config.Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expanded.Groups != nil {
+		t.Fatal("expected expanded config without groups")
+	}
+
+	var names []string
+	for _, c := range expanded.AntConfigs {
+		names = append(names, c.Name)
+	}
+	expectedNames := []string{"custom", ant.NameMiner(0), ant.NameHost(0), ant.NameHost(1), ant.NameHost(2)}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("expected ants %v, got %v", expectedNames, names)
+	}
+
+	// Groups without jobs get the role defaults
+	miner := expanded.AntConfigs[1]
+	if !reflect.DeepEqual(miner.Jobs, []ant.JobConfig{{Name: "gateway"}, {Name: "miner"}}) || miner.DesiredCurrency != 0 {
+		t.Fatalf("unexpected miner config %+v", miner)
+	}
+
+	// The template is applied to each ant of the group
+	for _, c := range expanded.AntConfigs[2:4] {
+		if c.DataDir != filepath.Join("hosts", c.Name) {
+			t.Fatalf("unexpected data dir %v of ant %v", c.DataDir, c.Name)
+		}
+		if c.SiadPath != "siad-v1.5.4" || c.DesiredCurrency != 100000 || c.Proxy.LatencyMilliseconds != 50 {
+			t.Fatalf("unexpected host config %+v", c)
+		}
+	}
+	if expanded.AntConfigs[2].Proxy == expanded.AntConfigs[3].Proxy {
+		t.Fatal("expected ants not to share a proxy config")
+	}
+
+	// Groups with jobs keep their jobs and desired currency
+	host := expanded.AntConfigs[4]
+	if len(host.Jobs) != 2 || host.DesiredCurrency != 0 {
+		t.Fatalf("unexpected host config %+v", host)
+	}
+
+	// Unknown roles are rejected
+	config.Groups = []AntGroup{{Role: "farmer", Count: 1}}
+	if _, err := config.Expand(); err == nil {
+		t.Fatal("expected error expanding unknown role")
+	}
+}
@@ -0,0 +1,358 @@
+package antfarm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// defaultSiadPath defines the siad binary used by ants without SiadPath.
+	defaultSiadPath = "siad-dev"
+)
+
+// ConfigProblem describes an invalid value in an antfarm config.
+type ConfigProblem struct {
+	// Path is the JSON path of the invalid value, e.g.
+	// "$.AntConfigs[1].Jobs[0]".
+	Path    string
+	Message string
+}
+
+// Error implements error.
+func (p ConfigProblem) Error() string {
+	return p.Path + ": " + p.Message
+}
+
+// DecodeConfig strictly decodes an antfarm config, i.e. unknown fields are
+// rejected. Field names are matched case-insensitively.
+func DecodeConfig(r io.Reader) (config AntfarmConfig, err error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&config); err != nil {
+		return AntfarmConfig{}, err
+	}
+	return config, nil
+}
+
+// ValidateConfigFile decodes and validates the antfarm config in the given
+// JSON data, see ValidateConfig.
+func ValidateConfigFile(data []byte, checkBinaries bool) []ConfigProblem {
+	config, err := DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return []ConfigProblem{{Path: "$", Message: fmt.Sprintf("can't decode config: %v", err)}}
+	}
+	return ValidateConfig(config, checkBinaries)
+}
+
+// ValidateConfig checks the antfarm config without starting any ant and
+// returns all problems found. If checkBinaries is true, it also checks that
+// the ants' siad binaries exist and run dev constants.
+func ValidateConfig(config AntfarmConfig, checkBinaries bool) (problems []ConfigProblem) {
+	v := configValidator{
+		addrs:       make(map[string]string),
+		dataDirs:    make(map[string]string),
+		names:       make(map[string]string),
+		siadResults: make(map[string]error),
+		faucetAnt:   config.FaucetAnt,
+	}
+
+	if config.ListenAddress != "" {
+		v.checkAddr("$.ListenAddress", config.ListenAddress)
+	}
+	for i, address := range config.ExternalFarms {
+		v.checkAddr(fmt.Sprintf("$.ExternalFarms[%d]", i), address)
+	}
+	if webhook := config.SyncPolicy.AlertWebhookURL; webhook != "" {
+		u, err := url.Parse(webhook)
+		if err != nil {
+			v.add("$.SyncPolicy.AlertWebhookURL", err.Error())
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			v.add("$.SyncPolicy.AlertWebhookURL", "webhook URL must be an http or https URL")
+		}
+	}
+	if config.SyncPolicy.MaxGroups < 0 {
+		v.add("$.SyncPolicy.MaxGroups", "max groups can't be negative")
+	}
+
+	for i, c := range config.AntConfigs {
+		v.checkAnt(fmt.Sprintf("$.AntConfigs[%d]", i), c, checkBinaries)
+	}
+	next := make(map[ant.Type]int)
+	for i, g := range config.Groups {
+		v.checkGroup(fmt.Sprintf("$.Groups[%d]", i), g, next, checkBinaries)
+	}
+	if config.FaucetAnt != "" {
+		v.checkFaucetAnt(config)
+	}
+	return v.problems
+}
+
+// configProblemsError composes the config problems into a single error.
+func configProblemsError(problems []ConfigProblem) error {
+	errs := make([]error, 0, len(problems))
+	for _, p := range problems {
+		errs = append(errs, p)
+	}
+	return errors.Compose(errs...)
+}
+
+// configField is a string field of a config with its field name.
+type configField struct {
+	field string
+	value string
+}
+
+// configValidator collects problems of an antfarm config and the values which
+// must be unique across the ants.
+type configValidator struct {
+	problems []ConfigProblem
+
+	// addrs, dataDirs and names map used values to the JSON paths they were
+	// first used at.
+	addrs    map[string]string
+	dataDirs map[string]string
+	names    map[string]string
+
+	// siadResults caches siad binary checks by siad path.
+	siadResults map[string]error
+
+	// faucetAnt is the name of the antfarm's faucet ant.
+	faucetAnt string
+}
+
+// add records a problem at the given JSON path.
+func (v *configValidator) add(path, msg string) {
+	v.problems = append(v.problems, ConfigProblem{Path: path, Message: msg})
+}
+
+// checkUnique records a problem if the value was already used at another JSON
+// path.
+func (v *configValidator) checkUnique(used map[string]string, path, what, value string) {
+	if first, ok := used[value]; ok {
+		v.add(path, fmt.Sprintf("%v %v is not unique, it is also used at %v", what, value, first))
+		return
+	}
+	used[value] = path
+}
+
+// checkAddr records a problem if the given address is not a valid unique
+// host:port address.
+func (v *configValidator) checkAddr(path, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.add(path, fmt.Sprintf("invalid address: %v", err))
+		return
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		v.add(path, fmt.Sprintf("invalid port %v", port))
+		return
+	}
+	v.checkUnique(v.addrs, path, "address", addr)
+}
+
+// checkAnt records the problems of the ant config at the given JSON path.
+func (v *configValidator) checkAnt(path string, c ant.AntConfig, checkBinaries bool) {
+	if c.Name != "" {
+		v.checkUnique(v.names, path+".Name", "ant name", c.Name)
+	}
+	if c.DataDir != "" {
+		v.checkUnique(v.dataDirs, path+".DataDir", "data directory", c.DataDir)
+	}
+	for _, a := range antAddrs(c) {
+		if a.value != "" {
+			v.checkAddr(path+"."+a.field, a.value)
+		}
+	}
+	v.checkAntSettings(path, c, checkBinaries)
+}
+
+// checkGroup records the problems of the ant group at the given JSON path.
+// The group's settings are checked once, the names and data directories of
+// the group's ants are checked to be unique. next contains the next ant index
+// of each role and is updated.
+func (v *configValidator) checkGroup(path string, g AntGroup, next map[ant.Type]int, checkBinaries bool) {
+	if _, ok := groupRole(g.Role); !ok {
+		v.add(path+".Role", fmt.Sprintf("unknown role %v, expected one of miner, host, renter or generic", g.Role))
+		return
+	}
+	if g.Count < 1 {
+		v.add(path+".Count", "count must be positive")
+		return
+	}
+	if g.Name != "" {
+		v.add(path+".Name", "ants of a group are named by their role")
+	}
+	if g.Count > 1 {
+		shared := append(antAddrs(g.AntConfig), configField{"InitialWalletSeed", g.InitialWalletSeed}, configField{"Seed", g.Seed})
+		for _, a := range shared {
+			if a.value != "" {
+				v.add(path+"."+a.field, fmt.Sprintf("%v can't be shared by %v ants", a.field, g.Count))
+			}
+		}
+	}
+
+	ants, err := expandGroup(g, next)
+	if err != nil {
+		v.add(path, err.Error())
+		return
+	}
+	for _, c := range ants {
+		v.checkUnique(v.names, path, "ant name", c.Name)
+		if c.DataDir != "" {
+			v.checkUnique(v.dataDirs, path+".DataDir", "data directory", c.DataDir)
+		}
+	}
+	if g.Count == 1 {
+		for _, a := range antAddrs(ants[0]) {
+			if a.value != "" {
+				v.checkAddr(path+"."+a.field, a.value)
+			}
+		}
+	}
+	v.checkAntSettings(path, ants[0], checkBinaries)
+}
+
+// checkFaucetAnt records a problem if the antfarm's faucet ant is not a miner
+// ant of the config.
+func (v *configValidator) checkFaucetAnt(config AntfarmConfig) {
+	// Problems of ant groups were already recorded
+	expanded, err := config.Expand()
+	if err != nil {
+		return
+	}
+	for _, c := range expanded.AntConfigs {
+		if c.Name != config.FaucetAnt {
+			continue
+		}
+		if countJobs(c.Jobs, "miner") == 0 {
+			v.add("$.FaucetAnt", fmt.Sprintf("faucet ant %v doesn't run the miner job", c.Name))
+		}
+		if c.UseFaucet {
+			v.add("$.FaucetAnt", fmt.Sprintf("faucet ant %v can't use the faucet", c.Name))
+		}
+		return
+	}
+	v.add("$.FaucetAnt", fmt.Sprintf("faucet ant %v is not defined", config.FaucetAnt))
+}
+
+// antAddrs returns the addresses of the ant config with their field names.
+func antAddrs(c ant.AntConfig) []configField {
+	return []configField{
+		{"APIAddr", c.APIAddr},
+		{"RPCAddr", c.RPCAddr},
+		{"HostAddr", c.HostAddr},
+		{"SiaMuxAddr", c.SiaMuxAddr},
+		{"SiaMuxWsAddr", c.SiaMuxWsAddr},
+		{"InternalRPCAddr", c.InternalRPCAddr},
+		{"InternalHostAddr", c.InternalHostAddr},
+		{"InternalSiaMuxAddr", c.InternalSiaMuxAddr},
+	}
+}
+
+// checkProxyAddrs records the problems of the public and internal addresses
+// of the ant config with a proxy at the given JSON path. Siad advertises the
+// ports it listens on, so the internal addresses must use the ports of the
+// public addresses on a different host to be reached through the proxy.
+func (v *configValidator) checkProxyAddrs(path string, c ant.AntConfig) {
+	addrs := []struct {
+		public, internal configField
+	}{
+		{configField{"RPCAddr", c.RPCAddr}, configField{"InternalRPCAddr", c.InternalRPCAddr}},
+		{configField{"HostAddr", c.HostAddr}, configField{"InternalHostAddr", c.InternalHostAddr}},
+		{configField{"SiaMuxAddr", c.SiaMuxAddr}, configField{"InternalSiaMuxAddr", c.InternalSiaMuxAddr}},
+	}
+	for _, a := range addrs {
+		var publicHost, publicPort string
+		if a.public.value != "" {
+			host, port, err := net.SplitHostPort(a.public.value)
+			if err != nil {
+				// Invalid addresses were already recorded
+				continue
+			}
+			if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+				v.add(path+"."+a.public.field, "an ant behind a proxy requires a public address with a specific host, siad listens on its port on an internal host")
+				continue
+			}
+			publicHost, publicPort = host, port
+		}
+		if a.internal.value == "" {
+			continue
+		}
+		if a.public.value == "" {
+			v.add(path+"."+a.internal.field, fmt.Sprintf("%v requires %v", a.internal.field, a.public.field))
+			continue
+		}
+		host, port, err := net.SplitHostPort(a.internal.value)
+		if err != nil {
+			continue
+		}
+		if port != publicPort {
+			v.add(path+"."+a.internal.field, fmt.Sprintf("%v must use the port of %v, siad advertises the port it listens on", a.internal.field, a.public.field))
+		} else if host == publicHost {
+			v.add(path+"."+a.internal.field, fmt.Sprintf("%v must use a different host than %v", a.internal.field, a.public.field))
+		}
+	}
+}
+
+// checkAntSettings records the problems of the ant config's jobs, desired
+// currency, faucet, proxy, restart policy, external siad and siad binary at
+// the given JSON path.
+func (v *configValidator) checkAntSettings(path string, c ant.AntConfig, checkBinaries bool) {
+	for i, jc := range c.Jobs {
+		if _, err := ant.NewJobFromConfig(jc); err != nil {
+			v.add(fmt.Sprintf("%v.Jobs[%d]", path, i), err.Error())
+		}
+	}
+	if err := checkDesiredCurrency(c); err != nil {
+		v.add(path+".DesiredCurrency", err.Error())
+	}
+
+	if c.UseFaucet && v.faucetAnt == "" {
+		v.add(path+".UseFaucet", "the antfarm has no FaucetAnt")
+	}
+
+	if c.Proxy != nil && (c.Proxy.DropRate < 0 || c.Proxy.DropRate > 1) {
+		v.add(path+".Proxy.DropRate", "drop rate must be between 0 and 1")
+	}
+	if c.Proxy != nil {
+		v.checkProxyAddrs(path, c)
+	}
+
+	if c.RestartPolicy != nil {
+		if err := c.RestartPolicy.Validate(); err != nil {
+			v.add(path+".RestartPolicy.Policy", err.Error())
+		}
+	}
+
+	// An external siad is served at the API address, it isn't spawned
+	if c.ExternalSiad && c.APIAddr == "" {
+		v.add(path+".APIAddr", "an external siad requires the API address")
+	}
+
+	// Binaries of siad versions are built when the antfarm is created
+	if c.SiadVersion != "" && c.SiadPath != "" {
+		v.add(path+".SiadVersion", "SiadVersion and SiadPath are mutually exclusive")
+	}
+	if checkBinaries && c.SiadVersion == "" && !c.ExternalSiad {
+		siadPath := c.SiadPath
+		if siadPath == "" {
+			siadPath = defaultSiadPath
+		}
+		err, ok := v.siadResults[siadPath]
+		if !ok {
+			err = ant.CheckSiad(siadPath)
+			v.siadResults[siadPath] = err
+		}
+		if err != nil {
+			v.add(path+".SiadPath", errors.AddContext(err, fmt.Sprintf("siad %v", siadPath)).Error())
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package antfarm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/proxy"
+)
+
+// TestValidateConfig verifies that all problems of an antfarm config are
+// reported with their JSON paths.
+func TestValidateConfig(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// A valid config has no problems
+	valid := AntfarmConfig{
+		ListenAddress: "127.0.0.1:9900",
+		AntConfigs: []ant.AntConfig{
+			{Name: "miner", Jobs: []ant.JobConfig{{Name: "gateway"}, {Name: "miner"}}},
+			{Name: "host", Jobs: []ant.JobConfig{{Name: "host"}}, DesiredCurrency: 100000},
+		},
+	}
+	if problems := ValidateConfig(valid, false); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	// All problems are reported at once
+	invalid := AntfarmConfig{
+		ListenAddress: "127.0.0.1",
+		AntConfigs: []ant.AntConfig{
+			{
+				SiadConfig:      ant.SiadConfig{APIAddr: "127.0.0.1:9980"},
+				Name:            "miner",
+				Jobs:            []ant.JobConfig{{Name: "miner"}, {Name: "unknown"}},
+				DesiredCurrency: 1000,
+			},
+			{
+				SiadConfig: ant.SiadConfig{RPCAddr: "127.0.0.1:9980"},
+				Name:       "miner",
+				Proxy:      &proxy.Config{DropRate: 2},
+			},
+			{
+				SiadConfig:  ant.SiadConfig{SiadPath: "siad-dev"},
+				SiadVersion: "v1.5.4",
+			},
+			{
+				SiadConfig:    ant.SiadConfig{ExternalSiad: true},
+				RestartPolicy: &ant.RestartPolicy{Policy: "always"},
+			},
+			{
+				SiadConfig: ant.SiadConfig{
+					RPCAddr:         "127.0.0.1:9981",
+					InternalRPCAddr: "127.0.0.2:9982",
+					HostAddr:        ":9983",
+				},
+				Proxy: &proxy.Config{},
+			},
+		},
+	}
+	var paths []string
+	for _, p := range ValidateConfig(invalid, false) {
+		paths = append(paths, p.Path)
+	}
+	expected := []string{
+		"$.ListenAddress",
+		"$.AntConfigs[0].Jobs[1]",
+		"$.AntConfigs[0].DesiredCurrency",
+		"$.AntConfigs[1].Name",
+		"$.AntConfigs[1].RPCAddr",
+		"$.AntConfigs[1].Proxy.DropRate",
+		"$.AntConfigs[2].SiadVersion",
+		"$.AntConfigs[3].RestartPolicy.Policy",
+		"$.AntConfigs[3].APIAddr",
+		"$.AntConfigs[4].InternalRPCAddr",
+		"$.AntConfigs[4].HostAddr",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected problems at %v, got %v", expected, paths)
+	}
+}
+
+// TestValidateConfigFile verifies that unknown fields of an antfarm config
+// file are reported.
+func TestValidateConfigFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Field names are case-insensitive
+	problems := ValidateConfigFile([]byte(`{"antconfigs": [{"jobs": ["gateway"]}]}`), false)
+	if len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	problems = ValidateConfigFile([]byte(`{"AntConfigs": [{"Jobz": ["gateway"]}]}`), false)
+	if len(problems) != 1 || problems[0].Path != "$" || !strings.Contains(problems[0].Message, "Jobz") {
+		t.Fatalf("expected unknown field problem, got %v", problems)
+	}
+}
+
+// TestValidateConfigGroups verifies that problems of ant groups are reported
+// once per group.
+func TestValidateConfigGroups(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	config := AntfarmConfig{
+		AntConfigs: []ant.AntConfig{{Name: ant.NameHost(1)}},
+		Groups: []AntGroup{
+			{Role: "farmer", Count: 1},
+			{Role: "renter", Count: 0},
+			{
+				Role:      "host",
+				Count:     3,
+				AntConfig: ant.AntConfig{SiadConfig: ant.SiadConfig{APIAddr: "127.0.0.1:9980"}, Jobs: []ant.JobConfig{{Name: "unknown"}}},
+			},
+		},
+	}
+	var paths []string
+	for _, p := range ValidateConfig(config, false) {
+		paths = append(paths, p.Path)
+	}
+	expected := []string{
+		"$.Groups[0].Role",
+		"$.Groups[1].Count",
+		"$.Groups[2].APIAddr",
+		"$.Groups[2]",
+		"$.Groups[2].Jobs[0]",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected problems at %v, got %v", expected, paths)
+	}
+}
+
+// TestValidateConfigFaucet verifies that the faucet ant must be a miner ant of
+// the config and that ants can use the faucet only if it is set.
+func TestValidateConfigFaucet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// A miner ant of a group funds hosts
+	config := AntfarmConfig{
+		FaucetAnt: ant.NameMiner(0),
+		Groups: []AntGroup{
+			{Role: "miner", Count: 1},
+			{Role: "host", Count: 2, AntConfig: ant.AntConfig{UseFaucet: true}},
+		},
+	}
+	if problems := ValidateConfig(config, false); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	tests := []struct {
+		config   AntfarmConfig
+		expected []string
+	}{
+		{
+			config: AntfarmConfig{
+				AntConfigs: []ant.AntConfig{{Name: "host", UseFaucet: true}},
+			},
+			expected: []string{"$.AntConfigs[0].UseFaucet"},
+		},
+		{
+			config: AntfarmConfig{
+				FaucetAnt:  "miner",
+				AntConfigs: []ant.AntConfig{{Name: "host"}},
+			},
+			expected: []string{"$.FaucetAnt"},
+		},
+		{
+			config: AntfarmConfig{
+				FaucetAnt:  "host",
+				AntConfigs: []ant.AntConfig{{Name: "host", UseFaucet: true}},
+			},
+			expected: []string{"$.FaucetAnt", "$.FaucetAnt"},
+		},
+	}
+	for _, test := range tests {
+		var paths []string
+		for _, p := range ValidateConfig(test.config, false) {
+			paths = append(paths, p.Path)
+		}
+		if !reflect.DeepEqual(paths, test.expected) {
+			t.Fatalf("expected problems at %v, got %v", test.expected, paths)
+		}
+	}
+}
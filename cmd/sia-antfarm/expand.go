@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.sia.tech/sia-antfarm/antfarm"
+)
+
+// expand prints the sia-antfarm configuration file with its ant groups
+// expanded into ant configs. It returns the process exit code.
+func expand(args []string) int {
+	fs := flag.NewFlagSet("expand", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "path to the sia-antfarm configuration file")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	expanded, err := config.Expand()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error expanding %v: %v\n", *configPath, err)
+		return 1
+	}
+	data, err := json.MarshalIndent(expanded, "", "\t")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error encoding expanded config: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
@@ -1,37 +1,65 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"go.sia.tech/sia-antfarm/antfarm"
+	"go.sia.tech/sia-antfarm/scenario"
 	"gitlab.com/NebulousLabs/errors"
 )
 
 func main() {
+	// Run a subcommand instead of the antfarm if given
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "schema":
+			os.Exit(schema())
+		case "expand":
+			os.Exit(expand(os.Args[2:]))
+		}
+	}
+
 	configPath := flag.String("config", "config.json", "path to the sia-antfarm configuration file")
+	scenarioPath := flag.String("scenario", "", "path to an optional scenario file run on the antfarm")
+	drainTimeout := flag.Duration("drain-timeout", 0, "on a quit signal, stop starting new uploads and downloads and wait up to this long for in-flight ones before closing the ants, 0 closes the ants immediately")
 	flag.Parse()
 
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, os.Interrupt)
+	// The context is cancelled on the first quit signal. Afterwards the
+	// default signal handling is restored, so that a second signal kills the
+	// process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// SIGHUP reloads the sia-antfarm configuration file.
+	hupchan := make(chan os.Signal, 1)
+	signal.Notify(hupchan, syscall.SIGHUP)
 
 	// Read and decode the sia-antfarm configuration file.
-	var antfarmConfig antfarm.AntfarmConfig
-	f, err := os.Open(*configPath)
+	antfarmConfig, err := loadConfig(*configPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error opening %v: %v\n", *configPath, err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	if err = json.NewDecoder(f).Decode(&antfarmConfig); err != nil {
-		fmt.Fprintf(os.Stderr, "error decoding %v: %v\n", *configPath, err)
-		os.Exit(1)
-	}
-	if err = f.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "error closing antfarm config file: %v\n", err)
+	// Load the optional scenario before starting any ants.
+	var s scenario.Scenario
+	if *scenarioPath != "" {
+		s, err = scenario.Load(*scenarioPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading scenario %v: %v\n", *scenarioPath, err)
+			os.Exit(1)
+		}
 	}
 
 	logger, err := antfarm.NewAntfarmLogger(antfarmConfig.DataDir)
@@ -45,7 +73,11 @@ func main() {
 		}
 	}()
 
-	farm, err := antfarm.New(logger, antfarmConfig)
+	farm, err := antfarm.NewWithContext(ctx, logger, antfarmConfig)
+	if err != nil && ctx.Err() != nil {
+		fmt.Println("Caught quit signal while creating antfarm, quitting...")
+		return
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error creating antfarm: %v\n", err)
 		os.Exit(1)
@@ -62,7 +94,100 @@ func main() {
 	}()
 	go farm.PermanentSyncMonitor()
 
-	fmt.Printf("Finished.  Running sia-antfarm with %v ants.\n", len(antfarmConfig.AntConfigs))
-	<-sigchan
-	fmt.Println("Caught quit signal, quitting...")
+	fmt.Printf("Finished.  Running sia-antfarm with %v ants.\n", len(farm.GetAnts()))
+
+	// Run the scenario, scenarioDone stays nil without a scenario.
+	var scenarioDone chan error
+	scenarioStop := make(chan struct{})
+	defer close(scenarioStop)
+	if *scenarioPath != "" {
+		scenarioDone = make(chan error, 1)
+		go func() {
+			results, err := scenario.Run(farm, logger, s, scenarioStop)
+			for _, r := range results {
+				if r.Error != "" {
+					fmt.Printf("Scenario step %v failed after %v: %v\n", r.Name, r.Duration, r.Error)
+					continue
+				}
+				fmt.Printf("Scenario step %v finished in %v\n", r.Name, r.Duration)
+			}
+			scenarioDone <- err
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Caught quit signal, quitting...")
+			if *drainTimeout > 0 {
+				fmt.Printf("Draining antfarm for up to %v...\n", *drainTimeout)
+				drainCtx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+				if err := farm.Drain(drainCtx); err != nil {
+					fmt.Fprintf(os.Stderr, "error draining antfarm: %v\n", err)
+				}
+				cancel()
+			}
+			return
+		case err := <-scenarioDone:
+			if err == nil {
+				fmt.Printf("Scenario %v passed, quitting...\n", s.Name)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Scenario %v failed: %v, quitting...\n", s.Name, err)
+
+			// os.Exit doesn't run deferred functions, close the antfarm and
+			// the logger explicitly
+			if err := farm.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "error closing antfarm: %v\n", err)
+			}
+			if err := logger.Close(); err != nil {
+				fmt.Println(errors.AddContext(err, "can't close logger"))
+			}
+			os.Exit(1)
+		case <-hupchan:
+			fmt.Printf("Caught hangup signal, reloading %v...\n", *configPath)
+			config, err := loadConfig(*configPath)
+			if err == nil {
+				err = farm.Reload(config)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reloading antfarm config: %v\n", err)
+				continue
+			}
+			fmt.Println("Antfarm config was reloaded.")
+		case alert := <-farm.SyncAlerts():
+			if !antfarmConfig.SyncPolicy.ExitOnAlert {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Sync alert: %v, quitting...\n", alert.Reason)
+
+			// os.Exit doesn't run deferred functions, close the antfarm and
+			// the logger explicitly
+			if err := farm.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "error closing antfarm: %v\n", err)
+			}
+			if err := logger.Close(); err != nil {
+				fmt.Println(errors.AddContext(err, "can't close logger"))
+			}
+			os.Exit(1)
+		}
+	}
+}
+
+// loadConfig reads and decodes the sia-antfarm configuration file. Unknown
+// fields are rejected like by `sia-antfarm validate`.
+func loadConfig(path string) (config antfarm.AntfarmConfig, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return antfarm.AntfarmConfig{}, fmt.Errorf("error opening %v: %v", path, err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "error closing antfarm config file: %v\n", closeErr)
+		}
+	}()
+	if config, err = antfarm.DecodeConfig(f); err != nil {
+		return antfarm.AntfarmConfig{}, fmt.Errorf("error decoding %v: %v", path, err)
+	}
+	return config, nil
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"go.sia.tech/sia-antfarm/antfarm"
+)
+
+// validate checks the sia-antfarm configuration file without starting any ant
+// and prints all problems found. It returns the process exit code.
+func validate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "path to the sia-antfarm configuration file")
+	skipBinaries := fs.Bool("skip-binaries", false, "don't check that the ants' siad binaries exist and run dev constants")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	data, err := ioutil.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %v: %v\n", *configPath, err)
+		return 1
+	}
+	problems := antfarm.ValidateConfigFile(data, !*skipBinaries)
+	if len(problems) == 0 {
+		fmt.Printf("%v is valid\n", *configPath)
+		return 0
+	}
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	fmt.Fprintf(os.Stderr, "%v has %v problems\n", *configPath, len(problems))
+	return 1
+}
+
+// schema prints the JSON Schema of the sia-antfarm configuration file. It
+// returns the process exit code.
+func schema() int {
+	s, err := antfarm.ConfigSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error generating config schema: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(s))
+	return 0
+}
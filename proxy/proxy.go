@@ -0,0 +1,235 @@
+// Package proxy implements a userspace TCP proxy which shapes the proxied
+// traffic by adding latency and jitter, capping bandwidth and dropping
+// connections. It doesn't require root privileges nor tc/netem.
+package proxy
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+const (
+	// chunkSize defines the maximum size of data read from a connection at
+	// once. Latency and bandwidth caps are applied per chunk.
+	chunkSize = 16 << 10
+
+	// chunkQueueSize defines how many chunks read from a connection can wait
+	// for their delivery time.
+	chunkQueueSize = 256
+
+	// dialTimeout defines timeout for connecting to the proxy target.
+	dialTimeout = time.Second * 10
+)
+
+// Config defines how the proxy shapes the proxied traffic. Zero values
+// disable the corresponding shaping.
+type Config struct {
+	// LatencyMilliseconds is added to the delivery of data in each direction.
+	LatencyMilliseconds uint64
+
+	// JitterMilliseconds defines the maximum random deviation added to or
+	// subtracted from the latency. Data order is preserved.
+	JitterMilliseconds uint64
+
+	// BandwidthBytesPerSecond caps the bandwidth of each direction of each
+	// connection.
+	BandwidthBytesPerSecond uint64
+
+	// DropRate defines the probability (0 to 1) a new connection is dropped.
+	DropRate float64
+}
+
+// Proxy forwards TCP connections accepted on its listen address to its target
+// address and shapes the traffic according to its config.
+type Proxy struct {
+	staticConfig   Config
+	staticListener net.Listener
+	staticTarget   string
+
+	conns  map[net.Conn]struct{}
+	closed bool
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// chunk is a piece of data read from a connection with its delivery time.
+type chunk struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+// New starts a new proxy which listens on the given listen address and
+// forwards connections to the given target address.
+func New(listenAddr, targetAddr string, config Config) (*Proxy, error) {
+	if config.DropRate < 0 || config.DropRate > 1 {
+		return nil, errors.New("drop rate must be between 0 and 1")
+	}
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, errors.AddContext(err, "can't listen on proxy address")
+	}
+	p := &Proxy{
+		staticConfig:   config,
+		staticListener: l,
+		staticTarget:   targetAddr,
+		conns:          make(map[net.Conn]struct{}),
+	}
+	p.wg.Add(1)
+	go p.threadedAccept()
+	return p, nil
+}
+
+// Addr returns the address the proxy listens on.
+func (p *Proxy) Addr() string {
+	return p.staticListener.Addr().String()
+}
+
+// Close stops accepting connections, closes all proxied connections and waits
+// until all proxy threads return.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	err := p.staticListener.Close()
+	for c := range p.conns {
+		// The connection might be already closing by its forwarding
+		// threads, so the close error is ignored.
+		_ = c.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return err
+}
+
+// managedTrack adds the connections to the tracked connections, so that they
+// are closed when the proxy is closed. It returns false if the proxy is
+// already closed.
+func (p *Proxy) managedTrack(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+	return true
+}
+
+// managedUntrack removes the connections from the tracked connections.
+func (p *Proxy) managedUntrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		delete(p.conns, c)
+	}
+}
+
+// threadedAccept accepts connections until the listener is closed.
+func (p *Proxy) threadedAccept() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.staticListener.Accept()
+		if err != nil {
+			return
+		}
+		if p.staticConfig.DropRate > 0 && float64(fastrand.Intn(1e6)) < p.staticConfig.DropRate*1e6 {
+			_ = conn.Close()
+			continue
+		}
+		p.wg.Add(1)
+		go p.threadedProxy(conn)
+	}
+}
+
+// threadedProxy forwards the connection to the proxy target.
+func (p *Proxy) threadedProxy(conn net.Conn) {
+	defer p.wg.Done()
+	target, err := net.DialTimeout("tcp", p.staticTarget, dialTimeout)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	if !p.managedTrack(conn, target) {
+		_ = conn.Close()
+		_ = target.Close()
+		return
+	}
+
+	// Forward both directions, when one direction finishes, close both
+	// connections to stop the other direction.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	forward := func(dst, src net.Conn) {
+		defer wg.Done()
+		p.forward(dst, src)
+		_ = dst.Close()
+		_ = src.Close()
+	}
+	go forward(target, conn)
+	go forward(conn, target)
+	wg.Wait()
+	p.managedUntrack(conn, target)
+}
+
+// forward copies data from src to dst, delaying its delivery by the latency
+// and jitter and capping its bandwidth.
+func (p *Proxy) forward(dst io.Writer, src io.Reader) {
+	chunks := make(chan chunk, chunkQueueSize)
+
+	// Read chunks and schedule their delivery
+	go func() {
+		defer close(chunks)
+		var last time.Time
+		for {
+			buf := make([]byte, chunkSize)
+			n, err := src.Read(buf)
+			if n > 0 {
+				deliverAt := time.Now().Add(p.delay())
+				if deliverAt.Before(last) {
+					// Preserve data order
+					deliverAt = last
+				}
+				last = deliverAt
+				chunks <- chunk{data: buf[:n], deliverAt: deliverAt}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Deliver chunks, keep draining chunks after a write error so that the
+	// reading goroutine can return
+	var writeErr error
+	for c := range chunks {
+		if writeErr != nil {
+			continue
+		}
+		time.Sleep(time.Until(c.deliverAt))
+		start := time.Now()
+		_, writeErr = dst.Write(c.data)
+		if bw := p.staticConfig.BandwidthBytesPerSecond; bw > 0 {
+			transferTime := time.Duration(uint64(len(c.data)) * uint64(time.Second) / bw)
+			time.Sleep(transferTime - time.Since(start))
+		}
+	}
+}
+
+// delay returns the delivery delay of a chunk, i.e. the latency with random
+// jitter.
+func (p *Proxy) delay() time.Duration {
+	latency := time.Duration(p.staticConfig.LatencyMilliseconds) * time.Millisecond
+	jitter := int64(p.staticConfig.JitterMilliseconds)
+	if jitter > 0 {
+		latency += time.Duration(int64(fastrand.Intn(int(2*jitter+1)))-jitter) * time.Millisecond
+	}
+	if latency < 0 {
+		return 0
+	}
+	return latency
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// newEchoServer starts a TCP server which echoes all received data.
+func newEchoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+// roundTrip sends data through the proxy and returns the echoed data and the
+// round trip duration.
+func roundTrip(t *testing.T, addr string, data []byte) ([]byte, time.Duration) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	start := time.Now()
+	go func() {
+		_, _ = conn.Write(data)
+	}()
+	received := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, received); err != nil {
+		t.Fatal(err)
+	}
+	return received, time.Since(start)
+}
+
+// TestProxy verifies that the proxy forwards data and adds latency and
+// bandwidth caps.
+func TestProxy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	echo := newEchoServer(t)
+	defer func() {
+		if err := echo.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	tests := []struct {
+		name        string
+		config      Config
+		size        int
+		minDuration time.Duration
+	}{
+		{"no shaping", Config{}, 1 << 20, 0},
+		// Latency is added in both directions
+		{"latency", Config{LatencyMilliseconds: 100, JitterMilliseconds: 10}, 1 << 10, 180 * time.Millisecond},
+		// 256 KiB at 1 MiB/s takes at least 250ms
+		{"bandwidth", Config{BandwidthBytesPerSecond: 1 << 20}, 256 << 10, 240 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		p, err := New("127.0.0.1:0", echo.Addr().String(), tt.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := fastrand.Bytes(tt.size)
+		received, d := roundTrip(t, p.Addr(), data)
+		if !bytes.Equal(data, received) {
+			t.Errorf("%v: received data doesn't equal sent data", tt.name)
+		}
+		if d < tt.minDuration {
+			t.Errorf("%v: expected round trip to take at least %v, took %v", tt.name, tt.minDuration, d)
+		}
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestProxyDropRate verifies that the proxy drops all connections with drop
+// rate 1 and rejects an invalid drop rate.
+func TestProxyDropRate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	if _, err := New("127.0.0.1:0", "127.0.0.1:1", Config{DropRate: 1.5}); err == nil {
+		t.Fatal("expected invalid drop rate to be rejected")
+	}
+
+	echo := newEchoServer(t)
+	defer func() {
+		if err := echo.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	p, err := New("127.0.0.1:0", echo.Addr().String(), Config{DropRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("hello"))
+	if _, err := conn.Read(make([]byte, 5)); err == nil {
+		t.Fatal("expected dropped connection")
+	}
+}
@@ -0,0 +1,281 @@
+package scenario
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/antfarm"
+	"go.sia.tech/sia-antfarm/persist"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+const (
+	// defaultWaitTimeout defines the default timeout of waiting for a block
+	// height or a condition.
+	defaultWaitTimeout = time.Minute * 10
+
+	// waitFrequency defines how frequently block heights and conditions are
+	// checked.
+	waitFrequency = time.Second
+)
+
+var (
+	// errStopped is returned when the scenario run was stopped.
+	errStopped = errors.New("scenario run was stopped")
+)
+
+type (
+	// StepResult contains the result of a scenario step.
+	StepResult struct {
+		Name     string
+		Start    time.Time
+		Duration time.Duration
+		Error    string `json:",omitempty"`
+	}
+
+	// runner runs a scenario on an antfarm.
+	runner struct {
+		staticFarm     *antfarm.AntFarm
+		staticLogger   *persist.Logger
+		staticStart    time.Time
+		staticStopChan <-chan struct{}
+
+		// files contains files uploaded by the scenario per ant name.
+		files map[string][]ant.RenterFile
+	}
+)
+
+// Run runs the scenario steps in order on the given antfarm until all steps
+// finish, a step fails or stopChan is closed. It returns results of the run
+// steps and the error of the failed step.
+func Run(farm *antfarm.AntFarm, logger *persist.Logger, s Scenario, stopChan <-chan struct{}) ([]StepResult, error) {
+	if err := s.Validate(); err != nil {
+		return nil, errors.AddContext(err, "invalid scenario")
+	}
+	r := &runner{
+		staticFarm:     farm,
+		staticLogger:   logger,
+		staticStart:    time.Now(),
+		staticStopChan: stopChan,
+		files:          make(map[string][]ant.RenterFile),
+	}
+	logger.Printf("starting scenario %v with %v steps", s.Name, len(s.Steps))
+
+	var results []StepResult
+	for i, step := range s.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %v", i)
+		}
+		result := StepResult{Name: name, Start: time.Now()}
+		logger.Printf("scenario %v: starting %v", s.Name, name)
+		err := r.runStep(step)
+		result.Duration = time.Since(result.Start)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			logger.Errorf("scenario %v: %v failed: %v", s.Name, name, err)
+			return results, errors.AddContext(err, fmt.Sprintf("%v failed", name))
+		}
+		results = append(results, result)
+		logger.Printf("scenario %v: %v finished in %v", s.Name, name, result.Duration)
+	}
+	logger.Printf("scenario %v finished", s.Name)
+	return results, nil
+}
+
+// runStep waits for the step trigger, runs the step action and checks the
+// step assertion.
+func (r *runner) runStep(step Step) error {
+	if err := r.wait(step); err != nil {
+		return errors.AddContext(err, "wait failed")
+	}
+	if err := r.act(step); err != nil {
+		return errors.AddContext(err, fmt.Sprintf("%v action failed", step.Action))
+	}
+	if err := r.assert(step); err != nil {
+		return errors.AddContext(err, fmt.Sprintf("%v assertion failed", step.Assertion))
+	}
+	return nil
+}
+
+// sleep sleeps for the given duration, it returns errStopped if the run was
+// stopped.
+func (r *runner) sleep(d time.Duration) error {
+	select {
+	case <-r.staticStopChan:
+		return errStopped
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// waitFor waits until the given check returns true or the timeout is
+// reached. A check error counts as a failed check, so it is retried until the
+// timeout, the last check error is returned with the timeout error.
+func (r *runner) waitFor(timeout time.Duration, check func() (bool, error)) error {
+	start := time.Now()
+	for {
+		ok, err := check()
+		if err == nil && ok {
+			return nil
+		}
+		if time.Since(start) > timeout {
+			return errors.Compose(fmt.Errorf("timeout %v reached", timeout), err)
+		}
+		if err := r.sleep(waitFrequency); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for the step's time, block height and condition triggers.
+func (r *runner) wait(step Step) error {
+	w := step.Wait
+	timeout := defaultWaitTimeout
+	if w.TimeoutSeconds > 0 {
+		timeout = time.Duration(w.TimeoutSeconds) * time.Second
+	}
+
+	// Wait for time since the scenario start
+	if w.Seconds > 0 {
+		at := r.staticStart.Add(time.Duration(w.Seconds) * time.Second)
+		if err := r.sleep(time.Until(at)); err != nil {
+			return err
+		}
+	}
+
+	// Wait for block height
+	if w.BlockHeight > 0 {
+		ants, err := selectAnts(r.staticFarm.GetAnts(), step.Ants)
+		if err != nil {
+			return err
+		}
+		err = r.waitFor(timeout, func() (bool, error) {
+			for _, a := range ants {
+				cg, err := a.StaticClient.ConsensusGet()
+				if err != nil {
+					return false, errors.AddContext(err, "can't get consensus info")
+				}
+				if cg.Height < types.BlockHeight(w.BlockHeight) {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("block height %v not reached", w.BlockHeight))
+		}
+	}
+
+	// Wait for condition
+	switch w.Condition {
+	case ConditionRenterUploadReady:
+		ants, err := selectAnts(r.staticFarm.GetAnts(), step.Ants)
+		if err != nil {
+			return err
+		}
+		err = r.waitFor(timeout, func() (bool, error) {
+			for _, a := range ants {
+				if !a.HasRenterTypeJob() {
+					continue
+				}
+				ready, err := a.RenterUploadReady()
+				if err != nil || !ready {
+					return false, err
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return errors.AddContext(err, "renters aren't upload ready")
+		}
+	case ConditionSynced:
+		if err := r.staticFarm.WaitForAntsToSync(timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// act runs the step action.
+func (r *runner) act(step Step) error {
+	switch step.Action {
+	case ActionNone:
+		return nil
+	case ActionPartition:
+		return r.staticFarm.Partition(step.Groups...)
+	case ActionHeal:
+		return r.staticFarm.Heal()
+	}
+
+	ants, err := selectAnts(r.staticFarm.GetAnts(), step.Ants)
+	if err != nil {
+		return err
+	}
+	for _, a := range ants {
+		var err error
+		switch step.Action {
+		case ActionStop:
+			err = a.Close()
+		case ActionStart:
+			siadPath := step.SiadPath
+			if siadPath == "" {
+				siadPath = a.Config.SiadPath
+			}
+			err = a.StartSiad(siadPath)
+		case ActionUpdateSiad:
+			err = a.UpdateSiad(step.SiadPath)
+		case ActionStartJob:
+			err = a.StartJob(step.Job)
+		case ActionUpload:
+			if !a.HasRenterTypeJob() {
+				continue
+			}
+			jr := a.JobRunner()
+			if jr == nil {
+				return fmt.Errorf("ant %v is not running", a.Config.Name)
+			}
+			renterJob := jr.NewRenterJob()
+			_, err = renterJob.Upload(step.FileSize)
+			if err == nil {
+				r.files[a.Config.Name] = append(r.files[a.Config.Name], renterJob.Files...)
+			}
+		}
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("ant %v", a.Config.Name))
+		}
+	}
+	return nil
+}
+
+// assert checks the step assertion.
+func (r *runner) assert(step Step) error {
+	switch step.Assertion {
+	case AssertSingleConsensusGroup:
+		groups, err := r.staticFarm.ConsensusGroups()
+		if err != nil {
+			return errors.AddContext(err, "can't get consensus groups")
+		}
+		if len(groups) != 1 {
+			return fmt.Errorf("ants are split into %v consensus groups", len(groups))
+		}
+	case AssertFilesDownloadable:
+		ants, err := selectAnts(r.staticFarm.GetAnts(), step.Ants)
+		if err != nil {
+			return err
+		}
+		for _, a := range ants {
+			files := r.files[a.Config.Name]
+			if len(files) == 0 {
+				continue
+			}
+			if err := antfarm.DownloadAndVerifyFiles(r.staticLogger, a, files); err != nil {
+				return errors.AddContext(err, fmt.Sprintf("ant %v", a.Config.Name))
+			}
+		}
+	}
+	return nil
+}
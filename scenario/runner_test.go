@@ -0,0 +1,125 @@
+package scenario
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/antfarm"
+	"go.sia.tech/sia-antfarm/siadmock"
+	"go.sia.tech/sia-antfarm/test"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestRun verifies running a scenario on an antfarm of ants using mock siads.
+// The block height trigger waits for all selected ants and retries failing
+// siad calls.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dataDir := test.TestDir(t.Name())
+	logger := test.NewTestLogger(t, dataDir)
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create an antfarm with two ants, each using its own mock siad
+	var mocks []*siadmock.Server
+	var antConfigs []ant.AntConfig
+	for i := 0; i < 2; i++ {
+		s, err := siadmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		mocks = append(mocks, s)
+		name := ant.NameGeneric(i)
+		antConfigs = append(antConfigs, ant.AntConfig{
+			SiadConfig: ant.SiadConfig{
+				APIAddr:                  s.Address(),
+				DataDir:                  filepath.Join(dataDir, name),
+				AllowHostLocalNetAddress: true,
+				ExternalSiad:             true,
+			},
+			Name: name,
+		})
+	}
+	addr, err := ant.GetAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := antfarm.AntfarmConfig{
+		ListenAddress: "127.0.0.1" + addr,
+		DataDir:       filepath.Join(dataDir, "antfarm-data"),
+		AntConfigs:    antConfigs,
+	}
+	farm, err := antfarm.New(logger, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := farm.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Failing consensus calls are retried by the block height wait
+	mocks[1].SetErrorTimes("GET /consensus", errors.New("injected consensus error"), 3)
+
+	// Run the scenario, the first step waits for both ants to reach the
+	// block height, the second step stops the first ant
+	s := Scenario{
+		Name: "mock",
+		Steps: []Step{
+			{Name: "wait", Wait: Wait{BlockHeight: 3, TimeoutSeconds: 60}},
+			{Name: "stop", Ants: []string{ant.NameGeneric(0)}, Action: ActionStop},
+		},
+	}
+	type runResult struct {
+		results []StepResult
+		err     error
+	}
+	done := make(chan runResult)
+	go func() {
+		results, err := Run(farm, logger, s, make(chan struct{}))
+		done <- runResult{results, err}
+	}()
+
+	// Only one ant reaches the block height
+	mocks[0].MineBlocks(3)
+	select {
+	case r := <-done:
+		t.Fatalf("scenario finished before all ants reached the block height: %+v", r)
+	case <-time.After(waitFrequency * 3):
+	}
+
+	// Both ants reach the block height
+	mocks[1].MineBlocks(3)
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if len(r.results) != 2 {
+			t.Fatalf("expected 2 step results, got %+v", r.results)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("scenario didn't finish")
+	}
+	if n := mocks[0].Requests("GET /daemon/stop"); n != 1 {
+		t.Fatalf("expected the first ant to be stopped once, got %v stop requests", n)
+	}
+	if n := mocks[1].Requests("GET /daemon/stop"); n != 0 {
+		t.Fatalf("expected the second ant not to be stopped, got %v stop requests", n)
+	}
+}
@@ -0,0 +1,238 @@
+// Package scenario implements a declarative scenario engine for antfarm runs.
+// A scenario is a JSON file describing a timeline of steps. Each step
+// optionally waits for a time, block height or condition, then runs an action
+// on the selected ants and finally checks an assertion.
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// Action defines type for step Action enum
+type Action string
+
+// Action constants define values for step Action enum
+const (
+	// ActionNone only waits and checks the assertion.
+	ActionNone Action = ""
+
+	// ActionStop stops the ants' jobs and siad processes.
+	ActionStop Action = "stop"
+
+	// ActionStart starts stopped ants using SiadPath or the ants' current
+	// siad binaries. Starting a running ant fails the step.
+	ActionStart Action = "start"
+
+	// ActionUpdateSiad restarts the ants using the siad binary in SiadPath.
+	ActionUpdateSiad Action = "updateSiad"
+
+	// ActionStartJob starts the job Job on the ants.
+	ActionStartJob Action = "startJob"
+
+	// ActionUpload uploads a file of FileSize bytes from each renter ant.
+	// Uploaded files are checked by the filesDownloadable assertion.
+	ActionUpload Action = "upload"
+
+	// ActionPartition partitions the ants into the partition Groups.
+	ActionPartition Action = "partition"
+
+	// ActionHeal heals the current partition.
+	ActionHeal Action = "heal"
+)
+
+// Assertion defines type for step Assertion enum
+type Assertion string
+
+// Assertion constants define values for step Assertion enum
+const (
+	// AssertNone doesn't check anything.
+	AssertNone Assertion = ""
+
+	// AssertSingleConsensusGroup checks that all ants are in a single
+	// consensus group.
+	AssertSingleConsensusGroup Assertion = "singleConsensusGroup"
+
+	// AssertFilesDownloadable downloads all files uploaded by the scenario
+	// from the ants and verifies their content.
+	AssertFilesDownloadable Assertion = "filesDownloadable"
+)
+
+// Condition defines type for wait Condition enum
+type Condition string
+
+// Condition constants define values for wait Condition enum
+const (
+	// ConditionNone doesn't wait for any condition.
+	ConditionNone Condition = ""
+
+	// ConditionRenterUploadReady waits for the renter ants to be upload
+	// ready.
+	ConditionRenterUploadReady Condition = "renterUploadReady"
+
+	// ConditionSynced waits for all ants to be in a single consensus group.
+	ConditionSynced Condition = "synced"
+)
+
+type (
+	// Scenario describes a timeline of steps run on an antfarm.
+	Scenario struct {
+		Name  string
+		Steps []Step
+	}
+
+	// Step describes a scenario step. The step waits for its Wait trigger,
+	// then runs its Action and then checks its Assertion.
+	Step struct {
+		Name string
+
+		// Ants contains names or name patterns (e.g. "Host-*") of the ants
+		// the step applies to.
+		Ants []string `json:",omitempty"`
+
+		Wait      Wait
+		Action    Action    `json:",omitempty"`
+		Assertion Assertion `json:",omitempty"`
+
+		// Action parameters
+		SiadPath string     `json:",omitempty"`
+		Job      string     `json:",omitempty"`
+		FileSize uint64     `json:",omitempty"`
+		Groups   [][]string `json:",omitempty"`
+	}
+
+	// Wait describes when a step runs. All set triggers are waited for in
+	// order: time, block height and condition.
+	Wait struct {
+		// Seconds waits until the given number of seconds passed since the
+		// scenario start.
+		Seconds uint64 `json:",omitempty"`
+
+		// BlockHeight waits until all of the step's ants (or all ants, if the
+		// step has no ants) reach the given block height.
+		BlockHeight uint64 `json:",omitempty"`
+
+		// Condition waits for the given condition.
+		Condition Condition `json:",omitempty"`
+
+		// TimeoutSeconds defines the maximum time to wait for the block
+		// height and the condition, defaults to 10 minutes.
+		TimeoutSeconds uint64 `json:",omitempty"`
+	}
+)
+
+// Load loads and validates a scenario from the given JSON file.
+func Load(filename string) (Scenario, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Scenario{}, errors.AddContext(err, "can't read scenario file")
+	}
+	var s Scenario
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&s); err != nil {
+		return Scenario{}, errors.AddContext(err, "can't decode scenario file")
+	}
+	if err := s.Validate(); err != nil {
+		return Scenario{}, errors.AddContext(err, "invalid scenario")
+	}
+	return s, nil
+}
+
+// Validate checks that the scenario steps are valid.
+func (s Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("scenario has no steps")
+	}
+	for i, step := range s.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("step %v (%v): %v", i, step.Name, err)
+		}
+	}
+	return nil
+}
+
+// validate checks that the step is valid.
+func (s Step) validate() error {
+	for _, pattern := range s.Ants {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ant name pattern %v: %v", pattern, err)
+		}
+	}
+
+	switch s.Wait.Condition {
+	case ConditionNone, ConditionRenterUploadReady, ConditionSynced:
+	default:
+		return fmt.Errorf("unknown wait condition %v", s.Wait.Condition)
+	}
+
+	needsAnts := true
+	switch s.Action {
+	case ActionNone, ActionHeal:
+		needsAnts = false
+	case ActionStop, ActionStart:
+	case ActionUpdateSiad:
+		if s.SiadPath == "" {
+			return errors.New("updateSiad action requires SiadPath")
+		}
+	case ActionStartJob:
+		if s.Job == "" {
+			return errors.New("startJob action requires Job")
+		}
+		if s.Job == "littlesupplier" {
+			return errors.New("littlesupplier job can't be started by a scenario")
+		}
+	case ActionUpload:
+		if s.FileSize == 0 {
+			return errors.New("upload action requires FileSize")
+		}
+	case ActionPartition:
+		needsAnts = false
+		if len(s.Groups) < 2 {
+			return errors.New("partition action requires at least 2 Groups")
+		}
+	default:
+		return fmt.Errorf("unknown action %v", s.Action)
+	}
+	if needsAnts && len(s.Ants) == 0 {
+		return fmt.Errorf("%v action requires Ants", s.Action)
+	}
+
+	switch s.Assertion {
+	case AssertNone, AssertSingleConsensusGroup, AssertFilesDownloadable:
+	default:
+		return fmt.Errorf("unknown assertion %v", s.Assertion)
+	}
+	return nil
+}
+
+// selectAnts returns the ants matching any of the given name patterns. If
+// there are no patterns, all ants are returned.
+func selectAnts(ants []*ant.Ant, patterns []string) ([]*ant.Ant, error) {
+	if len(patterns) == 0 {
+		return ants, nil
+	}
+	var selected []*ant.Ant
+	for _, a := range ants {
+		for _, pattern := range patterns {
+			match, err := path.Match(pattern, a.Config.Name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ant name pattern %v: %v", pattern, err)
+			}
+			if match {
+				selected = append(selected, a)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no ant matches %v", patterns)
+	}
+	return selected, nil
+}
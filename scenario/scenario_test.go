@@ -0,0 +1,138 @@
+package scenario
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.sia.tech/sia-antfarm/ant"
+	"go.sia.tech/sia-antfarm/test"
+)
+
+// TestLoad verifies loading scenario files.
+func TestLoad(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := test.TestDir(t.Name())
+	tests := []struct {
+		name  string
+		json  string
+		valid bool
+	}{
+		{"valid", `{"Name": "s", "Steps": [{"Wait": {"Condition": "synced"}, "Action": "stop", "Ants": ["Host-*"], "Assertion": "singleConsensusGroup"}]}`, true},
+		{"unknown field", `{"Name": "s", "Steps": [{"Action": "heal", "Foo": 1}]}`, false},
+		{"no steps", `{"Name": "s"}`, false},
+		{"invalid step", `{"Name": "s", "Steps": [{"Action": "explode"}]}`, false},
+		{"invalid json", `{"Name": `, false},
+	}
+	for i, tt := range tests {
+		filename := filepath.Join(dir, tt.name+".json")
+		if err := ioutil.WriteFile(filename, []byte(tt.json), 0600); err != nil {
+			t.Fatal(err)
+		}
+		s, err := Load(filename)
+		if tt.valid && err != nil {
+			t.Errorf("test %v (%v): unexpected error: %v", i, tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("test %v (%v): expected error", i, tt.name)
+		}
+		if tt.valid && (len(s.Steps) != 1 || s.Steps[0].Action != ActionStop) {
+			t.Errorf("test %v (%v): unexpected scenario %+v", i, tt.name, s)
+		}
+	}
+
+	// Missing file
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected error loading a missing file")
+	}
+}
+
+// TestStepValidate verifies scenario step validation.
+func TestStepValidate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ants := []string{"Renter-*"}
+	tests := []struct {
+		name  string
+		step  Step
+		valid bool
+	}{
+		{"wait only", Step{Wait: Wait{Seconds: 10}}, true},
+		{"heal", Step{Action: ActionHeal}, true},
+		{"stop", Step{Action: ActionStop, Ants: ants}, true},
+		{"stop without ants", Step{Action: ActionStop}, false},
+		{"update siad", Step{Action: ActionUpdateSiad, Ants: ants, SiadPath: "siad"}, true},
+		{"update siad without path", Step{Action: ActionUpdateSiad, Ants: ants}, false},
+		{"start job", Step{Action: ActionStartJob, Ants: ants, Job: "gateway"}, true},
+		{"start job without job", Step{Action: ActionStartJob, Ants: ants}, false},
+		{"start little supplier", Step{Action: ActionStartJob, Ants: ants, Job: "littlesupplier"}, false},
+		{"upload", Step{Action: ActionUpload, Ants: ants, FileSize: 1}, true},
+		{"upload without size", Step{Action: ActionUpload, Ants: ants}, false},
+		{"partition", Step{Action: ActionPartition, Groups: [][]string{{"a"}, {"b"}}}, true},
+		{"partition single group", Step{Action: ActionPartition, Groups: [][]string{{"a"}}}, false},
+		{"unknown action", Step{Action: "explode"}, false},
+		{"unknown condition", Step{Wait: Wait{Condition: "never"}}, false},
+		{"unknown assertion", Step{Assertion: "everythingFine"}, false},
+		{"invalid pattern", Step{Action: ActionStop, Ants: []string{"["}}, false},
+	}
+	for _, tt := range tests {
+		err := tt.step.validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("%v: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+// TestSelectAnts verifies selecting ants by name patterns.
+func TestSelectAnts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	var ants []*ant.Ant
+	for _, name := range []string{"Host-0", "Host-1", "Renter-0", "Miner-0"} {
+		a := &ant.Ant{}
+		a.Config.Name = name
+		ants = append(ants, a)
+	}
+	names := func(ants []*ant.Ant) string {
+		var names []string
+		for _, a := range ants {
+			names = append(names, a.Config.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		patterns []string
+		expected string
+	}{
+		{nil, "Host-0,Host-1,Renter-0,Miner-0"},
+		{[]string{"Host-*"}, "Host-0,Host-1"},
+		{[]string{"Renter-0", "Miner-*"}, "Renter-0,Miner-0"},
+		{[]string{"*-0"}, "Host-0,Renter-0,Miner-0"},
+	}
+	for _, tt := range tests {
+		selected, err := selectAnts(ants, tt.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(selected); got != tt.expected {
+			t.Errorf("patterns %v: expected %v, got %v", tt.patterns, tt.expected, got)
+		}
+	}
+
+	// No match
+	if _, err := selectAnts(ants, []string{"Generic-*"}); err == nil {
+		t.Fatal("expected error when no ant matches")
+	}
+}
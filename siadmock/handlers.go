@@ -0,0 +1,639 @@
+package siadmock
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/node/api"
+	"go.sia.tech/siad/node/api/client"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/encoding"
+	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+const (
+	// defaultDataPieces and defaultParityPieces define the erasure coding of
+	// uploads without data and parity pieces.
+	defaultDataPieces   = 10
+	defaultParityPieces = 20
+)
+
+// newRouter constructs the mock siad API router.
+func (s *Server) newRouter() *httprouter.Router {
+	router := httprouter.New()
+
+	// Consensus
+	router.GET("/consensus", s.handle(s.consensusHandler))
+	router.GET("/consensus/blocks", s.handle(s.consensusBlocksHandler))
+
+	// Daemon
+	router.GET("/daemon/stop", s.handle(s.daemonStopHandler))
+	router.GET("/daemon/version", s.handle(s.daemonVersionHandler))
+
+	// Gateway
+	router.GET("/gateway", s.handle(s.gatewayHandler))
+	router.POST("/gateway/connect/:netaddress", s.handle(s.gatewayConnectHandler))
+	router.POST("/gateway/disconnect/:netaddress", s.handle(s.gatewayDisconnectHandler))
+
+	// Host
+	router.GET("/host", s.handle(s.hostHandlerGET))
+	router.POST("/host", s.handle(s.hostHandlerPOST))
+	router.POST("/host/announce", s.handle(s.hostAnnounceHandler))
+	router.POST("/host/storage/folders/add", s.handle(s.storageFoldersAddHandler))
+
+	// Host DB
+	router.GET("/hostdb/active", s.handle(s.hostdbActiveHandler))
+	router.GET("/hostdb/all", s.handle(s.hostdbAllHandler))
+	router.GET("/hostdb/hosts/:pubkey", s.handle(s.hostdbHostsHandler))
+
+	// Miner
+	router.GET("/miner/start", s.handle(s.minerStartHandler))
+	router.GET("/miner/stop", s.handle(s.minerStopHandler))
+
+	// Renter
+	router.GET("/renter", s.handle(s.renterHandlerGET))
+	router.POST("/renter", s.handle(s.renterHandlerPOST))
+	router.GET("/renter/contracts", s.handle(s.renterContractsHandler))
+	router.POST("/renter/delete/*siapath", s.handle(s.renterDeleteHandler))
+	router.GET("/renter/download/*siapath", s.handle(s.renterDownloadHandler))
+	router.GET("/renter/downloads", s.handle(s.renterDownloadsHandler))
+	router.GET("/renter/files", s.handle(s.renterFilesHandler))
+	router.POST("/renter/upload/*siapath", s.handle(s.renterUploadHandler))
+	router.GET("/renter/uploadready", s.handle(s.renterUploadReadyHandler))
+	router.GET("/renter/workers", s.handle(s.renterWorkersHandler))
+
+	// Wallet
+	router.GET("/wallet", s.handle(s.walletHandler))
+	router.GET("/wallet/address", s.handle(s.walletAddressHandler))
+	router.GET("/wallet/addresses", s.handle(s.walletAddressesHandler))
+	router.POST("/wallet/init", s.handle(s.walletInitHandler))
+	router.POST("/wallet/init/seed", s.handle(s.walletInitSeedHandler))
+	router.GET("/wallet/seeds", s.handle(s.walletSeedsHandler))
+	router.POST("/wallet/siacoins", s.handle(s.walletSiacoinsHandler))
+	router.POST("/wallet/unlock", s.handle(s.walletUnlockHandler))
+	return router
+}
+
+// handle wraps the given handler, so that it returns the error injected into
+// the request's route instead of its response. The wrapped handler is called
+// with the mock's state locked.
+func (s *Server) handle(h func(http.ResponseWriter, *http.Request, httprouter.Params)) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if err := s.managedInjectedError(req.Method, req.URL.Path); err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, req, ps)
+	}
+}
+
+// writeError writes the error in the siad API error format, so that the siad
+// client returns it.
+func writeError(w http.ResponseWriter, err error, code int) {
+	api.WriteError(w, api.Error{Message: err.Error()}, code)
+}
+
+// consensusHandler handles GET /consensus.
+func (s *Server) consensusHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	b := s.state.Blocks[len(s.state.Blocks)-1]
+	api.WriteJSON(w, api.ConsensusGET{
+		Synced:         true,
+		Height:         b.Height,
+		CurrentBlock:   b.ID,
+		BlockFrequency: 1,
+	})
+}
+
+// consensusBlocksHandler handles GET /consensus/blocks.
+func (s *Server) consensusBlocksHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	height, err := strconv.ParseUint(req.FormValue("height"), 10, 64)
+	if err != nil {
+		writeError(w, errors.AddContext(err, "can't parse block height"), http.StatusBadRequest)
+		return
+	}
+	if height >= uint64(len(s.state.Blocks)) {
+		writeError(w, errors.New("block height out of range"), http.StatusBadRequest)
+		return
+	}
+	api.WriteJSON(w, s.state.Blocks[height])
+}
+
+// daemonStopHandler handles GET /daemon/stop. The mock keeps serving its API,
+// so that ants can be started against it again.
+func (s *Server) daemonStopHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteSuccess(w)
+}
+
+// daemonVersionHandler handles GET /daemon/version.
+func (s *Server) daemonVersionHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Version)
+}
+
+// gatewayHandler handles GET /gateway.
+func (s *Server) gatewayHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Gateway)
+}
+
+// gatewayConnectHandler handles POST /gateway/connect/:netaddress.
+func (s *Server) gatewayConnectHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	addr := modules.NetAddress(ps.ByName("netaddress"))
+	for _, p := range s.state.Gateway.Peers {
+		if p.NetAddress == addr {
+			writeError(w, client.ErrPeerExists, http.StatusBadRequest)
+			return
+		}
+	}
+	s.state.Gateway.Peers = append(s.state.Gateway.Peers, modules.Peer{NetAddress: addr, Version: s.state.Version.Version})
+	api.WriteSuccess(w)
+}
+
+// gatewayDisconnectHandler handles POST /gateway/disconnect/:netaddress.
+func (s *Server) gatewayDisconnectHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	addr := modules.NetAddress(ps.ByName("netaddress"))
+	peers := s.state.Gateway.Peers
+	for i, p := range peers {
+		if p.NetAddress == addr {
+			s.state.Gateway.Peers = append(peers[:i:i], peers[i+1:]...)
+			api.WriteSuccess(w)
+			return
+		}
+	}
+	writeError(w, errors.New("not connected to that node"), http.StatusBadRequest)
+}
+
+// hostHandlerGET handles GET /host.
+func (s *Server) hostHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Host)
+}
+
+// hostHandlerPOST handles POST /host. The acceptingcontracts and netaddress
+// settings are applied, other settings are ignored.
+func (s *Server) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := req.ParseForm(); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	h := &s.state.Host
+	if v := req.PostForm.Get("acceptingcontracts"); v != "" {
+		accepting, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, errors.AddContext(err, "can't parse acceptingcontracts"), http.StatusBadRequest)
+			return
+		}
+		h.InternalSettings.AcceptingContracts = accepting
+		h.ExternalSettings.AcceptingContracts = accepting
+	}
+	if v := req.PostForm.Get("netaddress"); v != "" {
+		h.InternalSettings.NetAddress = modules.NetAddress(v)
+		h.ExternalSettings.NetAddress = modules.NetAddress(v)
+	}
+	api.WriteSuccess(w)
+}
+
+// hostAnnounceHandler handles POST /host/announce. The signed announcement is
+// added to the unconfirmed transactions.
+func (s *Server) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	addr := modules.NetAddress(req.FormValue("netaddress"))
+	if addr == "" {
+		addr = s.state.Host.ExternalSettings.NetAddress
+	}
+	if addr == "" {
+		writeError(w, errors.New("host doesn't have a net address"), http.StatusBadRequest)
+		return
+	}
+
+	// The announcement is signed like by modules.CreateAnnouncement, which
+	// rejects the loopback addresses of local ants
+	ha := modules.HostAnnouncement{
+		Specifier:  modules.PrefixHostAnnouncement,
+		NetAddress: addr,
+		PublicKey:  s.staticHostPK,
+	}
+	sig := crypto.SignHash(crypto.HashObject(ha), s.staticHostSK)
+	ann := append(encoding.Marshal(ha), sig[:]...)
+	s.state.Transactions = append(s.state.Transactions, types.Transaction{ArbitraryData: [][]byte{ann}})
+	api.WriteSuccess(w)
+}
+
+// storageFoldersAddHandler handles POST /host/storage/folders/add.
+func (s *Server) storageFoldersAddHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	size, err := strconv.ParseUint(req.FormValue("size"), 10, 64)
+	if err != nil {
+		writeError(w, errors.AddContext(err, "can't parse storage folder size"), http.StatusBadRequest)
+		return
+	}
+	h := &s.state.Host
+	s.state.StorageFolders = append(s.state.StorageFolders, modules.StorageFolderMetadata{
+		Capacity:          size,
+		CapacityRemaining: size,
+		Index:             uint16(len(s.state.StorageFolders)),
+		Path:              req.FormValue("path"),
+	})
+	h.ExternalSettings.TotalStorage += size
+	h.ExternalSettings.RemainingStorage += size
+	api.WriteSuccess(w)
+}
+
+// hostdbActiveHandler handles GET /hostdb/active.
+func (s *Server) hostdbActiveHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	hosts := []api.ExtendedHostDBEntry{}
+	for _, h := range s.state.HostDB {
+		if h.AcceptingContracts {
+			hosts = append(hosts, h)
+		}
+	}
+	api.WriteJSON(w, api.HostdbActiveGET{Hosts: hosts})
+}
+
+// hostdbAllHandler handles GET /hostdb/all.
+func (s *Server) hostdbAllHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, api.HostdbAllGET{Hosts: s.state.HostDB})
+}
+
+// hostdbHostsHandler handles GET /hostdb/hosts/:pubkey.
+func (s *Server) hostdbHostsHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	for _, h := range s.state.HostDB {
+		if h.PublicKeyString == ps.ByName("pubkey") {
+			api.WriteJSON(w, api.HostdbHostsGET{Entry: h})
+			return
+		}
+	}
+	writeError(w, errors.New("requested host does not exist"), http.StatusBadRequest)
+}
+
+// minerStartHandler handles GET /miner/start.
+func (s *Server) minerStartHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	s.state.Mining = true
+	api.WriteSuccess(w)
+}
+
+// minerStopHandler handles GET /miner/stop.
+func (s *Server) minerStopHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	s.state.Mining = false
+	api.WriteSuccess(w)
+}
+
+// renterHandlerGET handles GET /renter.
+func (s *Server) renterHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Renter)
+}
+
+// renterHandlerPOST handles POST /renter. The allowance's funds, hosts,
+// period and renew window and the IP violation check are applied, other
+// settings are ignored.
+func (s *Server) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := req.ParseForm(); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	settings := s.state.Renter.Settings
+	if v := req.PostForm.Get("funds"); v != "" {
+		if _, err := fmt.Sscan(v, &settings.Allowance.Funds); err != nil {
+			writeError(w, errors.AddContext(err, "can't parse funds"), http.StatusBadRequest)
+			return
+		}
+	}
+	uints := []struct {
+		param string
+		value *uint64
+	}{
+		{"hosts", &settings.Allowance.Hosts},
+		{"period", (*uint64)(&settings.Allowance.Period)},
+		{"renewwindow", (*uint64)(&settings.Allowance.RenewWindow)},
+	}
+	for _, u := range uints {
+		v := req.PostForm.Get(u.param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, errors.AddContext(err, "can't parse "+u.param), http.StatusBadRequest)
+			return
+		}
+		*u.value = n
+	}
+	if v := req.PostForm.Get("checkforipviolation"); v != "" {
+		check, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, errors.AddContext(err, "can't parse checkforipviolation"), http.StatusBadRequest)
+			return
+		}
+		settings.IPViolationCheck = check
+	}
+	s.state.Renter.Settings = settings
+	api.WriteSuccess(w)
+}
+
+// renterContractsHandler handles GET /renter/contracts.
+func (s *Server) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Contracts)
+}
+
+// renterDeleteHandler handles POST /renter/delete/*siapath.
+func (s *Server) renterDeleteHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	i, err := s.fileIndex(ps)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	files := s.state.Files
+	s.state.Files = append(files[:i:i], files[i+1:]...)
+	api.WriteSuccess(w)
+}
+
+// renterDownloadHandler handles GET /renter/download/*siapath. The download
+// completes immediately by copying the file's local path to the destination,
+// unless the state's DownloadError is set.
+func (s *Server) renterDownloadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	i, err := s.fileIndex(ps)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	f := s.state.Files[i]
+	now := time.Now()
+	d := api.DownloadInfo{
+		Destination:     req.FormValue("destination"),
+		DestinationType: "file",
+		Filesize:        f.Filesize,
+		Length:          f.Filesize,
+		SiaPath:         f.SiaPath,
+		StartTime:       now,
+		StartTimeUnix:   now.Unix(),
+	}
+	if s.state.DownloadError != "" {
+		d.Error = s.state.DownloadError
+	} else if err := copyFile(f.LocalPath, d.Destination); err != nil {
+		d.Error = err.Error()
+	} else {
+		d.Completed = true
+		d.EndTime = time.Now()
+		d.Received = f.Filesize
+		d.TotalDataTransferred = f.Filesize
+	}
+
+	// The latest downloads are listed first
+	s.state.Downloads = append([]api.DownloadInfo{d}, s.state.Downloads...)
+	var id types.Specifier
+	fastrand.Read(id[:])
+	w.Header().Set("ID", id.String())
+	api.WriteSuccess(w)
+}
+
+// renterDownloadsHandler handles GET /renter/downloads.
+func (s *Server) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, api.RenterDownloadQueue{Downloads: s.state.Downloads})
+}
+
+// renterFilesHandler handles GET /renter/files.
+func (s *Server) renterFilesHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, api.RenterFiles{Files: s.state.Files})
+}
+
+// renterUploadHandler handles POST /renter/upload/*siapath. The upload
+// completes immediately.
+func (s *Server) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		writeError(w, errors.AddContext(err, "can't parse siapath"), http.StatusBadRequest)
+		return
+	}
+	source := req.FormValue("source")
+	fi, err := os.Stat(source)
+	if err != nil {
+		writeError(w, errors.AddContext(err, "can't stat upload source"), http.StatusBadRequest)
+		return
+	}
+	dataPieces, parityPieces := uint64(defaultDataPieces), uint64(defaultParityPieces)
+	if v := req.FormValue("datapieces"); v != "" {
+		if dataPieces, err = strconv.ParseUint(v, 10, 64); err != nil || dataPieces == 0 {
+			writeError(w, errors.New("invalid data pieces"), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := req.FormValue("paritypieces"); v != "" {
+		if parityPieces, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, errors.New("invalid parity pieces"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Replace an existing file only if forced
+	for i, f := range s.state.Files {
+		if f.SiaPath.Equals(siaPath) {
+			if req.FormValue("force") != "true" {
+				writeError(w, errors.New("a file already exists at that location"), http.StatusBadRequest)
+				return
+			}
+			s.state.Files = append(s.state.Files[:i:i], s.state.Files[i+1:]...)
+			break
+		}
+	}
+	now := time.Now()
+	s.state.Files = append(s.state.Files, modules.FileInfo{
+		AccessTime:       now,
+		Available:        true,
+		ChangeTime:       now,
+		CreateTime:       now,
+		Filesize:         uint64(fi.Size()),
+		LocalPath:        source,
+		MaxHealthPercent: 100,
+		ModificationTime: now,
+		OnDisk:           true,
+		Recoverable:      true,
+		Redundancy:       float64(dataPieces+parityPieces) / float64(dataPieces),
+		SiaPath:          siaPath,
+		UploadedBytes:    uint64(fi.Size()),
+		UploadProgress:   100,
+	})
+	api.WriteSuccess(w)
+}
+
+// renterUploadReadyHandler handles GET /renter/uploadready.
+func (s *Server) renterUploadReadyHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.UploadReady)
+}
+
+// renterWorkersHandler handles GET /renter/workers.
+func (s *Server) renterWorkersHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Workers)
+}
+
+// walletHandler handles GET /wallet.
+func (s *Server) walletHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, s.state.Wallet)
+}
+
+// walletAddressHandler handles GET /wallet/address.
+func (s *Server) walletAddressHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if !s.state.Wallet.Unlocked {
+		writeError(w, modules.ErrLockedWallet, http.StatusBadRequest)
+		return
+	}
+	var uh types.UnlockHash
+	fastrand.Read(uh[:])
+	s.state.Addresses = append(s.state.Addresses, uh)
+	api.WriteJSON(w, api.WalletAddressGET{Address: uh})
+}
+
+// walletAddressesHandler handles GET /wallet/addresses.
+func (s *Server) walletAddressesHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, api.WalletAddressesGET{Addresses: s.state.Addresses})
+}
+
+// walletInitHandler handles POST /wallet/init. A random primary seed is
+// generated.
+func (s *Server) walletInitHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var seed modules.Seed
+	fastrand.Read(seed[:])
+	seedStr, err := modules.SeedToString(seed, mnemonics.English)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := s.initWallet(req, seedStr); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	api.WriteJSON(w, api.WalletInitPOST{PrimarySeed: seedStr})
+}
+
+// walletInitSeedHandler handles POST /wallet/init/seed.
+func (s *Server) walletInitSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	seedStr := req.FormValue("seed")
+	if _, err := modules.StringToSeed(seedStr, mnemonics.English); err != nil {
+		writeError(w, errors.AddContext(err, "can't parse seed"), http.StatusBadRequest)
+		return
+	}
+	if err := s.initWallet(req, seedStr); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	api.WriteSuccess(w)
+}
+
+// walletSeedsHandler handles GET /wallet/seeds.
+func (s *Server) walletSeedsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if !s.state.Wallet.Unlocked {
+		writeError(w, modules.ErrLockedWallet, http.StatusBadRequest)
+		return
+	}
+	api.WriteJSON(w, api.WalletSeedsGET{
+		PrimarySeed: s.state.PrimarySeed,
+		AllSeeds:    []string{s.state.PrimarySeed},
+	})
+}
+
+// walletSiacoinsHandler handles POST /wallet/siacoins. The transfer is added
+// to the unconfirmed transactions.
+func (s *Server) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	wallet := &s.state.Wallet
+	if !wallet.Unlocked {
+		writeError(w, modules.ErrLockedWallet, http.StatusBadRequest)
+		return
+	}
+	var amount types.Currency
+	if _, err := fmt.Sscan(req.FormValue("amount"), &amount); err != nil {
+		writeError(w, errors.AddContext(err, "can't parse amount"), http.StatusBadRequest)
+		return
+	}
+	var dest types.UnlockHash
+	if err := dest.LoadString(req.FormValue("destination")); err != nil {
+		writeError(w, errors.AddContext(err, "can't parse destination"), http.StatusBadRequest)
+		return
+	}
+	spendable := subOrZero(wallet.ConfirmedSiacoinBalance, wallet.UnconfirmedOutgoingSiacoins)
+	if spendable.Cmp(amount) < 0 {
+		writeError(w, modules.ErrLowBalance, http.StatusBadRequest)
+		return
+	}
+
+	// A random nonce keeps the IDs of equal transfers unique
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: amount, UnlockHash: dest}},
+		ArbitraryData:  [][]byte{fastrand.Bytes(16)},
+	}
+	s.state.Transactions = append(s.state.Transactions, txn)
+	wallet.UnconfirmedOutgoingSiacoins = wallet.UnconfirmedOutgoingSiacoins.Add(amount)
+	if s.ownsAddress(dest) {
+		wallet.UnconfirmedIncomingSiacoins = wallet.UnconfirmedIncomingSiacoins.Add(amount)
+	}
+	api.WriteJSON(w, api.WalletSiacoinsPOST{
+		Transactions:   []types.Transaction{txn},
+		TransactionIDs: []types.TransactionID{txn.ID()},
+	})
+}
+
+// walletUnlockHandler handles POST /wallet/unlock.
+func (s *Server) walletUnlockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if !s.state.Wallet.Encrypted {
+		writeError(w, errors.New("wallet has not been encrypted yet"), http.StatusBadRequest)
+		return
+	}
+	if req.FormValue("encryptionpassword") != s.state.EncryptionPassword {
+		writeError(w, modules.ErrBadEncryptionKey, http.StatusBadRequest)
+		return
+	}
+	s.state.Wallet.Unlocked = true
+	api.WriteSuccess(w)
+}
+
+// fileIndex returns the index of the renter file with the siapath of the
+// request.
+func (s *Server) fileIndex(ps httprouter.Params) (int, error) {
+	siaPath, err := modules.NewSiaPath(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		return 0, errors.AddContext(err, "can't parse siapath")
+	}
+	for i, f := range s.state.Files {
+		if f.SiaPath.Equals(siaPath) {
+			return i, nil
+		}
+	}
+	return 0, errors.New("no file known with that path")
+}
+
+// initWallet encrypts the wallet with the given primary seed. Without an
+// encryption password the wallet is encrypted with the primary seed like by
+// siad.
+func (s *Server) initWallet(req *http.Request, seed string) error {
+	if s.state.Wallet.Encrypted && req.FormValue("force") != "true" {
+		return errors.New("wallet is already encrypted, cannot encrypt again")
+	}
+	password := req.FormValue("encryptionpassword")
+	if password == "" {
+		password = seed
+	}
+	s.state.Wallet.Encrypted = true
+	s.state.Wallet.Unlocked = false
+	s.state.PrimarySeed = seed
+	s.state.EncryptionPassword = password
+	return nil
+}
+
+// copyFile copies the file at the source path to the destination path.
+func copyFile(source, dest string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return errors.AddContext(err, "can't open download source")
+	}
+	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Compose(errors.AddContext(err, "can't create download destination"), src.Close())
+	}
+	_, err = io.Copy(dst, src)
+	return errors.Compose(err, dst.Close(), src.Close())
+}
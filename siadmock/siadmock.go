@@ -0,0 +1,379 @@
+// Package siadmock implements an in-process mock of the subset of the siad
+// API used by the ants' jobs. The mock's state is scriptable and errors can be
+// injected into its endpoints, so that job logic can be tested in milliseconds
+// without a siad binary and without mining.
+package siadmock
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/node/api"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+const (
+	// miningBlockFrequency defines how often the mock mines a block while
+	// its miner is started.
+	miningBlockFrequency = time.Millisecond * 50
+
+	// mockVersion defines the siad version reported by the mock.
+	mockVersion = "1.5.7-mock"
+)
+
+var (
+	// MinerPayout defines the Siacoins the mock's wallet receives for each
+	// block mined while the mock's miner is started.
+	MinerPayout = types.SiacoinPrecision.Mul64(300e3)
+)
+
+// State is the scriptable state of the mock siad. Endpoints read and update
+// it, tests can inspect it by Server.State and script it by Server.Update.
+type State struct {
+	// Blocks contains the blocks of the mock's blockchain, the block at index
+	// i has height i. Transactions contains the unconfirmed transactions
+	// included in the next mined block.
+	Blocks       []api.ConsensusBlocksGet
+	Transactions []types.Transaction
+
+	// Wallet is the mock's wallet, PrimarySeed and EncryptionPassword are
+	// set when the wallet is initialized. Addresses are the addresses
+	// generated by the wallet.
+	Wallet             api.WalletGET
+	PrimarySeed        string
+	EncryptionPassword string
+	Addresses          []types.UnlockHash
+
+	// Mining defines whether the mock's miner is started.
+	Mining bool
+
+	// Host is the mock's host, StorageFolders are its storage folders.
+	Host           api.HostGET
+	StorageFolders []modules.StorageFolderMetadata
+
+	// HostDB contains the hosts known to the renter, hosts announced in
+	// mined blocks are added to it.
+	HostDB []api.ExtendedHostDBEntry
+
+	// Renter is the mock's renter. Uploaded files complete immediately.
+	// Downloads complete immediately by copying the file's local path to the
+	// download destination, unless DownloadError is set, then the downloads
+	// report the error.
+	Renter        api.RenterGET
+	Contracts     api.RenterContracts
+	Files         []modules.FileInfo
+	Downloads     []api.DownloadInfo
+	DownloadError string
+	UploadReady   api.RenterUploadReadyGet
+	Workers       modules.WorkerPoolStatus
+
+	// Gateway is the mock's gateway.
+	Gateway api.GatewayGET
+
+	// Version is the siad version reported by the mock.
+	Version api.DaemonVersionGet
+}
+
+// Server serves the mock siad API on a local address.
+type Server struct {
+	staticListener net.Listener
+	staticServer   *http.Server
+
+	// staticHostSK and staticHostPK are the keys host announcements are
+	// signed with.
+	staticHostSK crypto.SecretKey
+	staticHostPK types.SiaPublicKey
+
+	// errors contains the errors injected into the routes, requests contains
+	// the number of received requests by "METHOD /path".
+	errors   map[string]*injectedError
+	requests map[string]int
+
+	state State
+	mu    sync.Mutex
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// injectedError is an error returned by a route instead of its response.
+type injectedError struct {
+	err error
+
+	// remaining is the number of requests the error is returned for, a
+	// negative number means until the error is cleared.
+	remaining int
+}
+
+// New starts a new mock siad serving its API on a random local port. The
+// mock starts with a genesis block, an uninitialized wallet and an offline
+// host.
+func New() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.AddContext(err, "can't listen on mock siad address")
+	}
+	sk, pk := crypto.GenerateKeyPair()
+	s := &Server{
+		staticListener: l,
+		staticHostSK:   sk,
+		staticHostPK:   types.Ed25519PublicKey(pk),
+		errors:         make(map[string]*injectedError),
+		requests:       make(map[string]int),
+		stopChan:       make(chan struct{}),
+	}
+	s.state.Blocks = []api.ConsensusBlocksGet{{ID: randomBlockID(), Timestamp: types.CurrentTimestamp()}}
+	s.state.Host.PublicKey = s.staticHostPK
+	s.state.Gateway.NetAddress = modules.NetAddress(l.Addr().String())
+	s.state.Gateway.Online = true
+	s.state.Version = api.DaemonVersionGet{Version: mockVersion}
+	s.staticServer = &http.Server{Handler: s.newRouter()}
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		_ = s.staticServer.Serve(l)
+	}()
+	go s.threadedMine()
+	return s, nil
+}
+
+// Address returns the address the mock siad API is served on.
+func (s *Server) Address() string {
+	return s.staticListener.Addr().String()
+}
+
+// Close stops serving the mock siad API.
+func (s *Server) Close() error {
+	close(s.stopChan)
+	err := s.staticServer.Close()
+	s.wg.Wait()
+	return err
+}
+
+// State returns a copy of the mock's current state. Nested slices are shared
+// with the mock, so they must not be modified, use Update instead.
+func (s *Server) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state
+	st.Blocks = append([]api.ConsensusBlocksGet{}, st.Blocks...)
+	st.Transactions = append([]types.Transaction{}, st.Transactions...)
+	st.Addresses = append([]types.UnlockHash{}, st.Addresses...)
+	st.StorageFolders = append([]modules.StorageFolderMetadata{}, st.StorageFolders...)
+	st.HostDB = append([]api.ExtendedHostDBEntry{}, st.HostDB...)
+	st.Files = append([]modules.FileInfo{}, st.Files...)
+	st.Downloads = append([]api.DownloadInfo{}, st.Downloads...)
+	return st
+}
+
+// Update calls the given function with the mock's state, the function can
+// modify the state.
+func (s *Server) Update(f func(*State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(&s.state)
+}
+
+// SetError makes the requests matching the route fail with the given error
+// until the error is cleared by setting a nil error. A route is a method and
+// a path, e.g. "POST /host/announce", it matches the requests to the path and
+// to its subpaths, e.g. "GET /renter/download" matches the downloads of all
+// files.
+func (s *Server) SetError(route string, err error) {
+	s.SetErrorTimes(route, err, -1)
+}
+
+// SetErrorTimes makes the next n requests matching the route fail with the
+// given error, a negative n means until the error is cleared.
+func (s *Server) SetErrorTimes(route string, err error, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil || n == 0 {
+		delete(s.errors, route)
+		return
+	}
+	s.errors[route] = &injectedError{err: err, remaining: n}
+}
+
+// Requests returns the number of requests the mock received which match the
+// route.
+func (s *Server) Requests(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	for r, count := range s.requests {
+		if routeMatches(route, r) {
+			n += count
+		}
+	}
+	return n
+}
+
+// MineBlocks mines n blocks. The first block confirms the unconfirmed
+// transactions.
+func (s *Server) MineBlocks(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.mineBlock(types.ZeroCurrency)
+	}
+}
+
+// Reorg replaces the last n blocks with n new empty blocks. The transactions
+// of the replaced blocks, e.g. host announcements, are dropped from the
+// blockchain, wallet balances aren't changed.
+func (s *Server) Reorg(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n >= len(s.state.Blocks) {
+		return errors.New("can't reorg the genesis block")
+	}
+	s.state.Blocks = s.state.Blocks[:len(s.state.Blocks)-n]
+	pending := s.state.Transactions
+	s.state.Transactions = nil
+	for i := 0; i < n; i++ {
+		s.mineBlock(types.ZeroCurrency)
+	}
+	s.state.Transactions = pending
+	return nil
+}
+
+// threadedMine mines blocks paying out to the mock's wallet while the mock's
+// miner is started.
+func (s *Server) threadedMine() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(miningBlockFrequency):
+		}
+		s.mu.Lock()
+		if s.state.Mining {
+			s.mineBlock(MinerPayout)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// mineBlock adds a block with the unconfirmed transactions to the blockchain
+// and adds the payout to the wallet's confirmed balance. Confirmed transfers
+// update the wallet's balances and confirmed host announcements are added to
+// the host DB.
+func (s *Server) mineBlock(payout types.Currency) {
+	st := &s.state
+	parent := st.Blocks[len(st.Blocks)-1]
+	b := api.ConsensusBlocksGet{
+		ID:        randomBlockID(),
+		Height:    parent.Height + 1,
+		ParentID:  parent.ID,
+		Timestamp: types.CurrentTimestamp(),
+	}
+	w := &st.Wallet
+	for _, txn := range st.Transactions {
+		bt := api.ConsensusBlocksGetTxn{
+			ID:            txn.ID(),
+			ArbitraryData: txn.ArbitraryData,
+		}
+		for i, sco := range txn.SiacoinOutputs {
+			bt.SiacoinOutputs = append(bt.SiacoinOutputs, api.ConsensusBlocksGetSiacoinOutput{
+				ID:         txn.SiacoinOutputID(uint64(i)),
+				Value:      sco.Value,
+				UnlockHash: sco.UnlockHash,
+			})
+			w.ConfirmedSiacoinBalance = subOrZero(w.ConfirmedSiacoinBalance, sco.Value)
+			w.UnconfirmedOutgoingSiacoins = subOrZero(w.UnconfirmedOutgoingSiacoins, sco.Value)
+			if s.ownsAddress(sco.UnlockHash) {
+				w.ConfirmedSiacoinBalance = w.ConfirmedSiacoinBalance.Add(sco.Value)
+				w.UnconfirmedIncomingSiacoins = subOrZero(w.UnconfirmedIncomingSiacoins, sco.Value)
+			}
+		}
+		for _, arb := range txn.ArbitraryData {
+			if addr, pk, err := modules.DecodeAnnouncement(arb); err == nil {
+				s.addHost(addr, pk)
+			}
+		}
+		b.Transactions = append(b.Transactions, bt)
+	}
+	st.Transactions = nil
+	st.Blocks = append(st.Blocks, b)
+	w.Height = b.Height
+	w.ConfirmedSiacoinBalance = w.ConfirmedSiacoinBalance.Add(payout)
+}
+
+// addHost adds the announced host to the host DB or updates its net address.
+func (s *Server) addHost(addr modules.NetAddress, pk types.SiaPublicKey) {
+	for i, h := range s.state.HostDB {
+		if h.PublicKeyString == pk.String() {
+			s.state.HostDB[i].NetAddress = addr
+			return
+		}
+	}
+	var h api.ExtendedHostDBEntry
+	h.PublicKey = pk
+	h.PublicKeyString = pk.String()
+	h.NetAddress = addr
+	h.AcceptingContracts = true
+	s.state.HostDB = append(s.state.HostDB, h)
+}
+
+// ownsAddress returns true if the address was generated by the mock's wallet.
+func (s *Server) ownsAddress(uh types.UnlockHash) bool {
+	for _, a := range s.state.Addresses {
+		if a == uh {
+			return true
+		}
+	}
+	return false
+}
+
+// managedInjectedError returns the error injected into the route of the
+// given request, if any, and counts the request.
+func (s *Server) managedInjectedError(method, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := method + " " + path
+	s.requests[request]++
+	for route, ie := range s.errors {
+		if !routeMatches(route, request) {
+			continue
+		}
+		if ie.remaining > 0 {
+			ie.remaining--
+			if ie.remaining == 0 {
+				delete(s.errors, route)
+			}
+		}
+		return ie.err
+	}
+	return nil
+}
+
+// routeMatches returns true if the request "METHOD /path" matches the route,
+// i.e. it has the route's method and the route's path or its subpath.
+func routeMatches(route, request string) bool {
+	return request == route || strings.HasPrefix(request, strings.TrimSuffix(route, "/")+"/")
+}
+
+// subOrZero returns x-y, or zero if y is greater than x, e.g. after a test
+// scripted the wallet's balance.
+func subOrZero(x, y types.Currency) types.Currency {
+	if x.Cmp(y) < 0 {
+		return types.ZeroCurrency
+	}
+	return x.Sub(y)
+}
+
+// randomBlockID returns a random block ID.
+func randomBlockID() (id types.BlockID) {
+	fastrand.Read(id[:])
+	return
+}
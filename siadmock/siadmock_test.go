@@ -0,0 +1,148 @@
+package siadmock
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/node/api/client"
+	"go.sia.tech/siad/types"
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// newTestClient starts a new mock siad and returns it with a client of its
+// API.
+func newTestClient(t *testing.T) (*Server, *client.Client) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := client.DefaultOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Address = s.Address()
+	return s, client.New(opts)
+}
+
+// TestWallet tests initializing the mock's wallet, mining and sending
+// Siacoins.
+func TestWallet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	s, c := newTestClient(t)
+	defer func() {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Init and unlock the wallet
+	wip, err := c.WalletInitPost("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WalletUnlockPost("wrong password"); err == nil {
+		t.Fatal("expected unlocking the wallet with a wrong password to fail")
+	}
+	if err := c.WalletUnlockPost(wip.PrimarySeed); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine until the wallet has a balance
+	if err := c.MinerStartGet(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for {
+		wg, err := c.WalletGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !wg.ConfirmedSiacoinBalance.IsZero() {
+			break
+		}
+		if time.Since(start) > time.Second*10 {
+			t.Fatal("the mock's miner didn't mine a block")
+		}
+		time.Sleep(miningBlockFrequency)
+	}
+	if err := c.MinerStopGet(); err != nil {
+		t.Fatal(err)
+	}
+	balance := s.State().Wallet.ConfirmedSiacoinBalance
+
+	// Send Siacoins to another wallet, the transfer is confirmed by the next
+	// block
+	var dest types.UnlockHash
+	amount := types.SiacoinPrecision.Mul64(100)
+	if _, err := c.WalletSiacoinsPost(balance.Add(amount), dest, false); err == nil {
+		t.Fatal("expected sending more than the balance to fail")
+	}
+	if _, err := c.WalletSiacoinsPost(amount, dest, false); err != nil {
+		t.Fatal(err)
+	}
+	wg, err := c.WalletGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wg.UnconfirmedOutgoingSiacoins.Equals(amount) {
+		t.Fatalf("expected unconfirmed outgoing %v, got %v", amount, wg.UnconfirmedOutgoingSiacoins)
+	}
+	s.MineBlocks(1)
+	wg, err = c.WalletGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wg.ConfirmedSiacoinBalance.Equals(balance.Sub(amount)) || !wg.UnconfirmedOutgoingSiacoins.IsZero() {
+		t.Fatalf("unexpected balances after the transfer was confirmed: %+v", wg)
+	}
+}
+
+// TestInjectedErrors tests that injected errors are returned by the matching
+// routes and that requests are counted.
+func TestInjectedErrors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	s, c := newTestClient(t)
+	defer func() {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The error is returned twice
+	s.SetErrorTimes("GET /consensus", errors.New("injected error"), 2)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ConsensusGet(); err == nil || !strings.Contains(err.Error(), "injected error") {
+			t.Fatalf("expected injected error, got %v", err)
+		}
+	}
+	if _, err := c.ConsensusGet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A route matches its subpaths, but not other paths with the same prefix
+	s.SetError("GET /consensus", errors.New("injected error"))
+	if _, err := c.ConsensusBlocksHeightGet(0); err == nil {
+		t.Fatal("expected injected error")
+	}
+	s.SetError("GET /consensus", nil)
+	s.SetError("GET /renter/download", errors.New("injected error"))
+	if _, err := c.RenterDownloadsGet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.Requests("GET /consensus"); n != 4 {
+		t.Fatalf("expected 4 consensus requests, got %v", n)
+	}
+	if n := s.Requests("GET /consensus/blocks"); n != 1 {
+		t.Fatalf("expected 1 consensus blocks request, got %v", n)
+	}
+}
@@ -531,7 +531,7 @@ func TestRenewContractBackupRestoreSnapshot(t *testing.T) {
 		t.Fatal(err)
 	}
 	restoreRenterAnt.Jr.StaticWalletSeed = backupRenterAnt.Jr.StaticWalletSeed
-	restoreRenterAnt.Config.Jobs = []string{"renter"}
+	restoreRenterAnt.Config.Jobs = []ant.JobConfig{{Name: "renter"}}
 	restoreRenterAnt.Config.DesiredCurrency = 100000
 	err = restoreRenterAnt.StartSiad(binariesbuilder.SiadBinaryPath(branch))
 	if err != nil {